@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+var (
+	// ambiguousCount 统计默认输出设备切换为无法分类的设备（或路由）的次数
+	ambiguousCount atomic.Uint64
+
+	ambiguousMu sync.Mutex
+	// ambiguousWarned 记录已警告过的设备，按 device.name，每个设备只警告一次
+	ambiguousWarned = make(map[string]bool)
+)
+
+// noteAmbiguous 在默认输出设备既不属于公共设备也不属于私有设备时计数，并对每个设备发出一次警告，
+// 列出其输出路由与 port.type，说明自动暂停为何不会触发
+func noteAmbiguous(dev Device, class policy.Class) {
+	if class != policy.Unclassified || dev.ID == 0 {
+		return
+	}
+	ambiguousCount.Add(1)
+
+	key := dev.Info.Props.DeviceName
+	if key == "" {
+		key = "#" + strconv.Itoa(dev.ID)
+	}
+	ambiguousMu.Lock()
+	warned := ambiguousWarned[key]
+	ambiguousWarned[key] = true
+	ambiguousMu.Unlock()
+	if warned {
+		return
+	}
+
+	var routes, portTypes []string
+	for _, r := range dev.Info.Params.Route {
+		if !strings.EqualFold(r.Direction, "output") {
+			continue
+		}
+		routes = append(routes, r.Name)
+		portTypes = append(portTypes, r.PortType())
+	}
+	zap.L().Warn("默认输出设备无法分类，切换到该设备时不会自动暂停，可通过 [classify] 或 devices.toml 指定其分类",
+		zap.String("device", DeviceDisplayName(dev)),
+		zap.String("name", dev.Info.Props.DeviceName),
+		zap.Strings("routes", routes),
+		zap.Strings("port_types", portTypes))
+}
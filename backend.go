@@ -0,0 +1,31 @@
+package main
+
+import "context"
+
+// Backend 负责与音频服务连接：将对象的变化以 pw-dump 格式送入 dispatcher，
+// 并在连接期间设置 controller；连接中断时返回，由 superviseBackend 重新连接
+type Backend interface {
+	Run(ctx context.Context) error
+}
+
+type pwDumpBackend struct{}
+
+func (pwDumpBackend) Run(ctx context.Context) error {
+	return runSubprocessBackend(ctx)
+}
+
+type nativeBackend struct{}
+
+func (nativeBackend) Run(ctx context.Context) error {
+	return runNativeBackend(ctx)
+}
+
+func newBackend(name string) Backend {
+	switch name {
+	case "native":
+		return nativeBackend{}
+	case "pulse":
+		return &pulseBackend{}
+	}
+	return pwDumpBackend{}
+}
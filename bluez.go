@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+func startBluezMonitor() {
+	if !config.Bluetooth.Enabled {
+		return
+	}
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		zap.L().Warn("无法连接系统总线，蓝牙断开检测不可用", zap.Error(err))
+		return
+	}
+
+	err = conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, "org.bluez.Device1"),
+	)
+	if err != nil {
+		zap.L().Warn("无法订阅蓝牙设备事件", zap.Error(err))
+		conn.Close()
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	go func() {
+		for sig := range signals {
+			if sig.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" || len(sig.Body) < 2 {
+				continue
+			}
+			changed, ok := sig.Body[1].(map[string]dbus.Variant)
+			if !ok {
+				continue
+			}
+			connected, ok := changed["Connected"]
+			if !ok {
+				continue
+			}
+			if v, _ := connected.Value().(bool); !v {
+				onBluezDisconnect(sig.Path)
+			}
+		}
+	}()
+}
+
+func bluezAddress(p dbus.ObjectPath) string {
+	base := path.Base(string(p))
+	if !strings.HasPrefix(base, "dev_") {
+		return ""
+	}
+	return strings.ReplaceAll(strings.TrimPrefix(base, "dev_"), "_", ":")
+}
+
+func onBluezDisconnect(p dbus.ObjectPath) {
+	addr := bluezAddress(p)
+	if addr == "" {
+		return
+	}
+	zap.L().Debug("蓝牙设备已断开", zap.String("address", addr))
+
+	sink := sinkPolicy.Sink()
+	devID, ok := GetDeviceIDBySinkName(sink)
+	if !ok {
+		return
+	}
+	nodeID, ok := GetSinkIDByName(sink)
+	if !ok {
+		return
+	}
+
+	dev, exists := registry.Device(devID)
+	if !exists || !strings.EqualFold(dev.Info.Props.BluezAddress, addr) || !IsPrivateDevice(dev) {
+		return
+	}
+
+	zap.L().Info("蓝牙设备已断开", zap.String("address", addr))
+	// 蓝牙输出节点即将消失，静音它本身没有意义，因此直接静音跟随它的播放流；
+	// 没有播放流时无需静音，切换到新设备后由之后的默认输出变更处理
+	streams := outputStreamsFor(nodeID)
+	if streams == nil {
+		streams = []int{}
+	}
+	class := sinkPolicy.Class()
+	applyTransition(transition{
+		oldSink: sink,
+		newSink: sink,
+		from:    class,
+		to:      class,
+		dev:     dev,
+		nodeID:  nodeID,
+		reason:  "蓝牙设备断开",
+		preempt: true,
+		targets: streams,
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/nsplup/pw-autopaused/pkg/pwmon"
+)
+
+const (
+	bluezDevice = `{"id":60,"info":{"props":{"device.name":"bluez_card.00_1B_66_AA_BB_CC","device.description":"MOMENTUM 4","api.bluez5.address":"00:1B:66:AA:BB:CC"},
+		"params":{"Route":[{"index":1,"name":"headset-output","direction":"Output","available":"yes","info":[1,"port.type","headset"]}]}}}`
+	bluezSink   = `{"id":61,"info":{"props":{"node.name":"bluez_out","media.class":"Audio/Sink","device.id":60}}}`
+	bluezStream = `{"id":70,"info":{"props":{"node.name":"Firefox","media.class":"Stream/Output/Audio","application.name":"Firefox"}}}`
+	bluezLink   = `{"id":88,"info":{"output-node-id":70,"output-port-id":73,"input-node-id":61,"input-port-id":62,"state":"active"}}`
+	bluezPath   = dbus.ObjectPath("/org/bluez/hci0/dev_00_1B_66_AA_BB_CC")
+)
+
+func TestBluezDisconnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		streaming bool
+		muted     []int
+	}{
+		{name: "静音跟随蓝牙输出的播放流", streaming: true, muted: []int{70}},
+		// 没有播放流时不应转而静音即将消失的蓝牙输出节点
+		{name: "没有播放流时不静音"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := setupTest(t)
+			putDevice(t, bluezDevice)
+			putNode(t, bluezSink)
+			if tt.streaming {
+				putNode(t, bluezStream)
+				var link pwmon.Link
+				if err := json.Unmarshal([]byte(bluezLink), &link); err != nil {
+					t.Fatal(err)
+				}
+				registry.PutLink(link)
+			}
+			setMetadata("default.audio.sink", "bluez_out")
+			fake.reset()
+
+			onBluezDisconnect(bluezPath)
+			if got := fake.muted(true); !slices.Equal(got, tt.muted) {
+				t.Errorf("静音的节点为 %v，期望 %v", got, tt.muted)
+			}
+			if got := lastAction(); got != "mute-only" {
+				t.Errorf("动作为 %q，期望 mute-only", got)
+			}
+		})
+	}
+}
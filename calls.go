@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// activeCall 检测是否正在通话：存在 media.role 为通信用途的流，或会议类应用的播放器正处于播放状态
+func activeCall() (string, bool) {
+	for _, node := range registry.Nodes() {
+		props := node.Info.Props
+		if !strings.HasPrefix(props.MediaClass, "Stream/") {
+			continue
+		}
+		role := props.MediaRole.String()
+		if strings.EqualFold(role, "communication") || strings.EqualFold(role, "phone") {
+			name := props.ApplicationName.String()
+			if name == "" {
+				name = props.NodeName
+			}
+			return name, true
+		}
+	}
+
+	for _, name := range playerNames() {
+		p, _ := lookupPlayer(name)
+		if p.PlaybackStatus == "Playing" && isCallPlayer(p) {
+			return p.Identity, true
+		}
+	}
+	return "", false
+}
+
+// isCallPlayer 判断播放器是否属于 call_apps 中的会议类应用
+func isCallPlayer(p Player) bool {
+	for _, app := range config.CallApps {
+		if matchName(app, p.Identity) || (p.DesktopEntry != "" && matchName(app, p.DesktopEntry)) {
+			return true
+		}
+	}
+	return false
+}
+
+func suppressedByCall(reason string) bool {
+	if !config.SuppressDuringCalls {
+		return false
+	}
+	app, ok := activeCall()
+	if ok {
+		zap.L().Info("检测到正在进行的通话，跳过本次处理", zap.String("app", app), zap.String("reason", reason))
+	}
+	return ok
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nsplup/pw-autopaused/policy"
+)
+
+// runCheckConfig 校验配置文件：语法错误、未知的键、无效的取值与匹配模式、devices.toml 中相互冲突的设备分类，
+// 并输出合并后生效的配置；存在错误时以非零状态退出，便于在 dotfiles 仓库的 CI 中使用
+func runCheckConfig(args []string) int {
+	set := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	quiet := set.Bool("quiet", false, "只报告问题，不输出生效的配置")
+	if err := set.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	// 指定文件时只校验这些文件，不读取系统级与用户配置
+	paths, explicit := set.Args(), set.NArg() > 0
+	if !explicit {
+		paths = ConfigPaths()
+	}
+
+	r := &doctorReport{}
+	for _, p := range paths {
+		checkConfigFile(r, p, explicit)
+	}
+	if r.failed {
+		return 1
+	}
+
+	conf, err := mergeConfig(paths...)
+	if err != nil {
+		r.fail("%v", err)
+		return 1
+	}
+	validateConfig(r, conf)
+	if !explicit {
+		checkDeviceChoices(r, DeviceChoicesPath())
+	}
+
+	if !*quiet {
+		fmt.Println("\n# 生效的配置")
+		if err := toml.NewEncoder(os.Stdout).Encode(conf); err != nil {
+			r.fail("无法输出生效的配置：%v", err)
+		}
+	}
+	if r.failed {
+		return 1
+	}
+	return 0
+}
+
+// checkConfigFile 解析单个配置文件，报告语法错误与未知的键
+func checkConfigFile(r *doctorReport, p string, required bool) {
+	if p == "" {
+		return
+	}
+	var scratch Config
+	md, err := toml.DecodeFile(p, &scratch)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !required:
+		return
+	case err != nil:
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			r.fail("%s：%s", p, strings.TrimSpace(perr.ErrorWithPosition()))
+		} else {
+			r.fail("%s：%v", p, err)
+		}
+		return
+	}
+	unknown := md.Undecoded()
+	for _, key := range unknown {
+		r.fail("%s：未知的配置项 %s", p, key)
+	}
+	if len(unknown) == 0 {
+		r.ok("%s：语法正确", p)
+	}
+}
+
+func checkEnum(r *doctorReport, key, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		r.fail("%s 的取值 %q 无效，可选：%s", key, value, strings.Join(allowed, "、"))
+	}
+}
+
+// validateConfig 校验合并后的配置中各项的取值
+func validateConfig(r *doctorReport, c Config) {
+	failed := r.failed
+	r.failed = false
+
+	checkEnum(r, "backend", c.Backend, "pw-dump", "native", "pulse")
+	checkEnum(r, "mode", c.Mode, "pause", "duck", "mute-only", "safe-sink")
+	checkEnum(r, "mute_method", c.MuteMethod, "mute", "volume")
+	checkEnum(r, "control", c.Control, "auto", "pw-cli", "wpctl", "native")
+	checkEnum(r, "guard", c.Guard, "sink", "streams", "gate")
+	checkEnum(r, "session_bus", c.SessionBus, "auto", "required", "none")
+	checkEnum(r, "session_manager", c.SessionManager, "auto", "wireplumber", "media-session")
+	checkEnum(r, "schedule_default", c.ScheduleDefault, "", "strict", "normal", "off")
+	checkEnum(r, "source.policy", c.Source.Policy, "none", "mute-source", "mute-streams")
+	checkEnum(r, "streams.mode", c.Streams.Mode, "none", "mute")
+	checkEnum(r, "dnd.policy", c.DND.Policy, "default", "always-pause", "never-resume")
+	checkEnum(r, "screencast.policy", c.Screencast.Policy, "default", "suppress", "pause")
+	if c.Duck.Level < 0 || c.Duck.Level > 1 {
+		r.fail("duck.level 应在 0 到 1 之间：%v", c.Duck.Level)
+	}
+	if c.Resume.Rewind < 0 {
+		r.fail("resume.rewind 不能为负数：%v", c.Resume.Rewind)
+	}
+	if c.Earcon.Volume < 0 || c.Earcon.Volume > 1 {
+		r.fail("earcon.volume 应在 0 到 1 之间：%v", c.Earcon.Volume)
+	}
+
+	for _, p := range configPatterns(c) {
+		if _, err := compilePattern(p); err != nil {
+			r.fail("%v", err)
+		}
+	}
+	if _, err := compileScript(c); err != nil {
+		r.fail("%v", err)
+	}
+	for _, p := range c.Classify.PublicRoutes {
+		if slices.Contains(c.Classify.PrivateRoutes, p) {
+			r.fail("路由模式 %q 同时出现在 classify.public_routes 与 classify.private_routes 中", p)
+		}
+	}
+	for _, name := range c.Players.Allow {
+		if slices.Contains(c.Players.Deny, name) {
+			r.fail("播放器 %q 同时出现在 players.allow 与 players.deny 中", name)
+		}
+	}
+	for i, rule := range c.Profiles.Rules {
+		checkEnum(r, fmt.Sprintf("profiles.rules[%d].action", i), rule.Action, "pause", "resume")
+	}
+	for i, rule := range c.Players.Rules {
+		checkEnum(r, fmt.Sprintf("players.rules[%d].action", i), rule.Action, "pause", "stop", "mute", "ignore")
+	}
+	for i, e := range c.Schedule {
+		key := fmt.Sprintf("schedule[%d]", i)
+		if _, ok := parseClock(e.Start); !ok {
+			r.fail("%s.start 不是有效的时间（HH:MM）：%q", key, e.Start)
+		}
+		if _, ok := parseClock(e.End); !ok {
+			r.fail("%s.end 不是有效的时间（HH:MM）：%q", key, e.End)
+		}
+		for _, d := range e.Days {
+			if !slices.Contains(weekdayNames, strings.ToLower(d)) {
+				r.fail("%s.days 中的 %q 无效，可选：%s", key, d, strings.Join(weekdayNames, "、"))
+			}
+		}
+		checkEnum(r, key+".mode", e.Mode, "strict", "normal", "off")
+	}
+	classes := []string{policy.Private.String(), policy.Public.String(), policy.Unclassified.String(), "any"}
+	for key, value := range c.Transitions {
+		from, to, ok := strings.Cut(key, "-")
+		if !ok || !slices.Contains(classes, from) || !slices.Contains(classes, to) {
+			r.fail("transitions 中的键 %q 无效，应为「原分类-新分类」，分类可选：%s", key, strings.Join(classes, "、"))
+		}
+		if _, ok := policy.ParseAction(value); !ok {
+			r.fail("transitions.%s 的动作 %q 无效，可选：pause、resume、none", key, value)
+		}
+	}
+
+	if !r.failed {
+		r.ok("配置项的取值均有效")
+	}
+	r.failed = r.failed || failed
+}
+
+// checkDeviceChoices 校验 devices.toml，同一设备不应出现在多个分类中
+func checkDeviceChoices(r *doctorReport, p string) {
+	if p == "" {
+		return
+	}
+	var c DeviceChoices
+	md, err := toml.DecodeFile(p, &c)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		r.fail("%s：%v", p, err)
+		return
+	}
+	failed := r.failed
+	r.failed = false
+	for _, key := range md.Undecoded() {
+		r.fail("%s：未知的配置项 %s", p, key)
+	}
+	seen := make(map[string]string)
+	for _, list := range []struct {
+		name    string
+		devices []string
+	}{{"public", c.Public}, {"private", c.Private}, {"ignore", c.Ignore}} {
+		for _, dev := range list.devices {
+			if prev, ok := seen[dev]; ok && prev != list.name {
+				r.fail("%s：设备 %s 同时出现在 %s 与 %s 中", p, dev, prev, list.name)
+			}
+			seen[dev] = list.name
+		}
+	}
+	if !r.failed {
+		r.ok("%s：%d 个设备的分类没有冲突", p, len(seen))
+	}
+	r.failed = r.failed || failed
+}
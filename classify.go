@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	patternMu    sync.Mutex
+	patternCache = make(map[string]*regexp.Regexp)
+)
+
+// configPattern 为配置中用于匹配名称的一项，Glob 为 false 的项按名称精确匹配（不区分大小写）
+type configPattern struct {
+	Key     string
+	Pattern string
+	Glob    bool
+}
+
+// configPatterns 列出配置中所有匹配名称的项，均可使用以 `re:` 开头的 RE2 正则表达式
+func configPatterns(c Config) []configPattern {
+	var out []configPattern
+	add := func(key string, glob bool, patterns ...string) {
+		for _, p := range patterns {
+			if p != "" {
+				out = append(out, configPattern{key, p, glob})
+			}
+		}
+	}
+	add("classify.public_routes", true, c.Classify.PublicRoutes...)
+	add("classify.private_routes", true, c.Classify.PrivateRoutes...)
+	for i, rule := range c.Profiles.Rules {
+		add(fmt.Sprintf("profiles.rules[%d]", i), true, rule.From, rule.To)
+	}
+	add("players.allow", false, c.Players.Allow...)
+	add("players.deny", false, c.Players.Deny...)
+	for i, rule := range c.Players.Rules {
+		add(fmt.Sprintf("players.rules[%d].match", i), false, rule.Match)
+	}
+	add("call_apps", false, c.CallApps...)
+	add("streams.include", false, c.Streams.Include...)
+	add("streams.exclude", false, c.Streams.Exclude...)
+	return out
+}
+
+// compilePattern 编译 `re:` 正则表达式，glob 为真时同时检查通配符语法
+func compilePattern(p configPattern) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(p.Pattern, "re:"); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s 中的正则表达式 %q 无效：%w", p.Key, p.Pattern, err)
+		}
+		return re, nil
+	}
+	if _, err := path.Match(p.Pattern, ""); p.Glob && err != nil {
+		return nil, fmt.Errorf("%s 中的通配符 %q 无效：%w", p.Key, p.Pattern, err)
+	}
+	return nil, nil
+}
+
+// compilePatterns 在加载配置时预先编译其中所有的正则表达式，返回第一个无效的模式
+func compilePatterns(c Config) error {
+	cache := make(map[string]*regexp.Regexp)
+	for _, p := range configPatterns(c) {
+		re, err := compilePattern(p)
+		if err != nil {
+			return err
+		}
+		if re != nil {
+			cache[strings.TrimPrefix(p.Pattern, "re:")] = re
+		}
+	}
+	patternMu.Lock()
+	patternCache = cache
+	patternMu.Unlock()
+	return nil
+}
+
+// lookupRegexp 返回 `re:` 正则表达式，未在加载配置时编译的（如程序内部生成的模式）在首次使用时编译
+func lookupRegexp(expr string) *regexp.Regexp {
+	patternMu.Lock()
+	defer patternMu.Unlock()
+	re, cached := patternCache[expr]
+	if !cached {
+		var err error
+		re, err = regexp.Compile("(?i)" + expr)
+		if err != nil {
+			zap.L().Warn("无效的正则表达式", zap.String("pattern", "re:"+expr), zap.Error(err))
+		}
+		patternCache[expr] = re
+	}
+	return re
+}
+
+// matchPattern 支持 glob（如 `*headphones*`）与以 `re:` 开头的正则表达式，均不区分大小写
+func matchPattern(pattern, s string) bool {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re := lookupRegexp(expr)
+		return re != nil && re.MatchString(s)
+	}
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(s))
+	return ok
+}
+
+// matchName 按名称精确匹配（不区分大小写），以 `re:` 开头时按正则表达式匹配
+func matchName(pattern, s string) bool {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re := lookupRegexp(expr)
+		return re != nil && s != "" && re.MatchString(s)
+	}
+	return strings.EqualFold(pattern, s)
+}
+
+func matchRoutePattern(route RouteInfo, patterns []string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, route.Name) || (route.Description != "" && matchPattern(p, route.Description)) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeIs 判断路由是否属于公共（public 为 true）或私有设备：
+// 优先按配置中的路由名称与描述匹配，未命中时再按 port.type 关键字匹配
+func routeIs(route RouteInfo, keywords []string, public bool) bool {
+	own, other := config.Classify.PrivateRoutes, config.Classify.PublicRoutes
+	if public {
+		own, other = other, own
+	}
+	if matchRoutePattern(route, own) {
+		return true
+	}
+	if matchRoutePattern(route, other) {
+		return false
+	}
+	return routeMatches(route, keywords)
+}
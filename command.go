@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var commandUsage = [][2]string{
+	{"snooze [分钟]", "暂时停用自动暂停，到期后自动恢复（默认 15 分钟）"},
+	{"unsnooze", "立即恢复自动暂停"},
+	{"panic", "立即静音默认输出并暂停所有播放器，不论设备分类"},
+	{"recover", "取消 panic 的静音并恢复被暂停的播放器"},
+	{"status", "显示守护进程的当前状态"},
+	{"history", "显示最近触发的事件及执行的动作"},
+	{"statusbar", "持续输出 waybar 兼容的状态 JSON"},
+	{"doctor", "检查运行环境并列出设备的分类结果"},
+	{"init", "列出当前设备、确定其分类并生成带注释的配置文件"},
+	{"check-config [文件...]", "校验配置文件并输出合并后生效的配置，有错误时以非零状态退出"},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "用法：%s [参数] [命令]\n\n命令：\n", os.Args[0])
+	for _, c := range commandUsage {
+		fmt.Fprintf(out, "  %-16s %s\n", c[0], c[1])
+	}
+	fmt.Fprintf(out, "\n参数：\n")
+	flag.PrintDefaults()
+}
+
+func callService(method string, args ...any) *dbus.Call {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return &dbus.Call{Err: err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	obj := conn.Object(serviceName, servicePath)
+	return obj.CallWithContext(ctx, serviceInterface+"."+method, 0, args...)
+}
+
+func runCommand(args []string) int {
+	switch args[0] {
+	case "snooze":
+		minutes := uint64(15)
+		if len(args) > 1 {
+			n, err := strconv.ParseUint(args[1], 10, 32)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "无效的分钟数：%s\n", args[1])
+				return 2
+			}
+			minutes = n
+		}
+		if err := callService("Snooze", uint32(minutes)).Err; err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("自动暂停已停用 %d 分钟\n", minutes)
+	case "unsnooze":
+		if err := callService("Unsnooze").Err; err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("自动暂停已恢复")
+	case "panic":
+		if err := callService("Panic").Err; err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("已静音默认输出并暂停所有播放器，使用 recover 恢复")
+	case "recover":
+		if err := callService("Recover").Err; err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("已取消静音并恢复被暂停的播放器")
+	case "status":
+		return printStatus()
+	case "history":
+		return printHistory()
+	case "statusbar":
+		return runStatusbar()
+	case "doctor":
+		return runDoctor()
+	case "init":
+		return runInit(args[1:])
+	case "check-config":
+		return runCheckConfig(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知的命令：%s\n", args[0])
+		return 2
+	}
+	return 0
+}
+
+func printStatus() int {
+	var status map[string]dbus.Variant
+	if err := callService("Status").Store(&status); err != nil {
+		fmt.Fprintln(os.Stderr, "无法连接守护进程：", err)
+		return 1
+	}
+
+	str := func(key string) string {
+		v, _ := status[key].Value().(string)
+		return v
+	}
+	num := func(key string) int64 {
+		switch v := status[key].Value().(type) {
+		case int64:
+			return v
+		case uint32:
+			return int64(v)
+		}
+		return 0
+	}
+	classes := map[string]string{"public": "公共设备", "private": "私有设备"}
+	class, ok := classes[str("Classification")]
+	if !ok {
+		class = "未知"
+	}
+
+	sink := str("DefaultSink")
+	if sink == "" {
+		sink = "（无）"
+	}
+	fmt.Printf("默认输出设备：%s（%s）\n", sink, class)
+	fmt.Printf("跟踪的对象：%d 个节点，%d 个设备\n", num("Nodes"), num("Devices"))
+	fmt.Printf("处理方式：%s\n", str("Mode"))
+	if failures, _ := status["CommandFailures"].Value().(uint64); failures > 0 {
+		fmt.Printf("失败的 PipeWire 指令：%d 条\n", failures)
+	}
+	if ambiguous, _ := status["Ambiguous"].Value().(uint64); ambiguous > 0 {
+		fmt.Printf("切换到无法分类的输出设备：%d 次（未自动暂停，可运行 doctor 查看设备的路由）\n", ambiguous)
+	}
+	if devices, _ := status["StartupDevices"].Value().(map[string]string); len(devices) > 0 {
+		names := make([]string, 0, len(devices))
+		for name := range devices {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("启动时的设备分类：")
+		for _, name := range names {
+			class, ok := classes[devices[name]]
+			if !ok {
+				class = "未分类"
+			}
+			fmt.Printf("  %s：%s\n", name, class)
+		}
+	}
+	if list, _ := status["Players"].Value().([]string); len(list) > 0 {
+		fmt.Printf("播放器：%s\n", strings.Join(list, "，"))
+	} else {
+		fmt.Println("播放器：无")
+	}
+	if enabled, _ := status["Enabled"].Value().(bool); enabled {
+		fmt.Println("自动暂停：已启用")
+	} else if at := num("SnoozedUntil"); at > 0 {
+		fmt.Printf("自动暂停：已停用，将于 %s 恢复\n", time.Unix(at, 0).Format("15:04:05"))
+	} else {
+		fmt.Println("自动暂停：已停用")
+	}
+	if at := num("LastEventTime"); at > 0 {
+		fmt.Printf("最近一次事件：%s，%s\n", str("LastEvent"), time.Unix(at, 0).Format("2006-01-02 15:04:05"))
+	} else {
+		fmt.Println("最近一次事件：无")
+	}
+	return 0
+}
+
+func printHistory() int {
+	var entries []map[string]dbus.Variant
+	if err := callService("History").Store(&entries); err != nil {
+		fmt.Fprintln(os.Stderr, "无法连接守护进程：", err)
+		return 1
+	}
+	if len(entries) == 0 {
+		fmt.Println("暂无事件记录")
+		return 0
+	}
+
+	for _, e := range entries {
+		str := func(key string) string {
+			v, _ := e[key].Value().(string)
+			return v
+		}
+		at, _ := e["Time"].Value().(int64)
+		line := fmt.Sprintf("%s  %s", time.Unix(at, 0).Format("2006-01-02 15:04:05"), str("Trigger"))
+		if old, cur := str("OldSink"), str("NewSink"); old != "" && cur != "" && old != cur {
+			line += fmt.Sprintf("  %s → %s", old, cur)
+		} else if cur != "" {
+			line += "  " + cur
+		}
+		if dev := str("Device"); dev != "" {
+			line += fmt.Sprintf("（%s）", dev)
+		}
+		if by := str("Initiator"); by != "" {
+			line += "  推测由 " + by + " 发起"
+		}
+		if action := str("Action"); action != "" {
+			line += "  动作：" + action
+		}
+		if players, _ := e["Players"].Value().([]string); len(players) > 0 {
+			results, _ := e["Results"].Value().(map[string]string)
+			for i, p := range players {
+				if r := results[p]; r != "" {
+					players[i] = p + "（" + r + "）"
+				}
+			}
+			line += "  播放器：" + formatPlayers(players)
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
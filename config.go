@@ -0,0 +1,373 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+type Config struct {
+	Backend             string            `toml:"backend"`
+	Mode                string            `toml:"mode"`
+	ReconcileInterval   time.Duration     `toml:"reconcile_interval"`
+	MuteMethod          string            `toml:"mute_method"`
+	Control             string            `toml:"control"`
+	Guard               string            `toml:"guard"`
+	SessionManager      string            `toml:"session_manager"`
+	SessionBus          string            `toml:"session_bus"`
+	SinkMediaClasses    []string          `toml:"sink_media_classes"`
+	SettleWindow        time.Duration     `toml:"settle_window"`
+	Cooldown            time.Duration     `toml:"cooldown"`
+	PauseTimeout        time.Duration     `toml:"pause_timeout"`
+	UnmuteDelay         time.Duration     `toml:"unmute_delay"`
+	PauseConfirmTimeout time.Duration     `toml:"pause_confirm_timeout"`
+	KeepMutedOnTimeout  bool              `toml:"keep_muted_on_timeout"`
+	PauseRetries        int               `toml:"pause_retries"`
+	PlayerCallTimeout   time.Duration     `toml:"player_call_timeout"`
+	SuppressDuringCalls bool              `toml:"suppress_during_calls"`
+	CallApps            []string          `toml:"call_apps"`
+	Duck                DuckConfig        `toml:"duck"`
+	Fade                FadeConfig        `toml:"fade"`
+	Resume              ResumeConfig      `toml:"resume"`
+	Players             PlayersConfig     `toml:"players"`
+	Notify              NotifyConfig      `toml:"notify"`
+	Earcon              EarconConfig      `toml:"earcon"`
+	Source              SourceConfig      `toml:"source"`
+	Bluetooth           BluetoothConfig   `toml:"bluetooth"`
+	Hooks               HooksConfig       `toml:"hooks"`
+	Logind              LogindConfig      `toml:"logind"`
+	HDMI                HDMIConfig        `toml:"hdmi"`
+	DND                 DNDConfig         `toml:"dnd"`
+	Tray                TrayConfig        `toml:"tray"`
+	Profiles            ProfilesConfig    `toml:"profiles"`
+	Shortcuts           ShortcutsConfig   `toml:"shortcuts"`
+	Screencast          ScreencastConfig  `toml:"screencast"`
+	Script              ScriptConfig      `toml:"script"`
+	SafeSink            SafeSinkConfig    `toml:"safe_sink"`
+	Gate                GateConfig        `toml:"gate"`
+	Classify            ClassifyConfig    `toml:"classify"`
+	Schedule            []ScheduleEntry   `toml:"schedule"`
+	ScheduleDefault     string            `toml:"schedule_default"`
+	HistorySize         int               `toml:"history_size"`
+	Streams             StreamsConfig     `toml:"streams"`
+	Transitions         map[string]string `toml:"transitions"`
+}
+
+type FadeConfig struct {
+	Duration time.Duration `toml:"duration"`
+	Step     time.Duration `toml:"step"`
+}
+
+type DuckConfig struct {
+	Level float64 `toml:"level"`
+}
+
+type StreamsConfig struct {
+	Mode    string   `toml:"mode"`
+	PerSink bool     `toml:"per_sink"`
+	Include []string `toml:"include"`
+	Exclude []string `toml:"exclude"`
+}
+
+type HooksConfig struct {
+	OnPause         string        `toml:"on-pause"`
+	OnResume        string        `toml:"on-resume"`
+	OnPublicSwitch  string        `toml:"on-public-switch"`
+	OnPrivateSwitch string        `toml:"on-private-switch"`
+	Timeout         time.Duration `toml:"timeout"`
+}
+
+type ClassifyConfig struct {
+	PublicRoutes  []string `toml:"public_routes"`
+	PrivateRoutes []string `toml:"private_routes"`
+	Prompt        bool     `toml:"prompt"`
+}
+
+type ScheduleEntry struct {
+	Days  []string `toml:"days"`
+	Start string   `toml:"start"`
+	End   string   `toml:"end"`
+	Mode  string   `toml:"mode"`
+}
+
+type LogindConfig struct {
+	PauseOnSleep      bool `toml:"pause_on_sleep"`
+	PauseOnLock       bool `toml:"pause_on_lock"`
+	OnlyActiveSession bool `toml:"only_active_session"`
+}
+
+type SafeSinkConfig struct {
+	Name    string `toml:"name"`
+	Restore bool   `toml:"restore"`
+}
+
+type GateConfig struct {
+	Name string `toml:"name"`
+}
+
+type ProfilesConfig struct {
+	Rules []ProfileRule `toml:"rules"`
+}
+
+// ProfileRule 的 From 与 To 为配置文件名称的匹配模式（同 classify），Action 为 pause 或 resume
+type ProfileRule struct {
+	From   string `toml:"from"`
+	To     string `toml:"to"`
+	Action string `toml:"action"`
+}
+
+type TrayConfig struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// ShortcutsConfig 中的 Panic 与 Resume 为向门户建议的按键，如 CTRL+ALT+m；实际按键以用户在桌面环境中的设置为准
+type ShortcutsConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Panic   string `toml:"panic"`
+	Resume  string `toml:"resume"`
+}
+
+// ScreencastConfig 中的 Policy 为 default（不受影响）、suppress（共享期间不自动暂停）
+// 或 pause（开始共享时暂停播放器，结束后恢复）
+type ScreencastConfig struct {
+	Policy string `toml:"policy"`
+}
+
+// ScriptConfig 中的 Expr 为 expr-lang 表达式，根据设备切换事件返回动作，见 script.go 中的 scriptEnv
+type ScriptConfig struct {
+	Expr string `toml:"expr"`
+}
+
+type DNDConfig struct {
+	Enabled           bool   `toml:"enabled"`
+	SkipNotifications bool   `toml:"skip_notifications"`
+	Policy            string `toml:"policy"`
+}
+
+type HDMIConfig struct {
+	PauseOnHotplug bool `toml:"pause_on_hotplug"`
+}
+
+type BluetoothConfig struct {
+	Enabled bool `toml:"enabled"`
+}
+
+type SourceConfig struct {
+	Policy string `toml:"policy"`
+}
+
+type NotifyConfig struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// EarconConfig 为自动暂停后在公共设备上播放的提示音
+type EarconConfig struct {
+	Enabled bool   `toml:"enabled"`
+	File    string `toml:"file"`
+	// Volume 为 0 到 1 之间的线性音量，由 earconCommand 换算为各播放程序的参数
+	Volume float64 `toml:"volume"`
+}
+
+type PlayersConfig struct {
+	Allow      []string     `toml:"allow"`
+	Deny       []string     `toml:"deny"`
+	OnlyRouted bool         `toml:"only_routed"`
+	Rules      []PlayerRule `toml:"rules"`
+}
+
+type PlayerRule struct {
+	Match  string `toml:"match"`
+	Action string `toml:"action"`
+}
+
+type ResumeConfig struct {
+	Enabled        bool          `toml:"enabled"`
+	Window         time.Duration `toml:"window"`
+	OfferOnStartup bool          `toml:"offer_on_startup"`
+	// Rewind 为自动恢复时回退的时长，弥补切换设备期间外放的内容
+	Rewind time.Duration `toml:"rewind"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Backend:             "pw-dump",
+		Mode:                "pause",
+		ReconcileInterval:   5 * time.Minute,
+		MuteMethod:          "mute",
+		Control:             "auto",
+		Guard:               "sink",
+		SessionManager:      "auto",
+		SessionBus:          "auto",
+		SinkMediaClasses:    []string{"Audio/Sink"},
+		PauseTimeout:        3 * time.Second,
+		Cooldown:            3 * time.Second,
+		UnmuteDelay:         time.Second,
+		PauseConfirmTimeout: 5 * time.Second,
+		KeepMutedOnTimeout:  true,
+		PauseRetries:        2,
+		PlayerCallTimeout:   3 * time.Second,
+		SuppressDuringCalls: true,
+		HistorySize:         50,
+		CallApps:            []string{"Zoom", "Microsoft Teams", "Skype", "Slack", "Discord", "Jitsi Meet"},
+		Duck: DuckConfig{
+			Level: 0.2,
+		},
+		Fade: FadeConfig{
+			Duration: 200 * time.Millisecond,
+			Step:     20 * time.Millisecond,
+		},
+		Resume: ResumeConfig{
+			Enabled:        true,
+			Window:         10 * time.Minute,
+			OfferOnStartup: true,
+		},
+		Players: PlayersConfig{
+			OnlyRouted: true,
+		},
+		Notify: NotifyConfig{
+			Enabled: true,
+		},
+		Earcon: EarconConfig{
+			File:   "/usr/share/sounds/freedesktop/stereo/message.oga",
+			Volume: 0.3,
+		},
+		Source: SourceConfig{
+			Policy: "none",
+		},
+		Bluetooth: BluetoothConfig{
+			Enabled: true,
+		},
+		SafeSink: SafeSinkConfig{
+			Name:    "pw-autopaused-safe",
+			Restore: true,
+		},
+		Profiles: ProfilesConfig{
+			Rules: []ProfileRule{
+				{To: "headset?head?unit*", Action: "pause"},
+				{From: "headset?head?unit*", To: "a2dp*", Action: "resume"},
+			},
+		},
+		Gate: GateConfig{
+			Name: "pw-autopaused-gate",
+		},
+		Screencast: ScreencastConfig{
+			Policy: "default",
+		},
+		DND: DNDConfig{
+			Enabled:           true,
+			SkipNotifications: true,
+			Policy:            "default",
+		},
+		Classify: ClassifyConfig{
+			Prompt: true,
+		},
+		Logind: LogindConfig{
+			OnlyActiveSession: true,
+		},
+		Hooks: HooksConfig{
+			Timeout: 10 * time.Second,
+		},
+		Streams: StreamsConfig{
+			Mode:    "none",
+			PerSink: true,
+		},
+		Transitions: map[string]string{
+			"private-public": "pause",
+			"public-private": "resume",
+		},
+	}
+}
+
+// SystemConfigPath 为发行版或管理员提供默认值的系统级配置文件
+const SystemConfigPath = "/etc/pw-autopaused/config.toml"
+
+func ConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pw-autopaused", "config.toml")
+}
+
+// ConfigPaths 返回按优先级从低到高排列的配置文件
+func ConfigPaths() []string {
+	return []string{SystemConfigPath, ConfigPath()}
+}
+
+// LoadConfig 依次读取 paths 中的配置文件，后读取的文件覆盖先前文件中出现的键，
+// 未出现的键保留之前的值；数组整体替换而不是追加。不存在的文件会被跳过。
+// 配置中的正则表达式与规则表达式在此时编译，无效时返回指明配置项与模式的错误
+func LoadConfig(paths ...string) (Config, error) {
+	conf, err := mergeConfig(paths...)
+	if err != nil {
+		return conf, err
+	}
+	if err := compilePatterns(conf); err != nil {
+		return DefaultConfig(), err
+	}
+	program, err := compileScript(conf)
+	if err != nil {
+		return DefaultConfig(), err
+	}
+	scriptProgram.Store(program)
+	return conf, nil
+}
+
+// mergeConfig 按 LoadConfig 的规则合并配置文件，不编译其中的模式
+func mergeConfig(paths ...string) (Config, error) {
+	conf := DefaultConfig()
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		// 先解析一次找出文件中出现的数组，清空后再解码，避免复用之前数组中的元素
+		scratch := DefaultConfig()
+		md, err := toml.DecodeFile(path, &scratch)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return DefaultConfig(), fmt.Errorf("%s: %w", path, err)
+		}
+		for _, key := range md.Keys() {
+			clearSlice(reflect.ValueOf(&conf).Elem(), key)
+		}
+		if _, err := toml.DecodeFile(path, &conf); err != nil {
+			return DefaultConfig(), fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return conf, nil
+}
+
+// clearSlice 将 key 对应的数组字段置空
+func clearSlice(v reflect.Value, key toml.Key) {
+	for _, name := range key {
+		if v.Kind() != reflect.Struct {
+			return
+		}
+		field, ok := fieldByTag(v, name)
+		if !ok {
+			return
+		}
+		v = field
+	}
+	if v.Kind() == reflect.Slice {
+		v.SetZero()
+	}
+}
+
+func fieldByTag(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("toml") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/nsplup/pw-autopaused/pipewire"
+	"go.uber.org/zap"
+)
+
+// Controller 向 PipeWire 写入节点参数，由当前使用的后端提供
+type Controller interface {
+	SetNodeProps(nodeID int, values map[string]any) error
+}
+
+// restartableController 为可以在指令失败后重新建立连接的控制器
+type restartableController interface {
+	Restart() error
+}
+
+type nativeController struct {
+	client *pipewire.Client
+}
+
+func (c nativeController) SetNodeProps(nodeID int, values map[string]any) error {
+	return c.client.SetNodeProps(uint32(nodeID), values)
+}
+
+// controlMethod 返回 pw-dump 后端写入节点参数的方式：按配置的 control 选择，
+// auto 时依次尝试 pw-cli 与 wpctl，均不存在时改用原生协议
+func controlMethod() string {
+	switch config.Control {
+	case "pw-cli", "wpctl", "native":
+		return config.Control
+	case "", "auto":
+	default:
+		zap.L().Warn("未知的控制方式，自动选择", zap.String("control", config.Control))
+	}
+	for _, name := range []string{"pw-cli", "wpctl"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return "native"
+}
+
+// startController 启动写入节点参数所需的进程或连接，exited 在其意外中断时调用；
+// 返回的 stop 用于在后端退出时关闭连接
+func startController(ctx context.Context, exited func(error)) (c Controller, stop func(), err error) {
+	method := controlMethod()
+	zap.L().Info("正在启动控制进程...", zap.String("control", method))
+
+	switch method {
+	case "native":
+		// 仅用于写入参数，对象变化仍由 pw-dump 提供
+		client, err := pipewire.Dial(func([]pipewire.Global) {})
+		if err != nil {
+			return nil, nil, err
+		}
+		go func() {
+			select {
+			case <-client.Done():
+				exited(client.Err())
+			case <-ctx.Done():
+			}
+		}()
+		return nativeController{client: client}, func() { client.Close() }, nil
+	case "wpctl":
+		return wpctlController{}, func() {}, nil
+	default:
+		cli, err := startPwCli(ctx, exited)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cli, func() {}, nil
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxProcessDepth 限制向上查找父进程的层数
+const maxProcessDepth = 32
+
+// parentPID 读取 /proc/<pid>/stat 中的父进程号
+func parentPID(pid uint32) (uint32, bool) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, false
+	}
+	// 进程名可能包含空格与括号，从最后一个右括号之后开始解析：状态 父进程号 ...
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(ppid), true
+}
+
+// descendsFrom 判断进程 pid 是否为 ancestor 本身或其子孙进程：
+// 浏览器等多进程应用的 MPRIS 名称属于主进程，声音却由其子进程输出
+func descendsFrom(pid, ancestor uint32) bool {
+	for depth := 0; depth < maxProcessDepth && pid > 1; depth++ {
+		if pid == ancestor {
+			return true
+		}
+		parent, ok := parentPID(pid)
+		if !ok {
+			return false
+		}
+		pid = parent
+	}
+	return false
+}
+
+// playerStreams 找出播放器对应的输出流节点：优先按进程号关联（总线名称所属进程及其子进程创建的流），
+// 无法按进程号关联时才按应用名称匹配，避免同一应用的多个实例互相误判
+func playerStreams(p Player) []int {
+	var byPID, byName []int
+	names := []string{p.Identity, p.DesktopEntry, p.ShortName()}
+	for _, node := range registry.Nodes() {
+		props := node.Info.Props
+		if props.MediaClass != "Stream/Output/Audio" {
+			continue
+		}
+		// 沙盒中的进程号与会话总线上看到的不同，改用门户记录的应用 ID 与 DesktopEntry（即 Flatpak 应用 ID）比对
+		if appID := props.PortalAppID.String(); appID != "" && sandboxedPlayer(p, appID) {
+			byPID = append(byPID, node.ID)
+			continue
+		}
+		if p.PID != 0 {
+			if pid, err := strconv.ParseUint(props.ApplicationPID.String(), 10, 32); err == nil && descendsFrom(uint32(pid), p.PID) {
+				byPID = append(byPID, node.ID)
+				continue
+			}
+		}
+		for _, name := range names {
+			if name != "" && (strings.EqualFold(props.ApplicationName.String(), name) || strings.EqualFold(props.ApplicationBinary.String(), name)) {
+				byName = append(byName, node.ID)
+				break
+			}
+		}
+	}
+	if len(byPID) > 0 {
+		return byPID
+	}
+	return byName
+}
+
+// sandboxedPlayer 判断播放器是否属于应用 ID 为 appID 的沙盒应用
+func sandboxedPlayer(p Player, appID string) bool {
+	if strings.EqualFold(p.DesktopEntry, appID) {
+		return true
+	}
+	short := p.ShortName()
+	return strings.EqualFold(short, appID) || strings.HasPrefix(strings.ToLower(short), strings.ToLower(appID)+".")
+}
+
+// streamNames 返回流节点的应用名称，用于在日志与状态中说明具体被处理的应用
+func streamNames(ids []int) []string {
+	var names []string
+	for _, id := range ids {
+		node, ok := registry.Node(id)
+		if !ok {
+			continue
+		}
+		props := node.Info.Props
+		name := props.ApplicationName.String()
+		if name == "" {
+			name = props.NodeName
+		}
+		names = append(names, fmt.Sprintf("%s #%d", name, id))
+	}
+	return names
+}
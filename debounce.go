@@ -0,0 +1,222 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+type transition struct {
+	oldSink string
+	newSink string
+	from    policy.Class
+	to      policy.Class
+	dev     Device
+	nodeID  int
+	reason  string
+	user    bool
+	force   bool
+	// preempt 表示在 PipeWire 切换输出之前抢先处理当前的私有设备（耳机拔出、蓝牙断开），
+	// 实际暂停了播放器时，随后到达的切换事件不再重复暂停
+	preempt bool
+	// targets 为需要静音的节点：为 nil 时按 guard 配置选择，为空切片时不静音任何节点
+	targets []int
+	// initiator 为选择新设备的客户端名称，无法确定时为空
+	initiator string
+}
+
+var (
+	settleMu      sync.Mutex
+	settleTimer   *time.Timer
+	settlePending *transition
+
+	cooldownMu sync.Mutex
+	// 最近一次执行暂停的时间，恢复后清零
+	lastPauseAt time.Time
+)
+
+// inCooldown 判断距离上一次暂停是否仍在 cooldown 内，期间的暂停会被合并，
+// 避免扩展坞依次枚举多个设备时反复静音与取消静音
+func inCooldown() bool {
+	cooldownMu.Lock()
+	defer cooldownMu.Unlock()
+	return config.Cooldown > 0 && time.Since(lastPauseAt) < config.Cooldown
+}
+
+func setLastPause(at time.Time) {
+	cooldownMu.Lock()
+	lastPauseAt = at
+	cooldownMu.Unlock()
+}
+
+func submitTransition(t transition) {
+	if config.SettleWindow <= 0 {
+		applyTransition(t)
+		return
+	}
+
+	settleMu.Lock()
+	defer settleMu.Unlock()
+
+	if settlePending == nil {
+		settlePending = &t
+	} else {
+		// 合并窗口内的连续切换：保留最初的旧设备，以最后一次切换的结果为准
+		zap.L().Debug("合并连续的设备切换", zap.String("reason", t.reason))
+		settlePending.to = t.to
+		settlePending.newSink = t.newSink
+		settlePending.dev = t.dev
+		settlePending.nodeID = t.nodeID
+		settlePending.reason = t.reason
+		settlePending.user = t.user
+		settlePending.force = t.force
+		settlePending.initiator = t.initiator
+	}
+
+	if settleTimer != nil {
+		settleTimer.Stop()
+	}
+	settleTimer = time.AfterFunc(config.SettleWindow, flushTransition)
+}
+
+// markTransitionUser 将仍在合并窗口内、切换到 sink 的变更追认为用户操作
+func markTransitionUser(sink string) {
+	settleMu.Lock()
+	defer settleMu.Unlock()
+
+	if settlePending != nil && settlePending.newSink == sink && !settlePending.user {
+		zap.L().Debug("追认为用户操作", zap.String("sink", sink))
+		settlePending.user = true
+	}
+}
+
+func flushTransition() {
+	defer restoreOnPanic()
+	settleMu.Lock()
+	t := settlePending
+	settlePending = nil
+	settleTimer = nil
+	settleMu.Unlock()
+
+	if t != nil {
+		applyTransition(*t)
+	}
+}
+
+func applyTransition(t transition) {
+	hc := newHookContext(t.nodeID, t.reason, t.dev)
+	entry := HistoryEntry{
+		Trigger:   t.reason,
+		OldSink:   t.oldSink,
+		NewSink:   t.newSink,
+		Device:    DeviceDisplayName(t.dev),
+		Initiator: t.initiator,
+	}
+
+	// 初始化默认设备时没有可比较的旧设备
+	action := policy.None
+	if t.oldSink != "" {
+		action = transitionAction(t.from, t.to, t.oldSink != t.newSink)
+	}
+	if t.force || t.preempt {
+		action = policy.Pause
+	}
+	switch scheduleMode(time.Now()) {
+	case "strict":
+		// 严格时段：从任何非公共设备切换到公共设备都视为需要暂停
+		if action == policy.None && t.to == policy.Public && t.from != policy.Public {
+			action = policy.Pause
+		}
+	case "off":
+		if action == policy.Pause {
+			zap.L().Info("当前时段已停用自动暂停，跳过本次暂停", zap.String("reason", t.reason))
+			entry.Action = "跳过（当前时段已停用）"
+			addHistory(entry)
+			return
+		}
+	}
+
+	mode := effectiveMode()
+	if scripted, ok := scriptAction(t, action); ok {
+		switch scripted {
+		case "ignore":
+			if action != policy.None {
+				entry.Action = "跳过（规则表达式）"
+				addHistory(entry)
+			}
+			return
+		case "resume":
+			action = policy.Resume
+		default:
+			action, mode = policy.Pause, scripted
+			if scripted == "pause" {
+				mode = effectiveMode()
+			}
+		}
+	}
+
+	if action != policy.None && !sessionIsActive() {
+		zap.L().Info("登录会话不在前台，跳过本次处理", zap.String("reason", t.reason))
+		entry.Action = "跳过（会话不在前台）"
+		addHistory(entry)
+		return
+	}
+
+	switch action {
+	case policy.Pause:
+		if !t.preempt {
+			runHook(HookPublicSwitch, hc)
+		}
+		switch {
+		case recentlyPreempted() && !t.preempt:
+			zap.L().Debug("已抢先暂停，跳过本次暂停", zap.String("reason", t.reason))
+			entry.Action = "跳过（已抢先暂停）"
+		case t.user:
+			entry.Action = "跳过（用户操作）"
+		case isSnoozed():
+			entry.Action = "跳过（自动暂停已暂时停用）"
+		case inCooldown():
+			zap.L().Info("距离上一次暂停过近，合并本次暂停", zap.String("reason", t.reason))
+			entry.Action = "跳过（已合并到上一次暂停）"
+		default:
+			entry.Action = mode
+		}
+		id := addHistory(entry)
+		if entry.Action == mode {
+			setLastPause(time.Now())
+			// duck 与 mute-only 只处理了即将失效的私有设备，切换到新设备后仍需按配置处理
+			if t.preempt && (mode == "pause" || mode == "safe-sink") {
+				markPreempted()
+			}
+			applyPolicy(id, mode, t.nodeID, t.targets, t.reason, t.dev)
+		}
+	case policy.Resume:
+		runHook(HookPrivateSwitch, hc)
+		entry.Action = "resume"
+		if t.user {
+			entry.Action = "跳过（用户操作）"
+		}
+		id := addHistory(entry)
+		// 切回私有设备后再次切换到公共设备需要重新暂停
+		setLastPause(time.Time{})
+		releaseStreamPolicy()
+		releasePolicy()
+		if !t.user {
+			zap.L().Info("恢复播放器，触发事件为【" + t.reason + "】")
+			resumeAsync(id, t.nodeID, t.reason, t.dev)
+		}
+	}
+}
+
+func cancelPendingTransition() {
+	settleMu.Lock()
+	defer settleMu.Unlock()
+
+	if settleTimer != nil {
+		settleTimer.Stop()
+		settleTimer = nil
+	}
+	settlePending = nil
+}
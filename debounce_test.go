@@ -0,0 +1,344 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/policy"
+)
+
+// fakeController 记录写入的节点参数，代替 pw-cli 等控制进程
+type fakeController struct {
+	mu    sync.Mutex
+	calls []propsCall
+}
+
+type propsCall struct {
+	id     int
+	values map[string]any
+}
+
+func (c *fakeController) SetNodeProps(nodeID int, values map[string]any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, propsCall{id: nodeID, values: values})
+	return nil
+}
+
+// muted 按顺序返回被静音的节点，mute 为 false 时返回取消静音的节点；
+// 没有静音标志的节点通过将音量设为 0 静音
+func (c *fakeController) muted(mute bool) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []int
+	for _, call := range c.calls {
+		v, ok := call.values["mute"].(bool)
+		if !ok {
+			volumes, ok := call.values["channelVolumes"].([]float64)
+			if !ok {
+				continue
+			}
+			v = !slices.ContainsFunc(volumes, func(f float64) bool { return f != 0 })
+		}
+		if v == mute {
+			ids = append(ids, call.id)
+		}
+	}
+	return ids
+}
+
+func (c *fakeController) reset() {
+	c.mu.Lock()
+	c.calls = nil
+	c.mu.Unlock()
+}
+
+// setupTest 使用默认配置并安装 fakeController，静音只记录写入的参数，不调用 pw-cli 与 D-Bus
+func setupTest(t *testing.T) *fakeController {
+	t.Helper()
+	config = DefaultConfig()
+	config.Mode = "mute-only"
+	config.Fade.Duration = 0
+	config.Resume.Enabled = false
+	config.Notify.Enabled = false
+	dryRun = false
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	fake := &fakeController{}
+	controllerMu.Lock()
+	controller = fake
+	controllerMu.Unlock()
+	registry.Reset()
+	sinkPolicy.Reset()
+	if cancelDelete == nil {
+		// 测试不经过 run，不启动延迟清理
+		cancelDelete = func(int) {}
+	}
+
+	historyMu.Lock()
+	history = nil
+	historyMu.Unlock()
+	mutedMu.Lock()
+	clear(mutedNodes)
+	mutedMu.Unlock()
+	policyMu.Lock()
+	policyMuted = nil
+	policyMu.Unlock()
+	setLastPause(time.Time{})
+	preemptMu.Lock()
+	preemptAt = time.Time{}
+	preemptMu.Unlock()
+	clientsMu.Lock()
+	configuredBy, configuredFor, configuredSeen = initiator{}, "", time.Time{}
+	clientsMu.Unlock()
+	t.Cleanup(releasePolicy)
+	return fake
+}
+
+// lastAction 返回最近一条记录的动作，没有记录时返回空字符串
+func lastAction() string {
+	e, _ := lastHistory()
+	return e.Action
+}
+
+func TestApplyTransition(t *testing.T) {
+	tests := []struct {
+		name string
+		t    transition
+		// guarded 为切换前已按 mute-only 静音了公共设备 51
+		guarded bool
+		muted   []int
+		unmuted []int
+	}{
+		{
+			name:  "私有设备切换到公共设备时处理",
+			t:     transition{oldSink: "headphones", newSink: "speaker", from: policy.Private, to: policy.Public, nodeID: 51},
+			muted: []int{51},
+		},
+		{
+			name: "用户选择的公共设备不处理",
+			t:    transition{oldSink: "headphones", newSink: "speaker", from: policy.Private, to: policy.Public, nodeID: 51, user: true},
+		},
+		{
+			name:    "公共设备切换到私有设备时恢复",
+			t:       transition{oldSink: "speaker", newSink: "headphones", from: policy.Public, to: policy.Private, nodeID: 50},
+			guarded: true,
+			unmuted: []int{51},
+		},
+		{
+			name:    "用户切回私有设备时仍撤销静音",
+			t:       transition{oldSink: "speaker", newSink: "headphones", from: policy.Public, to: policy.Private, nodeID: 50, user: true},
+			guarded: true,
+			unmuted: []int{51},
+		},
+		{
+			name: "同类设备之间切换不处理",
+			t:    transition{oldSink: "headphones", newSink: "headset", from: policy.Private, to: policy.Private, nodeID: 52},
+		},
+		{
+			name: "初始化默认设备时不处理",
+			t:    transition{newSink: "speaker", to: policy.Public, nodeID: 51},
+		},
+		{
+			name:  "私有设备移除后无论新设备类别都处理",
+			t:     transition{oldSink: "headphones", newSink: "unknown", from: policy.Private, to: policy.Unclassified, nodeID: 53, force: true},
+			muted: []int{53},
+		},
+		{
+			name:  "耳机拔出时抢先处理当前设备",
+			t:     transition{oldSink: "headphones", newSink: "headphones", from: policy.Private, to: policy.Private, nodeID: 50, preempt: true},
+			muted: []int{50},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := setupTest(t)
+			if tt.guarded {
+				applyPolicy(0, "mute-only", 51, nil, "测试", Device{})
+				fake.reset()
+			}
+			applyTransition(tt.t)
+			if got := fake.muted(true); !slices.Equal(got, tt.muted) {
+				t.Errorf("静音的节点为 %v，期望 %v", got, tt.muted)
+			}
+			if got := fake.muted(false); !slices.Equal(got, tt.unmuted) {
+				t.Errorf("取消静音的节点为 %v，期望 %v", got, tt.unmuted)
+			}
+		})
+	}
+}
+
+func TestApplyTransitionSuppressed(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		want  string
+	}{
+		{
+			name:  "cooldown 内合并到上一次暂停",
+			setup: func(t *testing.T) { setLastPause(time.Now()) },
+			want:  "跳过（已合并到上一次暂停）",
+		},
+		{
+			name:  "耳机拔出已抢先暂停",
+			setup: func(t *testing.T) { markPreempted() },
+			want:  "跳过（已抢先暂停）",
+		},
+		{
+			name: "自动暂停已停用",
+			setup: func(t *testing.T) {
+				disable()
+				t.Cleanup(func() { snooze(0) })
+			},
+			want: "跳过（自动暂停已暂时停用）",
+		},
+		{
+			name: "登录会话不在前台",
+			setup: func(t *testing.T) {
+				sessionInactive.Store(true)
+				t.Cleanup(func() { sessionInactive.Store(false) })
+			},
+			want: "跳过（会话不在前台）",
+		},
+		{
+			name: "当前时段已停用",
+			setup: func(t *testing.T) {
+				config.ScheduleDefault = "off"
+			},
+			want: "跳过（当前时段已停用）",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := setupTest(t)
+			tt.setup(t)
+			applyTransition(transition{oldSink: "headphones", newSink: "speaker", from: policy.Private, to: policy.Public, nodeID: 51})
+			if got := fake.muted(true); len(got) > 0 {
+				t.Errorf("静音了节点 %v", got)
+			}
+			if got := lastAction(); got != tt.want {
+				t.Errorf("跳过的原因为 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettleMergesTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []transition
+		user  string
+		want  string
+	}{
+		{
+			name: "扩展坞依次枚举多个设备时只处理最终结果",
+			steps: []transition{
+				{oldSink: "headphones", newSink: "dock", from: policy.Private, to: policy.Unclassified},
+				{oldSink: "dock", newSink: "hdmi", from: policy.Unclassified, to: policy.Public},
+			},
+			want: "mute-only",
+		},
+		{
+			name: "合并窗口内切回原设备不处理",
+			steps: []transition{
+				{oldSink: "headphones", newSink: "speaker", from: policy.Private, to: policy.Public},
+				{oldSink: "speaker", newSink: "headphones", from: policy.Public, to: policy.Private},
+			},
+		},
+		{
+			name: "合并窗口内追认的用户操作不处理",
+			steps: []transition{
+				{oldSink: "headphones", newSink: "speaker", from: policy.Private, to: policy.Public},
+			},
+			user: "speaker",
+			want: "跳过（用户操作）",
+		},
+		{
+			name: "追认其他设备时仍然处理",
+			steps: []transition{
+				{oldSink: "headphones", newSink: "speaker", from: policy.Private, to: policy.Public},
+			},
+			user: "hdmi",
+			want: "mute-only",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			// 合并窗口足够长，由测试直接调用 flushTransition
+			config.SettleWindow = time.Hour
+			t.Cleanup(cancelPendingTransition)
+
+			for _, step := range tt.steps {
+				submitTransition(step)
+			}
+			if tt.user != "" {
+				markTransitionUser(tt.user)
+			}
+			if got := lastAction(); got != "" {
+				t.Fatalf("合并窗口结束前已执行动作 %q", got)
+			}
+
+			settleMu.Lock()
+			pending := *settlePending
+			settleMu.Unlock()
+			if first, last := tt.steps[0], tt.steps[len(tt.steps)-1]; pending.oldSink != first.oldSink || pending.from != first.from || pending.newSink != last.newSink || pending.to != last.to {
+				t.Errorf("合并后的切换为 %s(%v) → %s(%v)", pending.oldSink, pending.from, pending.newSink, pending.to)
+			}
+
+			flushTransition()
+			if got := lastAction(); got != tt.want {
+				t.Errorf("动作为 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInCooldown(t *testing.T) {
+	tests := []struct {
+		name      string
+		cooldown  time.Duration
+		lastPause time.Duration
+		want      bool
+	}{
+		{name: "刚刚暂停", cooldown: 3 * time.Second, lastPause: time.Second, want: true},
+		{name: "cooldown 已过", cooldown: 3 * time.Second, lastPause: 4 * time.Second},
+		{name: "cooldown 为 0 时不合并", lastPause: time.Millisecond},
+		{name: "切回私有设备后清零", cooldown: 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			config.Cooldown = tt.cooldown
+			if tt.lastPause > 0 {
+				setLastPause(time.Now().Add(-tt.lastPause))
+			}
+			if got := inCooldown(); got != tt.want {
+				t.Errorf("inCooldown() = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 暂停后 cooldown 内的第二次切换被合并，切回私有设备后再次切换需要重新暂停
+func TestCooldownAcrossTransitions(t *testing.T) {
+	setupTest(t)
+	toPublic := transition{oldSink: "headphones", newSink: "speaker", from: policy.Private, to: policy.Public}
+	toPrivate := transition{oldSink: "speaker", newSink: "headphones", from: policy.Public, to: policy.Private}
+
+	for i, step := range []struct {
+		t    transition
+		want string
+	}{
+		{toPublic, "mute-only"},
+		{toPublic, "跳过（已合并到上一次暂停）"},
+		{toPrivate, "resume"},
+		{toPublic, "mute-only"},
+	} {
+		applyTransition(step.t)
+		if got := lastAction(); got != step.want {
+			t.Errorf("第 %d 次切换的动作为 %q，期望 %q", i+1, got, step.want)
+		}
+	}
+}
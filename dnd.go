@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	notificationsName = "org.freedesktop.Notifications"
+	notificationsPath = dbus.ObjectPath("/org/freedesktop/Notifications")
+)
+
+var (
+	// GNOME 通过关闭 show-banners 开启勿扰模式，KDE 通过通知服务的 Inhibited 属性
+	dndGnome atomic.Bool
+	dndKDE   atomic.Bool
+)
+
+func isDND() bool {
+	return config.DND.Enabled && (dndGnome.Load() || dndKDE.Load())
+}
+
+// dndPolicy 判断勿扰模式是否开启且 [dnd] policy 为 policy
+func dndPolicy(policy string) bool {
+	return config.DND.Policy == policy && isDND()
+}
+
+func setDND(flag *atomic.Bool, on bool, source string) {
+	if flag.Swap(on) == on {
+		return
+	}
+	if on {
+		zap.L().Info("勿扰模式已开启", zap.String("source", source))
+	} else {
+		zap.L().Info("勿扰模式已关闭", zap.String("source", source))
+	}
+}
+
+// startDNDMonitor 通过 gsettings monitor 跟踪 GNOME 的勿扰模式
+func startDNDMonitor(ctx context.Context) {
+	if !config.DND.Enabled {
+		return
+	}
+	const schema, key = "org.gnome.desktop.notifications", "show-banners"
+
+	if out, err := exec.CommandContext(ctx, "gsettings", "get", schema, key).Output(); err == nil {
+		setDND(&dndGnome, strings.TrimSpace(string(out)) == "false", "gsettings")
+	} else {
+		zap.L().Debug("无法读取 GNOME 勿扰模式状态", zap.Error(err))
+		return
+	}
+
+	cmd := exec.CommandContext(ctx, "gsettings", "monitor", schema, key)
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		zap.L().Debug("无法监听 GNOME 勿扰模式状态", zap.Error(err))
+		return
+	}
+
+	go func() {
+		defer cmd.Wait()
+		// 每行形如 show-banners: false
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			_, value, ok := strings.Cut(scanner.Text(), ":")
+			if ok {
+				setDND(&dndGnome, strings.TrimSpace(value) == "false", "gsettings")
+			}
+		}
+	}()
+}
+
+// watchNotificationInhibition 跟踪 KDE 通知服务的 Inhibited 属性
+func watchNotificationInhibition() {
+	conn := sessionBus()
+	if !config.DND.Enabled || conn == nil {
+		return
+	}
+
+	obj := conn.Object(notificationsName, notificationsPath)
+	if v, err := obj.GetProperty(notificationsName + ".Inhibited"); err == nil {
+		inhibited, _ := v.Value().(bool)
+		setDND(&dndKDE, inhibited, "notifications")
+	} else {
+		zap.L().Debug("通知服务不支持 Inhibited 属性", zap.Error(err))
+		return
+	}
+
+	err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchObjectPath(notificationsPath),
+		dbus.WithMatchArg0Namespace(notificationsName),
+	)
+	if err != nil {
+		zap.L().Warn("无法订阅勿扰模式状态变化", zap.Error(err))
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	go func() {
+		for sig := range signals {
+			if sig.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" || sig.Path != notificationsPath || len(sig.Body) < 2 {
+				continue
+			}
+			changed, _ := sig.Body[1].(map[string]dbus.Variant)
+			if v, ok := changed["Inhibited"].Value().(bool); ok {
+				setDND(&dndKDE, v, "notifications")
+			}
+		}
+	}()
+}
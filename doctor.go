@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/nsplup/pw-autopaused/pkg/pwmon"
+	"github.com/nsplup/pw-autopaused/policy"
+)
+
+type doctorReport struct {
+	failed bool
+}
+
+func (r *doctorReport) ok(format string, args ...any) {
+	fmt.Printf("[正常] "+format+"\n", args...)
+}
+
+func (r *doctorReport) warn(format string, args ...any) {
+	fmt.Printf("[警告] "+format+"\n", args...)
+}
+
+func (r *doctorReport) fail(format string, args ...any) {
+	r.failed = true
+	fmt.Printf("[错误] "+format+"\n", args...)
+}
+
+// commandVersion 返回命令 --version 输出的第一行非空内容
+func commandVersion(ctx context.Context, name string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", err
+	}
+	out, err := exec.CommandContext(ctx, name, "--version").CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+	}
+	return "", nil
+}
+
+// doctorSnapshot 获取一次完整的对象快照，与当前后端使用的数据来源一致
+func doctorSnapshot(ctx context.Context) ([]json.RawMessage, error) {
+	if config.Backend == "pulse" {
+		b := &pulseBackend{}
+		raws, ids, err := b.snapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		objects := make([]json.RawMessage, 0, len(ids))
+		for _, id := range ids {
+			objects = append(objects, raws[id])
+		}
+		return objects, nil
+	}
+
+	return pwmon.Probe(ctx).Snapshot(ctx)
+}
+
+// runDoctor 检查运行环境并列出设备的分类结果及其依据，便于排查问题
+func runDoctor() int {
+	r := &doctorReport{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if conf, err := LoadConfig(ConfigPaths()...); err != nil {
+		r.fail("配置文件有误：%v", err)
+	} else {
+		config = conf
+		loadDeviceChoices()
+		r.ok("配置文件：%s（后端 %s，处理方式 %s）", strings.Join(ConfigPaths(), "、"), config.Backend, config.Mode)
+	}
+
+	tools := []string{"pw-dump"}
+	switch method := controlMethod(); {
+	case config.Backend == "pulse":
+		tools = []string{"pactl"}
+	case config.Backend == "native":
+	case method == "native":
+		r.ok("控制方式：PipeWire 原生协议")
+	default:
+		tools = append(tools, method)
+	}
+	for _, name := range tools {
+		if version, err := commandVersion(ctx, name); err != nil {
+			r.fail("%s 不可用：%v", name, err)
+		} else {
+			r.ok("%s：%s", name, version)
+		}
+	}
+	if config.Backend != "pulse" && config.Backend != "native" {
+		if c := pwmon.Probe(ctx); c.Version == (pwmon.Version{}) {
+			r.warn("无法识别 pw-dump 所链接的 libpipewire 版本")
+		} else if !c.NoColors {
+			r.ok("pw-dump 链接 libpipewire %s（不支持 --no-colors，按旧版本的参数调用）", c.Version)
+		} else {
+			r.ok("pw-dump 链接 libpipewire %s", c.Version)
+		}
+	}
+
+	if conn, err := dbus.SessionBus(); err != nil {
+		r.fail("无法连接会话总线：%v", err)
+	} else {
+		r.ok("会话总线可用")
+		var owner string
+		if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetNameOwner", 0, serviceName).Store(&owner); err != nil {
+			r.warn("守护进程未运行（未找到 %s）", serviceName)
+		} else {
+			r.ok("守护进程正在运行（%s）", owner)
+		}
+	}
+
+	objects, err := doctorSnapshot(ctx)
+	if err != nil {
+		r.fail("无法获取设备列表：%v", err)
+		return 1
+	}
+
+	var devices []Device
+	for _, ev := range pwmon.Decode(objects) {
+		if ev.Type == pwmon.DeviceChanged {
+			devices = append(devices, ev.Device)
+		}
+	}
+	if n, err := pwmon.DecodeFailures(); n > 0 {
+		r.warn("有 %d 个对象无法解析，pw-dump 的输出格式可能不受支持：%v", n, err)
+	}
+
+	fmt.Printf("\n检测到 %d 个设备：\n", len(devices))
+	names := map[policy.Class]string{policy.Public: "公共设备", policy.Private: "私有设备", policy.Unclassified: "未分类"}
+	for _, dev := range devices {
+		fmt.Printf("\n#%d %s（%s）\n", dev.ID, DeviceDisplayName(dev), dev.Info.Props.DeviceName)
+		if profiles := dev.Info.Params.Profile; len(profiles) > 0 {
+			fmt.Printf("  配置文件：%s\n", profiles[0].Name)
+		}
+		if len(dev.Info.Params.Route) == 0 {
+			fmt.Println("  没有活动路由，无法分类")
+		}
+		for _, d := range []struct {
+			direction string
+			label     string
+			class     func(Device) policy.Class
+		}{
+			{"output", "输出", sinkClass},
+			{"input", "输入", sourceClass},
+		} {
+			route, ok := ActiveRoute(dev, d.direction)
+			if !ok {
+				continue
+			}
+			class := d.class(dev)
+			fmt.Printf("  %s：%s  路由 %s（%s），port.type=%q\n", d.label, names[class], route.Name, route.Description, route.PortType())
+			if class == policy.Unclassified {
+				r.warn("设备 #%d 的%s路由既不属于公共设备也不属于私有设备，可通过 [classify] 配置指定", dev.ID, d.label)
+			}
+		}
+		if dev.Info.Props.BluezAddress != "" {
+			fmt.Printf("  蓝牙地址：%s\n", dev.Info.Props.BluezAddress)
+		}
+	}
+
+	if r.failed {
+		return 1
+	}
+	return 0
+}
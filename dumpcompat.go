@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/pkg/pwmon"
+	"go.uber.org/zap"
+)
+
+var (
+	dumpCompat     atomic.Pointer[pwmon.Compat]
+	decodeReported atomic.Uint64
+)
+
+// probeDumpCompat 检测 pw-dump 的版本与支持的参数；每次启动监听进程前重新检测，发行版升级 PipeWire 后无需重启
+func probeDumpCompat(ctx context.Context) *pwmon.Compat {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	c := pwmon.Probe(ctx)
+	dumpCompat.Store(&c)
+	zap.L().Info("pw-dump 版本", zap.String("libpipewire", c.Version.String()), zap.Bool("no_colors", c.NoColors))
+	return &c
+}
+
+func currentDumpCompat() pwmon.Compat {
+	if c := dumpCompat.Load(); c != nil {
+		return *c
+	}
+	return pwmon.DefaultCompat
+}
+
+// reportDecodeFailures 在出现无法解析的对象时发出警告，避免输出格式变化后设备分类悄然失效
+func reportDecodeFailures() {
+	n, err := pwmon.DecodeFailures()
+	if prev := decodeReported.Swap(n); n > prev {
+		zap.L().Warn("部分 pw-dump 输出无法解析，设备分类可能不完整",
+			zap.Uint64("dropped", n-prev), zap.String("libpipewire", currentDumpCompat().Version.String()), zap.Error(err))
+	}
+}
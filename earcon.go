@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// playEarcon 在自动暂停后通过公共设备以较低音量播放一段提示音，让房间里的人知道播放已被拦截；
+// 使用 pw-play，pulse 后端使用 paplay
+func playEarcon(nodeID int) {
+	if !config.Earcon.Enabled || config.Earcon.File == "" {
+		return
+	}
+	target := nodeNameByID(nodeID)
+	if target == "" {
+		target = strconv.Itoa(nodeID)
+	}
+	if dryRun {
+		zap.L().Info("[dry-run] 将播放提示音", zap.String("sink", target), zap.String("file", config.Earcon.File))
+		return
+	}
+
+	name, args := earconCommand(config.Backend, target, config.Earcon.Volume, config.Earcon.File)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if out, err := exec.CommandContext(ctx, name, args...).CombinedOutput(); err != nil {
+			zap.L().Warn("播放提示音失败", zap.String("sink", target), zap.ByteString("output", out), zap.Error(err))
+		}
+	}()
+}
+
+// earconCommand 返回播放提示音的命令与参数。volume 为线性音量（振幅的倍数，0 到 1）：
+// pw-play 的 --volume 同为线性音量，直接传入；paplay 的 --volume 为 PulseAudio 的音量值，
+// 65536（PA_VOLUME_NORM）对应 100%，与线性音量之间为立方关系
+func earconCommand(backend, target string, volume float64, file string) (string, []string) {
+	if backend == "pulse" {
+		paVolume := int(math.Round(math.Cbrt(volume) * 65536))
+		return "paplay", []string{"--device=" + target, fmt.Sprintf("--volume=%d", paVolume), file}
+	}
+	return "pw-play", []string{"--target", target, "--media-role", "Notification",
+		"--volume", strconv.FormatFloat(volume, 'f', -1, 64), file}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEarconCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		volume  float64
+		want    []string
+	}{
+		{name: "pw-play 使用线性音量", backend: "pw-dump", volume: 0.3, want: []string{"pw-play", "--target", "speaker", "--media-role", "Notification", "--volume", "0.3", "a.oga"}},
+		{name: "pw-play 保留较小的音量", backend: "native", volume: 0.005, want: []string{"pw-play", "--target", "speaker", "--media-role", "Notification", "--volume", "0.005", "a.oga"}},
+		{name: "paplay 换算为立方音量", backend: "pulse", volume: 0.125, want: []string{"paplay", "--device=speaker", "--volume=32768", "a.oga"}},
+		{name: "paplay 满音量", backend: "pulse", volume: 1, want: []string{"paplay", "--device=speaker", "--volume=65536", "a.oga"}},
+		{name: "paplay 静音", backend: "pulse", volume: 0, want: []string{"paplay", "--device=speaker", "--volume=0", "a.oga"}},
+		{name: "paplay 默认音量", backend: "pulse", volume: 0.3, want: []string{"paplay", "--device=speaker", "--volume=43872", "a.oga"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := earconCommand(tt.backend, "speaker", tt.volume, "a.oga")
+			if got := append([]string{name}, args...); !slices.Equal(got, tt.want) {
+				t.Errorf("命令为 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// events 为 --events-json 的输出队列，为 nil 时不输出事件
+var events chan []byte
+
+// openEvents 打开事件输出：- 表示标准输出，FIFO 以读写方式打开以免在没有读取方时阻塞
+func openEvents(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeNamedPipe != 0 {
+		return os.OpenFile(path, os.O_RDWR, 0)
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+}
+
+func startEvents(w io.Writer) {
+	events = make(chan []byte, 64)
+	go func() {
+		for line := range events {
+			if _, err := w.Write(line); err != nil {
+				zap.L().Debug("写入事件输出失败", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// emitEvent 输出一行 JSON 事件，队列已满（如读取方停止读取）时丢弃
+func emitEvent(event string, fields map[string]any) {
+	if events == nil {
+		return
+	}
+	out := map[string]any{"event": event, "time": time.Now().Format(time.RFC3339)}
+	for k, v := range fields {
+		out[k] = v
+	}
+	line, err := json.Marshal(out)
+	if err != nil {
+		return
+	}
+	select {
+	case events <- append(line, '\n'):
+	default:
+		zap.L().Debug("事件输出队列已满，丢弃事件", zap.String("event", event))
+	}
+}
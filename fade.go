@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+func fadeEnabled() bool {
+	return config.Fade.Duration > 0 && config.Fade.Step > 0 && !dryRun
+}
+
+// rampVolumes 在 config.Fade.Duration 内将节点音量从 from 逐步调整到 to，
+// 按立方根（即音量界面显示的百分比）插值，使听感上的变化均匀
+func rampVolumes(nodeID int, from, to []float64) {
+	steps := int(config.Fade.Duration / config.Fade.Step)
+	if steps < 1 {
+		steps = 1
+	}
+
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		volumes := make([]float64, len(to))
+		for c := range volumes {
+			start := 0.0
+			if c < len(from) {
+				start = math.Cbrt(from[c])
+			}
+			v := start + (math.Cbrt(to[c])-start)*t
+			volumes[c] = v * v * v
+		}
+		setNodeParams(nodeID, map[string]any{"channelVolumes": volumes})
+		if i < steps {
+			time.Sleep(config.Fade.Step)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+// guard = "gate" 时本程序通过 pw-loopback 创建一个防护 sink 并将其设为默认输出，
+// 再自行将其输出连接到实际的硬件 sink；需要暂停时直接断开该连接，播放器确认暂停后再重新连接，
+// 声音在任何时刻都不会从新的公共设备外放
+var (
+	gateMu      sync.Mutex
+	gateHolds   int
+	gateKept    int
+	gateTarget  string
+	gateTargetN int
+	gateTargetC policy.Class
+	// gateLinkedTo 为防护输出当前实际连接（或正在连接）的目标设备，由 setGateLinkedLocked 更新
+	gateLinkedTo string
+	// gateUnlinked 为等待防护输出断开的 holdGate，断开后关闭
+	gateUnlinked []chan struct{}
+
+	// gateSync 通知 runGateWorker 按最新状态更新连接；pw-link 可能耗时数秒，不在持有 gateMu 时运行
+	gateSync = make(chan struct{}, 1)
+)
+
+func gateEnabled() bool {
+	return config.Guard == "gate" && config.Backend != "pulse"
+}
+
+func gateOutputName() string {
+	return config.Gate.Name + ".output"
+}
+
+// isGateNode 判断 nodeID 是否为防护 sink
+func isGateNode(nodeID int) bool {
+	if !gateEnabled() {
+		return false
+	}
+	id, ok := GetSinkIDByName(config.Gate.Name)
+	return ok && id == nodeID
+}
+
+// startGate 运行 pw-loopback 提供防护 sink，进程退出后自动重新启动
+func startGate(ctx context.Context) {
+	if !gateEnabled() {
+		return
+	}
+	go runGateWorker(ctx)
+	go func() {
+		for ctx.Err() == nil {
+			cmd := exec.CommandContext(ctx, "pw-loopback",
+				"--name", config.Gate.Name,
+				"--capture-props", fmt.Sprintf("media.class=Audio/Sink node.name=%s node.description=\"pw-autopaused 防护输出\"", config.Gate.Name),
+				// 输出端不由会话管理器连接，目标设备消失时也不会被自动移到其他设备上
+				"--playback-props", fmt.Sprintf("node.name=%s node.autoconnect=false node.dont-reconnect=true node.passive=true", gateOutputName()))
+			zap.L().Info("正在启动防护输出...", zap.String("name", config.Gate.Name))
+			if err := cmd.Start(); err != nil {
+				zap.L().Error("无法启动 pw-loopback，防护输出不可用", zap.Error(err))
+				return
+			}
+			go activateGate(ctx)
+			err := cmd.Wait()
+			if ctx.Err() != nil {
+				return
+			}
+			zap.L().Warn("防护输出进程已退出，稍后重新启动", zap.Error(err))
+			gateMu.Lock()
+			setGateLinkedLocked("")
+			gateMu.Unlock()
+			select {
+			case <-ctx.Done():
+			case <-time.After(2 * time.Second):
+			}
+		}
+	}()
+}
+
+// activateGate 等待防护 sink 出现后将其输出连接到当前默认设备，并把防护 sink 设为默认输出
+func activateGate(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	for {
+		_, sinkOK := GetSinkIDByName(config.Gate.Name)
+		_, outOK := GetNodeIDByName(gateOutputName())
+		if sinkOK && outOK {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			zap.L().Warn("等待防护输出节点超时")
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if sink := sinkPolicy.Sink(); sink != "" && sink != config.Gate.Name {
+		setGateTarget(sink)
+	} else {
+		setGateTarget(gateTargetName())
+	}
+	if err := setConfiguredSink(ctx, config.Gate.Name); err != nil {
+		zap.L().Warn("无法将防护输出设为默认输出设备", zap.Error(err))
+	}
+}
+
+func gateTargetName() string {
+	gateMu.Lock()
+	defer gateMu.Unlock()
+	return gateTarget
+}
+
+// noteDefaultForGate 处理默认输出变为防护 sink 以外的设备（用户在设置中选择、或防护 sink 尚未就绪）：
+// 将其作为防护 sink 新的输出目标，并把默认输出设回防护 sink
+func noteDefaultForGate(sink string) {
+	if !gateEnabled() || sink == config.Gate.Name || sink == config.SafeSink.Name {
+		return
+	}
+	setGateTarget(sink)
+	if _, ok := GetSinkIDByName(config.Gate.Name); !ok {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := setConfiguredSink(ctx, config.Gate.Name); err != nil {
+			zap.L().Warn("无法将防护输出设为默认输出设备", zap.Error(err))
+		}
+	}()
+}
+
+// setGateTarget 更新防护 sink 的输出目标；没有正在进行的暂停时由 runGateWorker 重新连接
+func setGateTarget(sink string) {
+	_, class := classifyNode(sink, GetSinkIDByName, sinkClass)
+	id, _ := GetSinkIDByName(sink)
+
+	gateMu.Lock()
+	gateTarget, gateTargetN, gateTargetC = sink, id, class
+	gateMu.Unlock()
+	requestGateSync()
+}
+
+func requestGateSync() {
+	select {
+	case gateSync <- struct{}{}:
+	default:
+	}
+}
+
+// setGateLinkedLocked 记录防护输出的连接状态，断开时通知等待中的 holdGate；调用方需持有 gateMu
+func setGateLinkedLocked(target string) {
+	gateLinkedTo = target
+	if target != "" {
+		return
+	}
+	for _, ch := range gateUnlinked {
+		close(ch)
+	}
+	gateUnlinked = nil
+}
+
+// runGateWorker 依次将防护输出的连接更新为期望的状态：没有正在进行的暂停时连接到目标设备，否则保持断开
+func runGateWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gateSync:
+		}
+
+		gateMu.Lock()
+		var want string
+		if gateHolds == 0 {
+			want = gateTarget
+		}
+		if want == gateLinkedTo {
+			gateMu.Unlock()
+			continue
+		}
+		// 连接完成前 holdGate 也需要等待之后的断开
+		if want != "" {
+			gateLinkedTo = want
+		}
+		gateMu.Unlock()
+
+		unlinkGate()
+		linked := ""
+		if want != "" && linkGate(want) {
+			linked = want
+		}
+		gateMu.Lock()
+		setGateLinkedLocked(linked)
+		if linked != "" && gateHolds > 0 {
+			// 连接期间开始了新的暂停
+			requestGateSync()
+		}
+		gateMu.Unlock()
+	}
+}
+
+// linkGate 将防护 sink 的输出连接到目标设备
+func linkGate(target string) bool {
+	if dryRun {
+		zap.L().Info("[dry-run] 将连接防护输出", zap.String("target", target))
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, "pw-link", gateOutputName(), target).CombinedOutput(); err != nil {
+		zap.L().Warn("无法连接防护输出", zap.String("target", target), zap.Error(err), zap.ByteString("output", out))
+		return false
+	}
+	zap.L().Debug("已连接防护输出", zap.String("target", target))
+	return true
+}
+
+// unlinkGate 断开防护 sink 输出的所有连接
+func unlinkGate() {
+	outID, ok := GetNodeIDByName(gateOutputName())
+	if !ok {
+		return
+	}
+	for _, link := range registry.LinksFrom(outID) {
+		if dryRun {
+			zap.L().Info("[dry-run] 将断开防护输出", zap.Int("link", link))
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if out, err := exec.CommandContext(ctx, "pw-link", "-d", strconv.Itoa(link)).CombinedOutput(); err != nil {
+			// 连接可能已随目标设备一起被移除
+			zap.L().Debug("无法断开防护输出", zap.Int("link", link), zap.Error(err), zap.ByteString("output", out))
+		}
+		cancel()
+	}
+}
+
+// gateUnlinkTimeout 为 holdGate 等待防护输出断开的最长时间
+const gateUnlinkTimeout = time.Second
+
+// holdGate 在暂停期间断开防护输出，多个暂停重叠时只在全部结束后才重新连接；
+// 等待 runGateWorker 确认断开后返回 true，超时未确认时返回 false，调用方应改为静音
+func holdGate() bool {
+	gateMu.Lock()
+	gateHolds++
+	if gateHolds == 1 {
+		zap.L().Info("断开防护输出", zap.String("target", gateTarget))
+	}
+	if gateLinkedTo == "" {
+		gateMu.Unlock()
+		return true
+	}
+	unlinked := make(chan struct{})
+	gateUnlinked = append(gateUnlinked, unlinked)
+	gateMu.Unlock()
+	requestGateSync()
+
+	select {
+	case <-unlinked:
+		return true
+	case <-time.After(gateUnlinkTimeout):
+		zap.L().Warn("等待断开防护输出超时")
+		return false
+	}
+}
+
+func releaseGate() {
+	gateMu.Lock()
+	defer gateMu.Unlock()
+	if gateHolds == 0 {
+		return
+	}
+	gateHolds--
+	if gateHolds == 0 {
+		zap.L().Info("重新连接防护输出", zap.String("target", gateTarget))
+		requestGateSync()
+	}
+}
+
+// keepGate 将一次暂停的断开延续到 releaseKeptGate，用于播放器拒绝暂停时保持无声
+func keepGate() {
+	gateMu.Lock()
+	gateKept++
+	gateMu.Unlock()
+}
+
+func releaseKeptGate() {
+	gateMu.Lock()
+	kept := gateKept
+	gateKept = 0
+	gateMu.Unlock()
+	for range kept {
+		releaseGate()
+	}
+}
+
+// pickGateTarget 在 exclude 以外的硬件 sink 中选出 priority.session 最高的一个，作为目标设备消失后的新目标
+func pickGateTarget(exclude int) (Node, bool) {
+	var (
+		best     Node
+		bestPrio = -1
+	)
+	for _, node := range registry.Nodes() {
+		props := node.Info.Props
+		if node.ID == exclude || props.MediaClass != "Audio/Sink" || props.NodeName == config.Gate.Name || props.NodeName == config.SafeSink.Name || isVirtualNode(node) {
+			continue
+		}
+		prio, _ := strconv.Atoi(props.PrioritySession.String())
+		if prio > bestPrio {
+			best, bestPrio = node, prio
+		}
+	}
+	return best, bestPrio >= 0
+}
+
+// handleGateTargetRemoved 在防护输出的目标设备被移除时选择新的目标：此时输出已随设备断开，
+// 先保持断开直到决定是否需要暂停，私有设备被移除时一律暂停后再连接到新设备
+func handleGateTargetRemoved(id int) {
+	if !gateEnabled() {
+		return
+	}
+	gateMu.Lock()
+	target, targetID, from := gateTarget, gateTargetN, gateTargetC
+	gateMu.Unlock()
+	if target == "" || id != targetID {
+		return
+	}
+	gateID, ok := GetSinkIDByName(config.Gate.Name)
+	if !ok {
+		return
+	}
+
+	next, ok := pickGateTarget(id)
+	if !ok {
+		zap.L().Warn("防护输出的目标设备已移除，没有其他可用的输出设备", zap.String("sink", target))
+		return
+	}
+	dev, to := classifyNode(next.Info.Props.NodeName, GetSinkIDByName, sinkClass)
+	zap.L().Info("防护输出的目标设备已移除", zap.String("sink", target), zap.String("next", next.Info.Props.NodeName))
+
+	holdGate()
+	setGateTarget(next.Info.Props.NodeName)
+	d := sinkPolicy.Handle(policy.Event{Type: policy.RouteChanged, Class: to})
+	go notifyStateChanged()
+	t := transition{oldSink: config.Gate.Name, newSink: config.Gate.Name, from: from, to: d.To, dev: dev, nodeID: gateID, reason: "输出设备变更"}
+	if from == policy.Private {
+		t.reason, t.force = "私有设备移除", true
+	}
+	submitTransition(t)
+	// 暂停会自行断开与重新连接，这里的断开只需覆盖到合并窗口结束
+	time.AfterFunc(config.SettleWindow+500*time.Millisecond, releaseGate)
+}
+
+// stopGate 在退出前把默认输出设回防护输出的目标设备，避免防护 sink 消失后由会话管理器任意选择
+func stopGate() {
+	if !gateEnabled() {
+		return
+	}
+	if target := gateTargetName(); target != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := setConfiguredSink(ctx, target); err != nil {
+			zap.L().Warn("无法恢复默认输出设备", zap.String("sink", target), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// setupGateTest 模拟防护输出已连接到 speaker
+func setupGateTest(t *testing.T) {
+	t.Helper()
+	setupTest(t)
+	config.Guard = "gate"
+	gateMu.Lock()
+	gateHolds, gateKept = 0, 0
+	gateTarget, gateLinkedTo, gateUnlinked = "speaker", "speaker", nil
+	gateMu.Unlock()
+	select {
+	case <-gateSync:
+	default:
+	}
+}
+
+func TestHoldGateWaitsForUnlink(t *testing.T) {
+	setupGateTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runGateWorker(ctx)
+
+	if !holdGate() {
+		t.Fatal("断开防护输出后 holdGate 返回 false")
+	}
+	gateMu.Lock()
+	linked := gateLinkedTo
+	gateMu.Unlock()
+	if linked != "" {
+		t.Errorf("holdGate 返回时防护输出仍连接到 %q", linked)
+	}
+}
+
+func TestHoldGateTimeout(t *testing.T) {
+	setupGateTest(t)
+	// 没有运行 runGateWorker，断开无法确认
+	if holdGate() {
+		t.Error("未确认断开时 holdGate 返回 true")
+	}
+}
@@ -3,6 +3,8 @@ module github.com/nsplup/pw-autopaused
 go 1.25.5
 
 require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/expr-lang/expr v1.17.8
 	github.com/godbus/dbus/v5 v5.2.2
 	go.uber.org/zap v1.27.1
 )
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var displayPorts = []string{"hdmi", "displayport"}
+
+var (
+	hotplugMu  sync.Mutex
+	hotplugged = make(map[int]time.Time)
+)
+
+// isDisplayDevice 判断设备是否为 HDMI/DisplayPort 输出设备
+func isDisplayDevice(dev Device) bool {
+	for _, routes := range [][]RouteInfo{dev.Info.Params.Route, dev.Info.Params.EnumRoute} {
+		for _, r := range routes {
+			if strings.EqualFold(r.Direction, "output") && routeMatches(r, displayPorts) {
+				return true
+			}
+		}
+	}
+	props := dev.Info.Props
+	name := strings.ToLower(props.DeviceName + " " + props.DeviceDescription)
+	for _, kw := range displayPorts {
+		if strings.Contains(name, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// noteDeviceAppearance 记录新出现的 HDMI/DisplayPort 设备，需在写入缓存之前调用
+func noteDeviceAppearance(dev Device) {
+	if !config.HDMI.PauseOnHotplug || sinkPolicy.Sink() == "" {
+		// 初始同步阶段出现的设备不算热插拔
+		return
+	}
+	if _, exists := registry.Device(dev.ID); exists || !isDisplayDevice(dev) {
+		return
+	}
+
+	zap.L().Debug("检测到 HDMI/DisplayPort 设备接入", zap.Int("device", dev.ID), zap.String("name", DeviceDisplayName(dev)))
+	hotplugMu.Lock()
+	defer hotplugMu.Unlock()
+	for id, at := range hotplugged {
+		if time.Since(at) > 10*time.Second {
+			delete(hotplugged, id)
+		}
+	}
+	hotplugged[dev.ID] = time.Now()
+}
+
+// hotpluggedDisplay 判断 nodeName 所属的设备是否为刚刚接入的 HDMI/DisplayPort 设备
+func hotpluggedDisplay(nodeName string) bool {
+	devID, ok := GetDeviceIDBySinkName(nodeName)
+	if !ok {
+		return false
+	}
+
+	hotplugMu.Lock()
+	defer hotplugMu.Unlock()
+	at, ok := hotplugged[devID]
+	return ok && time.Since(at) <= 10*time.Second
+}
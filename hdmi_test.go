@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const (
+	builtinDevice = `{"id":40,"info":{"props":{"device.name":"alsa_card.pci-0000_00_1f.3","device.description":"Built-in Audio"},
+		"params":{"Route":[{"index":3,"name":"analog-output-headphones","direction":"Output","available":"yes","info":[1,"port.type","headphones"]}]}}}`
+	builtinSink = `{"id":50,"info":{"props":{"node.name":"alsa_out","media.class":"Audio/Sink","device.id":40}}}`
+	usbDevice   = `{"id":41,"info":{"props":{"device.name":"alsa_card.usb-speaker","device.description":"USB Speaker"},
+		"params":{"Route":[{"index":1,"name":"analog-output-speaker","direction":"Output","available":"yes","info":[1,"port.type","speaker"]}]}}}`
+	usbSink    = `{"id":51,"info":{"props":{"node.name":"usb_out","media.class":"Audio/Sink","device.id":41}}}`
+	hdmiDevice = `{"id":42,"info":{"props":{"device.name":"alsa_card.pci-0000_01_00.1","device.description":"HDA NVidia"},
+		"params":{"Route":[{"index":0,"name":"hdmi-output-0","direction":"Output","available":"yes","info":[1,"port.type","hdmi"]}]}}}`
+	hdmiSink = `{"id":52,"info":{"props":{"node.name":"hdmi_out","media.class":"Audio/Sink","device.id":42}}}`
+)
+
+func putDevice(t *testing.T, data string) {
+	t.Helper()
+	var dev Device
+	if err := json.Unmarshal([]byte(data), &dev); err != nil {
+		t.Fatal(err)
+	}
+	onDeviceUpdate(dev)
+}
+
+func putNode(t *testing.T, data string) {
+	t.Helper()
+	var node Node
+	if err := json.Unmarshal([]byte(data), &node); err != nil {
+		t.Fatal(err)
+	}
+	onNodeUpdate(node)
+}
+
+func setMetadata(key, sink string) {
+	handleDefaultSinkChange([]MetadataEntry{{Key: key, Value: map[string]any{"name": sink}}})
+}
+
+// setupHDMITest 以耳机为默认输出完成初始化；present 为 true 时 HDMI 设备在初始化前已经存在
+func setupHDMITest(t *testing.T, present bool) {
+	t.Helper()
+	setupTest(t)
+	config.HDMI.PauseOnHotplug = true
+	hotplugMu.Lock()
+	clear(hotplugged)
+	hotplugMu.Unlock()
+
+	putDevice(t, builtinDevice)
+	putNode(t, builtinSink)
+	putDevice(t, usbDevice)
+	putNode(t, usbSink)
+	if present {
+		putDevice(t, hdmiDevice)
+		putNode(t, hdmiSink)
+	}
+	setMetadata("default.audio.sink", "alsa_out")
+}
+
+func plugHDMI(t *testing.T) {
+	t.Helper()
+	putDevice(t, hdmiDevice)
+	putNode(t, hdmiSink)
+}
+
+func TestHDMIHotplugWithPendingSelection(t *testing.T) {
+	tests := []struct {
+		name        string
+		present     bool
+		run         func(t *testing.T)
+		wantTrigger string
+		wantAction  string
+	}{
+		{
+			name: "用户选择其他设备尚未生效时接入 HDMI",
+			run: func(t *testing.T) {
+				setMetadata("default.configured.audio.sink", "usb_out")
+				plugHDMI(t)
+				setMetadata("default.audio.sink", "hdmi_out")
+			},
+			wantTrigger: "HDMI 设备接入",
+			wantAction:  "mute-only",
+		},
+		{
+			name: "用户选择生效后接入 HDMI",
+			run: func(t *testing.T) {
+				setMetadata("default.configured.audio.sink", "usb_out")
+				setMetadata("default.audio.sink", "usb_out")
+				plugHDMI(t)
+				setMetadata("default.audio.sink", "hdmi_out")
+			},
+			wantTrigger: "HDMI 设备接入",
+			wantAction:  "mute-only",
+		},
+		{
+			name: "接入 HDMI 后会话管理器未切换默认设备",
+			run: func(t *testing.T) {
+				setMetadata("default.configured.audio.sink", "usb_out")
+				plugHDMI(t)
+			},
+		},
+		{
+			name:    "用户选择已存在的 HDMI 设备",
+			present: true,
+			run: func(t *testing.T) {
+				setMetadata("default.configured.audio.sink", "hdmi_out")
+				setMetadata("default.audio.sink", "hdmi_out")
+			},
+			wantTrigger: "输出设备变更",
+			wantAction:  "跳过（用户操作）",
+		},
+		{
+			name: "接入超过 10 秒后的切换不视为热插拔",
+			run: func(t *testing.T) {
+				plugHDMI(t)
+				hotplugMu.Lock()
+				hotplugged[42] = time.Now().Add(-11 * time.Second)
+				hotplugMu.Unlock()
+				setMetadata("default.configured.audio.sink", "hdmi_out")
+				setMetadata("default.audio.sink", "hdmi_out")
+			},
+			wantTrigger: "输出设备变更",
+			wantAction:  "跳过（用户操作）",
+		},
+		{
+			name: "初始化阶段出现的 HDMI 设备不是热插拔",
+			run: func(t *testing.T) {
+				sinkPolicy.Reset()
+				plugHDMI(t)
+				if hotpluggedDisplay("hdmi_out") {
+					t.Error("初始化阶段出现的设备被视为热插拔")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupHDMITest(t, tt.present)
+			tt.run(t)
+			e, _ := lastHistory()
+			if e.Trigger != tt.wantTrigger || e.Action != tt.wantAction {
+				t.Errorf("记录为 %q → %q，期望 %q → %q", e.Trigger, e.Action, tt.wantTrigger, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestIsDisplayDevice(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{name: "HDMI 路由", data: hdmiDevice, want: true},
+		{name: "DisplayPort 设备名称", data: `{"id":43,"info":{"props":{"device.name":"alsa_card.displayport-dock"}}}`, want: true},
+		{name: "耳机", data: builtinDevice},
+		{name: "USB 音箱", data: usbDevice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dev Device
+			if err := json.Unmarshal([]byte(tt.data), &dev); err != nil {
+				t.Fatal(err)
+			}
+			if got := isDisplayDevice(dev); got != tt.want {
+				t.Errorf("isDisplayDevice = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+type HistoryEntry struct {
+	Time    time.Time
+	Trigger string
+	OldSink string
+	NewSink string
+	Device  string
+	Action  string
+	Players []string
+	// Results 为每个播放器的暂停结果，如「第 2 次尝试后暂停」
+	Results map[string]string
+	// Initiator 为推测的发起设备切换的客户端，如 pavucontrol：元数据变更不携带写入者，按其前后连接或更新的客户端推断
+	Initiator string
+
+	// id 为记录的编号，暂停与恢复的播放器按编号补充到触发它们的记录中
+	id int
+}
+
+var (
+	historyMu sync.Mutex
+	history   []HistoryEntry
+	historyID int
+)
+
+// addHistory 添加一条记录并返回其编号，用于之后通过 attachPlayers、attachResults 补充实际处理的播放器
+func addHistory(e HistoryEntry) int {
+	e.Time = time.Now()
+	emitEvent("action", map[string]any{
+		"trigger":   e.Trigger,
+		"old_sink":  e.OldSink,
+		"new_sink":  e.NewSink,
+		"device":    e.Device,
+		"action":    e.Action,
+		"initiator": e.Initiator,
+	})
+	zap.L().Info("执行动作",
+		zap.String("trigger", triggerID(e.Trigger)),
+		zap.String("sink_old", e.OldSink),
+		zap.String("sink_new", e.NewSink),
+		zap.String("device", e.Device),
+		zap.String("action", e.Action),
+		zap.String("initiator", e.Initiator))
+	go notifyStateChanged()
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	historyID++
+	e.id = historyID
+	history = append(history, e)
+	if size := config.HistorySize; size > 0 && len(history) > size {
+		history = append([]HistoryEntry(nil), history[len(history)-size:]...)
+	}
+	return e.id
+}
+
+// historyEntryLocked 返回编号为 id 的记录，已被移出历史或 id 为 0 时返回 nil；调用方需持有 historyMu
+func historyEntryLocked(id int) *HistoryEntry {
+	if id == 0 {
+		return nil
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].id == id {
+			return &history[i]
+		}
+	}
+	return nil
+}
+
+// attachPlayers 将实际暂停或恢复的播放器补充到编号为 entry 的记录中
+func attachPlayers(entry int, action string, players []string) {
+	if len(players) == 0 {
+		return
+	}
+	emitEvent("players", map[string]any{"action": action, "players": players})
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if e := historyEntryLocked(entry); e != nil {
+		e.Players = append(e.Players, players...)
+	}
+}
+
+// attachResults 将每个播放器的暂停结果补充到编号为 entry 的记录中
+func attachResults(entry int, results map[string]string) {
+	if len(results) == 0 {
+		return
+	}
+	emitEvent("results", map[string]any{"action": "pause", "results": results})
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	e := historyEntryLocked(entry)
+	if e == nil {
+		return
+	}
+	if e.Results == nil {
+		e.Results = make(map[string]string)
+	}
+	for player, result := range results {
+		e.Results[player] = result
+	}
+}
+
+func lastHistory() (HistoryEntry, bool) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if len(history) == 0 {
+		return HistoryEntry{}, false
+	}
+	return history[len(history)-1], true
+}
+
+func (e HistoryEntry) Summary() string {
+	s := e.Trigger
+	if e.Action != "" {
+		s += " → " + e.Action
+	}
+	return s
+}
+
+func (e HistoryEntry) variant() map[string]dbus.Variant {
+	players := e.Players
+	if players == nil {
+		players = []string{}
+	}
+	results := e.Results
+	if results == nil {
+		results = map[string]string{}
+	}
+	return map[string]dbus.Variant{
+		"Time":      dbus.MakeVariant(e.Time.Unix()),
+		"Trigger":   dbus.MakeVariant(e.Trigger),
+		"OldSink":   dbus.MakeVariant(e.OldSink),
+		"NewSink":   dbus.MakeVariant(e.NewSink),
+		"Device":    dbus.MakeVariant(e.Device),
+		"Action":    dbus.MakeVariant(e.Action),
+		"Players":   dbus.MakeVariant(players),
+		"Results":   dbus.MakeVariant(results),
+		"Initiator": dbus.MakeVariant(e.Initiator),
+	}
+}
+
+func historyVariants() []map[string]dbus.Variant {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	out := make([]map[string]dbus.Variant, 0, len(history))
+	for _, e := range history {
+		out = append(out, e.variant())
+	}
+	return out
+}
+
+func formatPlayers(players []string) string {
+	names := make([]string, len(players))
+	for i, p := range players {
+		names[i] = strings.TrimPrefix(p, mprisPrefix)
+	}
+	return strings.Join(names, "，")
+}
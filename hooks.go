@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+const (
+	HookPause         = "on-pause"
+	HookResume        = "on-resume"
+	HookPublicSwitch  = "on-public-switch"
+	HookPrivateSwitch = "on-private-switch"
+)
+
+type HookContext struct {
+	Device string
+	NodeID int
+	Reason string
+}
+
+func hookCommand(event string) string {
+	switch event {
+	case HookPause:
+		return config.Hooks.OnPause
+	case HookResume:
+		return config.Hooks.OnResume
+	case HookPublicSwitch:
+		return config.Hooks.OnPublicSwitch
+	case HookPrivateSwitch:
+		return config.Hooks.OnPrivateSwitch
+	}
+	return ""
+}
+
+func newHookContext(nodeID int, reason string, dev Device) HookContext {
+	return HookContext{Device: DeviceDisplayName(dev), NodeID: nodeID, Reason: reason}
+}
+
+func runHook(event string, hc HookContext) {
+	command := hookCommand(event)
+	if command == "" {
+		return
+	}
+	if dryRun {
+		zap.L().Info("[dry-run] 将执行钩子", zap.String("event", event), zap.String("command", command), zap.String("reason", hc.Reason))
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Hooks.Timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+		cmd.Env = append(os.Environ(),
+			"PW_AUTOPAUSED_EVENT="+event,
+			"PW_AUTOPAUSED_DEVICE="+hc.Device,
+			"PW_AUTOPAUSED_NODE_ID="+strconv.Itoa(hc.NodeID),
+			"PW_AUTOPAUSED_REASON="+hc.Reason,
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			zap.L().Warn("钩子命令执行失败", zap.String("event", event), zap.ByteString("output", out), zap.Error(err))
+			return
+		}
+		zap.L().Debug("钩子命令执行完成", zap.String("event", event), zap.ByteString("output", out))
+	}()
+}
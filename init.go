@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/pkg/pwmon"
+	"github.com/nsplup/pw-autopaused/policy"
+)
+
+// initOptions 为 init 命令的参数，Public 与 Private 按 device.name 或 #索引 指定设备
+type initOptions struct {
+	Yes     bool
+	Force   bool
+	Mode    string
+	Public  []string
+	Private []string
+}
+
+func parseInitOptions(args []string) (initOptions, error) {
+	var opts initOptions
+	var public, private string
+	set := flag.NewFlagSet("init", flag.ContinueOnError)
+	set.BoolVar(&opts.Yes, "yes", false, "不询问，按检测到的分类写入配置")
+	set.BoolVar(&opts.Force, "force", false, "覆盖已存在的配置文件")
+	set.StringVar(&opts.Mode, "mode", "pause", "切换到公共设备时的处理方式：pause、duck、mute-only 或 safe-sink")
+	set.StringVar(&public, "public", "", "视为公共设备的设备，以逗号分隔的 device.name 或 #索引")
+	set.StringVar(&private, "private", "", "视为私有设备的设备，以逗号分隔的 device.name 或 #索引")
+	if err := set.Parse(args); err != nil {
+		return opts, err
+	}
+	if !slices.Contains([]string{"pause", "duck", "mute-only", "safe-sink"}, opts.Mode) {
+		return opts, fmt.Errorf("无效的处理方式：%s", opts.Mode)
+	}
+	opts.Public = splitList(public)
+	opts.Private = splitList(private)
+	return opts, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// matchesDevice 判断命令行中给出的名称是否指向设备
+func matchesDevice(names []string, dev Device) bool {
+	for _, name := range names {
+		if name == dev.Info.Props.DeviceName || name == "#"+strconv.Itoa(dev.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// stdinIsTerminal 判断标准输入是否为终端，非终端时不进行询问
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// runInit 列出当前的输出设备，按参数或交互询问确定每个设备的分类，
+// 将选择写入 devices.toml，并生成带注释的配置文件
+func runInit(args []string) int {
+	opts, err := parseInitOptions(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	path := ConfigPath()
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "无法确定配置目录")
+		return 1
+	}
+	if _, err := os.Stat(path); err == nil && !opts.Force {
+		fmt.Fprintf(os.Stderr, "配置文件已存在：%s（使用 --force 覆盖）\n", path)
+		return 1
+	}
+
+	if conf, err := LoadConfig(SystemConfigPath); err == nil {
+		config = conf
+	}
+	if _, err := exec.LookPath("pw-dump"); err != nil {
+		if _, err := exec.LookPath("pactl"); err == nil {
+			config.Backend = "pulse"
+		}
+	}
+	loadDeviceChoices()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	objects, err := doctorSnapshot(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "无法获取设备列表：%v\n", err)
+		return 1
+	}
+	var devices []Device
+	for _, ev := range pwmon.Decode(objects) {
+		if ev.Type != pwmon.DeviceChanged {
+			continue
+		}
+		if _, ok := ActiveRoute(ev.Device, "output"); ok {
+			devices = append(devices, ev.Device)
+		}
+	}
+
+	interactive := !opts.Yes && stdinIsTerminal()
+	in := bufio.NewReader(os.Stdin)
+	choicesMu.RLock()
+	c := choices
+	choicesMu.RUnlock()
+	names := map[policy.Class]string{policy.Public: "公共设备", policy.Private: "私有设备", policy.Unclassified: "未分类"}
+	bluetooth := false
+
+	fmt.Printf("检测到 %d 个输出设备：\n", len(devices))
+	for _, dev := range devices {
+		name := dev.Info.Props.DeviceName
+		route, _ := ActiveRoute(dev, "output")
+		class := sinkClass(dev)
+		if dev.Info.Props.BluezAddress != "" {
+			bluetooth = true
+		}
+		fmt.Printf("\n#%d %s（%s）\n  路由 %s，port.type=%q，当前分类：%s\n", dev.ID, DeviceDisplayName(dev), name, route.Name, route.PortType(), names[class])
+		if name == "" {
+			continue
+		}
+
+		choice := class
+		switch {
+		case matchesDevice(opts.Public, dev):
+			choice = policy.Public
+		case matchesDevice(opts.Private, dev):
+			choice = policy.Private
+		case interactive:
+			choice = askDeviceClass(in, class)
+		}
+		if choice == class {
+			continue
+		}
+		c.Public = slices.DeleteFunc(c.Public, func(s string) bool { return s == name })
+		c.Private = slices.DeleteFunc(c.Private, func(s string) bool { return s == name })
+		c.Ignore = slices.DeleteFunc(c.Ignore, func(s string) bool { return s == name })
+		switch choice {
+		case policy.Public:
+			c.Public = append(c.Public, name)
+		case policy.Private:
+			c.Private = append(c.Private, name)
+		}
+		fmt.Printf("  → %s\n", names[choice])
+	}
+
+	if len(c.Public)+len(c.Private)+len(c.Ignore) > 0 {
+		if err := saveDeviceChoices(c); err != nil {
+			fmt.Fprintf(os.Stderr, "保存设备分类失败：%v\n", err)
+			return 1
+		}
+		fmt.Printf("\n设备分类已保存到 %s\n", DeviceChoicesPath())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(path, initConfig(opts.Mode, bluetooth), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("配置文件已写入 %s，可使用 doctor 命令检查分类结果\n", path)
+	return 0
+}
+
+// askDeviceClass 询问设备的分类，直接回车保留检测到的分类
+func askDeviceClass(in *bufio.Reader, detected policy.Class) policy.Class {
+	for {
+		fmt.Print("  视为 [p] 公共设备 / [h] 私有设备（耳机），直接回车保持不变：")
+		line, err := in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return detected
+		case "p", "public":
+			return policy.Public
+		case "h", "private":
+			return policy.Private
+		}
+		if err != nil {
+			return detected
+		}
+	}
+}
+
+// initConfig 生成带注释的配置文件，只列出新用户最常调整的配置项
+func initConfig(mode string, bluetooth bool) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# 由 pw-autopaused init 于 %s 生成；未列出的配置项使用默认值，完整说明见 README\n", time.Now().Format("2006-01-02"))
+	b.WriteString("# 单个设备的公共/私有分类保存在同一目录的 devices.toml 中\n\n")
+	b.WriteString("# 事件来源与控制方式：pw-dump（默认）、native（原生协议）或 pulse（依赖 pactl）\n")
+	fmt.Fprintf(&b, "backend = %q\n", config.Backend)
+	b.WriteString("# 切换到公共设备时的处理方式：pause（暂停播放器）、duck（降低输出音量）、mute-only（仅静音输出）、\n")
+	b.WriteString("# safe-sink（先切换到不可闻的 null sink 再暂停播放器）\n")
+	fmt.Fprintf(&b, "mode = %q\n", mode)
+	b.WriteString("# 通话期间切换设备时不做任何处理\n")
+	fmt.Fprintf(&b, "suppress_during_calls = %t\n\n", config.SuppressDuringCalls)
+
+	b.WriteString("[resume]\n")
+	b.WriteString("# 切回私有设备时，自动恢复在该时间窗口内被暂停的播放器\n")
+	fmt.Fprintf(&b, "enabled = %t\n", config.Resume.Enabled)
+	fmt.Fprintf(&b, "window = %q\n\n", config.Resume.Window.String())
+
+	b.WriteString("[notify]\n")
+	b.WriteString("# 自动暂停时发送桌面通知\n")
+	fmt.Fprintf(&b, "enabled = %t\n\n", config.Notify.Enabled)
+
+	b.WriteString("[bluetooth]\n")
+	b.WriteString("# 蓝牙耳机断开时在 PipeWire 切换输出之前抢先暂停（需要系统总线上的 BlueZ），检测到蓝牙音频设备时启用\n")
+	fmt.Fprintf(&b, "enabled = %t\n\n", bluetooth)
+
+	b.WriteString("[classify]\n")
+	b.WriteString("# 新接入的设备无法按路由归类时，通过通知询问其分类\n")
+	fmt.Fprintf(&b, "prompt = %t\n", config.Classify.Prompt)
+	return b.Bytes()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+// initiatorWindow 为客户端连接或更新后，其间写入的默认设备选择归于该客户端的最长间隔；
+// pw-metadata、wpctl 等命令行工具连接后立即写入并断开，设置面板写入前通常也会更新客户端信息
+const initiatorWindow = time.Second
+
+// 默认设备变更的发起者
+const (
+	initiatorUnknown        = ""
+	initiatorSessionManager = "session-manager"
+	initiatorSelf           = "self"
+	initiatorClient         = "client"
+)
+
+type initiator struct {
+	Kind string
+	Name string
+}
+
+var (
+	clientsMu sync.Mutex
+	// 客户端最近一次连接或更新的时间；客户端信息保存在 registry 中，断开后与节点一样延迟清理
+	clientsSeen = make(map[int]time.Time)
+
+	// 最近一次 default.configured.audio.sink 变更的设备与发起者
+	configuredBy   initiator
+	configuredFor  string
+	configuredSeen time.Time
+)
+
+func onClientUpdate(c Client) {
+	cancelDelete(c.ID)
+	registry.PutClient(c)
+	clientsMu.Lock()
+	clientsSeen[c.ID] = time.Now()
+	clientsMu.Unlock()
+}
+
+// recentInitiator 推测默认设备选择的发起者：PipeWire 的元数据变更不携带写入者，只能归于 initiatorWindow 内
+// 唯一连接或更新过的客户端，有多个候选时无法确定；命令行工具写入后立即断开，其移除可能与元数据变更在同一批事件中到达，
+// 延迟清理保证此时仍能查到
+func recentInitiator() initiator {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	now := time.Now()
+	var found initiator
+	candidates := 0
+	for id, seen := range clientsSeen {
+		if now.Sub(seen) > initiatorWindow {
+			delete(clientsSeen, id)
+			continue
+		}
+		c, ok := registry.Client(id)
+		if !ok {
+			continue
+		}
+		by := classifyClient(c)
+		// 本程序的子进程中只有 pw-metadata 会写入默认设备，重启的 pw-dump、pw-cli 等不是发起者
+		if by.Kind == initiatorSelf && c.Info.Props.ApplicationBinary.String() != "pw-metadata" {
+			continue
+		}
+		found = by
+		candidates++
+	}
+	if candidates > 1 {
+		zap.L().Debug("同时有多个客户端连接或更新，无法确定默认设备选择的发起者", zap.Int("clients", candidates))
+		return initiator{}
+	}
+	return found
+}
+
+// clientName 返回客户端的 application.name，没有时返回进程名
+func clientName(c Client) string {
+	if name := c.Info.Props.ApplicationName.String(); name != "" {
+		return name
+	}
+	return c.Info.Props.ApplicationBinary.String()
+}
+
+// nodeClient 返回创建节点的客户端
+func nodeClient(node Node) (Client, bool) {
+	id, err := strconv.Atoi(node.Info.Props.ClientID.String())
+	if err != nil {
+		return Client{}, false
+	}
+	return registry.Client(id)
+}
+
+func classifyClient(c Client) initiator {
+	props := c.Info.Props
+	name := clientName(c)
+	pid := props.SecPID.String()
+	if pid == "" {
+		pid = props.ApplicationPID.String()
+	}
+	switch {
+	case isOwnProcess(pid):
+		return initiator{Kind: initiatorSelf, Name: name}
+	case isSessionManagerClient(props.ApplicationBinary.String(), name):
+		return initiator{Kind: initiatorSessionManager, Name: name}
+	}
+	return initiator{Kind: initiatorClient, Name: name}
+}
+
+func isSessionManagerClient(binary, name string) bool {
+	for _, s := range []string{binary, name} {
+		switch strings.ToLower(s) {
+		case "wireplumber", "pipewire-media-session":
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnProcess 判断 pid 是否为本程序或其启动的 pw-metadata、pw-cli 等子进程
+func isOwnProcess(pid string) bool {
+	n, err := strconv.ParseUint(pid, 10, 32)
+	if err != nil || n == 0 {
+		return false
+	}
+	self := uint32(os.Getpid())
+	if uint32(n) == self {
+		return true
+	}
+	parent, ok := parentPID(uint32(n))
+	return ok && parent == self
+}
+
+// noteConfiguredInitiator 在 default.configured.audio.sink 变更时确定并记录其发起者
+func noteConfiguredInitiator(sink string) initiator {
+	by := recentInitiator()
+	clientsMu.Lock()
+	configuredBy, configuredFor, configuredSeen = by, sink, time.Now()
+	clientsMu.Unlock()
+
+	if by.Kind != initiatorUnknown {
+		zap.L().Debug("默认输出设备的选择来自", zap.String("sink", sink), zap.String("initiator", by.Kind), zap.String("client", by.Name))
+	}
+	return by
+}
+
+// sinkInitiator 返回切换到 sink 的默认设备变更的发起者：只有 policy.UserWindow 内选择了同一设备时才能确定
+func sinkInitiator(sink string) initiator {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if configuredFor != sink || time.Since(configuredSeen) > policy.UserWindow {
+		return initiator{}
+	}
+	return configuredBy
+}
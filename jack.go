@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+var (
+	preemptMu sync.Mutex
+	preemptAt time.Time
+)
+
+// markPreempted 记录一次抢先暂停，随后到达的切换事件不再重复暂停
+func markPreempted() {
+	preemptMu.Lock()
+	preemptAt = time.Now()
+	preemptMu.Unlock()
+}
+
+func recentlyPreempted() bool {
+	preemptMu.Lock()
+	defer preemptMu.Unlock()
+	return time.Since(preemptAt) < 5*time.Second
+}
+
+// unpluggedRoute 返回在 newDev 中由可用变为不可用的私有输出路由
+func unpluggedRoute(oldDev, newDev Device) (RouteInfo, bool) {
+	wasAvailable := make(map[int]bool)
+	for _, routes := range [][]RouteInfo{oldDev.Info.Params.Route, oldDev.Info.Params.EnumRoute} {
+		for _, r := range routes {
+			if strings.EqualFold(r.Direction, "output") && r.Available != "no" {
+				wasAvailable[r.Index] = true
+			}
+		}
+	}
+
+	for _, routes := range [][]RouteInfo{newDev.Info.Params.Route, newDev.Info.Params.EnumRoute} {
+		for _, r := range routes {
+			if !strings.EqualFold(r.Direction, "output") || r.Available != "no" {
+				continue
+			}
+			if wasAvailable[r.Index] && routeIs(r, privateDevice, false) {
+				return r, true
+			}
+		}
+	}
+	return RouteInfo{}, false
+}
+
+// handleRouteAvailability 监听插孔检测：当前默认输出设备上的私有端口变为不可用时立即暂停，
+// 不依赖随后到达的路由或默认设备变更事件
+func handleRouteAvailability(newDev Device) {
+	sink := sinkPolicy.Sink()
+	devID, ok := GetDeviceIDBySinkName(sink)
+	if !ok || newDev.ID != devID {
+		return
+	}
+
+	oldDev, exists := registry.Device(newDev.ID)
+	if !exists {
+		return
+	}
+
+	route, unplugged := unpluggedRoute(oldDev, newDev)
+	if !unplugged {
+		return
+	}
+	nodeID, ok := GetSinkIDByName(sink)
+	if !ok {
+		return
+	}
+
+	zap.L().Info("耳机已拔出", zap.String("route", route.Name))
+	class := sinkPolicy.Class()
+	applyTransition(transition{
+		oldSink: sink,
+		newSink: sink,
+		from:    class,
+		to:      class,
+		dev:     newDev,
+		nodeID:  nodeID,
+		reason:  "耳机拔出",
+		preempt: true,
+	})
+}
+
+var (
+	removedMu sync.Mutex
+	removedAt time.Time
+)
+
+// handlePrivateRemoval 记录当前默认输出所在私有设备（或其节点）的移除，
+// 随后回退到其他设备的切换一律视为需要暂停，不依赖已失效的旧设备信息
+func handlePrivateRemoval(id int) {
+	sink := sinkPolicy.Sink()
+	if sink == "" || sinkPolicy.Class() != policy.Private {
+		return
+	}
+	nodeID, nodeOK := GetSinkIDByName(sink)
+	devID, devOK := GetDeviceIDBySinkName(sink)
+	if !(nodeOK && id == nodeID) && !(devOK && id == devID) {
+		return
+	}
+
+	zap.L().Info("当前私有输出设备已移除", zap.String("sink", sink), zap.Int("id", id))
+	removedMu.Lock()
+	removedAt = time.Now()
+	removedMu.Unlock()
+}
+
+// privateRemovedRecently 判断最近是否移除过私有输出设备，并清除该记录
+func privateRemovedRecently() bool {
+	removedMu.Lock()
+	defer removedMu.Unlock()
+	recent := time.Since(removedAt) < 5*time.Second
+	removedAt = time.Time{}
+	return recent
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// decodeDevice 按 pw-dump 的格式解析设备，info 中只需给出 params
+func decodeDevice(t *testing.T, params string) Device {
+	t.Helper()
+	var dev Device
+	if err := json.Unmarshal([]byte(`{"id":40,"info":{"params":`+params+`}}`), &dev); err != nil {
+		t.Fatal(err)
+	}
+	return dev
+}
+
+func TestUnpluggedRoute(t *testing.T) {
+	const (
+		headphonesYes = `{"Route":[{"index":3,"name":"analog-output-headphones","direction":"Output","available":"yes","info":[1,"port.type","headphones"]}]}`
+		headphonesNo  = `{"Route":[{"index":3,"name":"analog-output-headphones","direction":"Output","available":"no","info":[1,"port.type","headphones"]}]}`
+		speakerYes    = `{"Route":[{"index":2,"name":"analog-output-speaker","direction":"Output","available":"yes","info":[1,"port.type","speaker"]}]}`
+		speakerNo     = `{"Route":[{"index":2,"name":"analog-output-speaker","direction":"Output","available":"no","info":[1,"port.type","speaker"]}]}`
+		micNo         = `{"Route":[{"index":5,"name":"analog-input-headset-mic","direction":"Input","available":"no","info":[1,"port.type","headset"]}]}`
+		enumNo        = `{"EnumRoute":[{"index":3,"name":"analog-output-headphones","direction":"Output","available":"no","info":[1,"port.type","headphones"]}]}`
+	)
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{name: "耳机拔出", old: headphonesYes, new: headphonesNo, want: "analog-output-headphones"},
+		{name: "耳机插入", old: headphonesNo, new: headphonesYes},
+		{name: "耳机一直未插入", old: headphonesNo, new: headphonesNo},
+		{name: "扬声器不可用", old: speakerYes, new: speakerNo},
+		{name: "输入路由不可用", old: `{"Route":[{"index":5,"name":"analog-input-headset-mic","direction":"Input","available":"yes","info":[1,"port.type","headset"]}]}`, new: micNo},
+		{name: "仅 EnumRoute 更新", old: headphonesYes, new: enumNo, want: "analog-output-headphones"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			route, ok := unpluggedRoute(decodeDevice(t, tt.old), decodeDevice(t, tt.new))
+			if ok != (tt.want != "") || route.Name != tt.want {
+				t.Errorf("unpluggedRoute = %q, %v，期望 %q", route.Name, ok, tt.want)
+			}
+		})
+	}
+}
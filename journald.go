@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	journalSocket     = "/run/systemd/journal/socket"
+	journalIdentifier = "pw-autopaused"
+)
+
+// triggerIDs 将触发原因映射为写入日志 TRIGGER 字段的标识，便于 journalctl 过滤
+var triggerIDs = map[string]string{
+	"设备路由变更":        "route-change",
+	"输出设备变更":        "sink-change",
+	"HDMI 设备接入":     "hdmi-hotplug",
+	"私有设备移除":        "device-removed",
+	"非默认设备路由变更":     "other-sink-route-change",
+	"应用流切换输出设备":     "stream-move",
+	"虚拟输出设备的下层设备变更": "virtual-sink-relink",
+	"输入设备变更":        "source-change",
+	"耳机拔出":          "jack-unplug",
+	"蓝牙设备断开":        "bluetooth-disconnect",
+	"系统挂起":          "sleep",
+	"锁屏":            "lock",
+	"时段策略切换":        "schedule",
+	"快捷键":           "shortcut",
+	"紧急暂停":          "panic",
+	"设备配置文件切换":      "profile-change",
+	"开始屏幕共享":        "screencast-start",
+	"结束屏幕共享":        "screencast-stop",
+}
+
+func triggerID(reason string) string {
+	if id, ok := triggerIDs[reason]; ok {
+		return id
+	}
+	return reason
+}
+
+// journalCore 通过 journald 原生协议写入日志，每个 zap 字段成为一个同名的大写日志字段
+type journalCore struct {
+	zapcore.LevelEnabler
+	conn   *net.UnixConn
+	mu     *sync.Mutex
+	fields []zapcore.Field
+}
+
+func newJournalCore(level zapcore.LevelEnabler) (*journalCore, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("connect to journald: %w", err)
+	}
+	return &journalCore{LevelEnabler: level, conn: conn, mu: &sync.Mutex{}}, nil
+}
+
+func (c *journalCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+	return &clone
+}
+
+func (c *journalCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *journalCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", e.Message)
+	writeJournalField(&buf, "PRIORITY", journalPriority(e.Level))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", journalIdentifier)
+	if e.Stack != "" {
+		writeJournalField(&buf, "STACK", e.Stack)
+	}
+	for key, value := range enc.Fields {
+		writeJournalField(&buf, journalFieldName(key), fmt.Sprint(value))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(buf.Bytes())
+	return err
+}
+
+func (c *journalCore) Sync() error {
+	return nil
+}
+
+// writeJournalField 按原生协议写入一个字段，含换行的值需使用带长度前缀的二进制格式
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName 将字段名转换为 journald 接受的形式：大写字母、数字与下划线，且不以下划线开头
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "F_" + name
+	}
+	return name
+}
+
+func journalPriority(level zapcore.Level) string {
+	switch level {
+	case zapcore.DebugLevel:
+		return "7"
+	case zapcore.InfoLevel:
+		return "6"
+	case zapcore.WarnLevel:
+		return "4"
+	case zapcore.ErrorLevel:
+		return "3"
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		return "2"
+	default:
+		return "1"
+	}
+}
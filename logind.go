@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	login1Name    = "org.freedesktop.login1"
+	login1Path    = dbus.ObjectPath("/org/freedesktop/login1")
+	login1Manager = "org.freedesktop.login1.Manager"
+	login1Session = "org.freedesktop.login1.Session"
+)
+
+var (
+	inhibitMu sync.Mutex
+	inhibitFd *os.File
+)
+
+// takeSleepInhibitor 获取 delay 类型的休眠抑制锁，使暂停指令能在系统挂起前发出
+func takeSleepInhibitor(conn *dbus.Conn) {
+	inhibitMu.Lock()
+	defer inhibitMu.Unlock()
+	if inhibitFd != nil {
+		return
+	}
+
+	var fd dbus.UnixFD
+	err := conn.Object(login1Name, login1Path).Call(login1Manager+".Inhibit", 0,
+		"sleep", "pw-autopaused", "挂起前暂停播放器", "delay").Store(&fd)
+	if err != nil {
+		zap.L().Warn("无法获取休眠抑制锁", zap.Error(err))
+		return
+	}
+	inhibitFd = os.NewFile(uintptr(fd), "inhibit")
+}
+
+func releaseSleepInhibitor() {
+	inhibitMu.Lock()
+	defer inhibitMu.Unlock()
+	if inhibitFd != nil {
+		inhibitFd.Close()
+		inhibitFd = nil
+	}
+}
+
+func isOwnSession(conn *dbus.Conn, p dbus.ObjectPath) bool {
+	v, err := conn.Object(login1Name, p).GetProperty(login1Session + ".User")
+	if err != nil {
+		return false
+	}
+	user, ok := v.Value().([]interface{})
+	if !ok || len(user) == 0 {
+		return false
+	}
+	uid, _ := user[0].(uint32)
+	return int(uid) == os.Getuid()
+}
+
+func pauseForSystemEvent(reason string) {
+	if isSnoozed() {
+		zap.L().Info("自动暂停已暂时停用，跳过本次暂停", zap.String("reason", reason))
+		return
+	}
+	zap.L().Info("暂停播放器，触发事件为【" + reason + "】")
+	id := addHistory(HistoryEntry{Trigger: reason, Action: "pause"})
+	runHook(HookPause, HookContext{Reason: reason})
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
+	defer cancel()
+	pauseAllPlayers(ctx, id)
+}
+
+func startLogindMonitor() {
+	if !config.Logind.PauseOnSleep && !config.Logind.PauseOnLock {
+		return
+	}
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		zap.L().Warn("无法连接系统总线，挂起与锁屏检测不可用", zap.Error(err))
+		return
+	}
+
+	if config.Logind.PauseOnSleep {
+		err = conn.AddMatchSignal(
+			dbus.WithMatchInterface(login1Manager),
+			dbus.WithMatchMember("PrepareForSleep"),
+		)
+	}
+	if err == nil && config.Logind.PauseOnLock {
+		err = conn.AddMatchSignal(
+			dbus.WithMatchInterface(login1Session),
+			dbus.WithMatchMember("Lock"),
+		)
+	}
+	if err != nil {
+		zap.L().Warn("无法订阅 logind 事件", zap.Error(err))
+		conn.Close()
+		return
+	}
+
+	if config.Logind.PauseOnSleep {
+		takeSleepInhibitor(conn)
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	go func() {
+		for sig := range signals {
+			switch sig.Name {
+			case login1Manager + ".PrepareForSleep":
+				if len(sig.Body) < 1 {
+					continue
+				}
+				if start, _ := sig.Body[0].(bool); start {
+					pauseForSystemEvent("系统挂起")
+					releaseSleepInhibitor()
+				} else {
+					takeSleepInhibitor(conn)
+				}
+			case login1Session + ".Lock":
+				if isOwnSession(conn, sig.Path) {
+					pauseForSystemEvent("锁屏")
+				}
+			}
+		}
+	}()
+}
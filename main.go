@@ -3,370 +3,849 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
+	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/godbus/dbus/v5"
+	"github.com/nsplup/pw-autopaused/pkg/mpris"
+	"github.com/nsplup/pw-autopaused/pkg/pwmon"
+	"github.com/nsplup/pw-autopaused/policy"
 	"go.uber.org/zap"
 )
 
 var (
-	IsUserOperation    bool
-	currentDefaultSink string
-	pwCliStdin io.WriteCloser
-	dbusConn *dbus.Conn
+	sinkPolicy    = policy.NewMachine()
+	dryRun        bool
+	recordFile    *os.File
 	triggerDelete func(int)
 	cancelDelete  func(int)
+	resetDelete   func()
 
-	nodesMu         sync.RWMutex
-	devsMu          sync.RWMutex
-	stdinMu    sync.Mutex
+	controllerMu sync.Mutex
+	pausedMu     sync.Mutex
 
-	GlobalNodes   = make(map[int]Node)
-	GlobalDevices = make(map[int]Device)
+	config        = DefaultConfig()
+	pausedPlayers []string
+	pausedAt      time.Time
+
+	registry   = pwmon.NewRegistry(forgetObject)
+	controller Controller
 
 	publicDevice  = []string{"speaker", "hdmi", "displayport"}
 	privateDevice = []string{"headphones", "headset"}
+	publicSource  = []string{"mic"}
+	privateSource = []string{"headset", "handsfree", "handset"}
 )
 
-type PwObject struct {
-	ID   int    `json:"id"`
-	Type string `json:"type"`
-	Info json.RawMessage `json:"info"`
-}
-
-type Node struct {
-	ID   int `json:"id"`
-	Info struct {
-		Props struct {
-			NodeName   string `json:"node.name"`
-			DeviceID   int    `json:"device.id"`
-			MediaClass string `json:"media.class"`
-		} `json:"props"`
-	} `json:"info"`
-}
-
-type Device struct {
-	ID   int `json:"id"`
-	Info struct {
-		Props struct {
-			DeviceName  string `json:"device.name"`
-			DeviceAlias string `json:"device.alias"`
-		} `json:"props"`
-		Params struct {
-			Route   []RouteInfo   `json:"Route"`
-			Profile []interface{} `json:"Profile"`
-		} `json:"params"`
-	} `json:"info"`
-}
-
-type RouteInfo struct {
-	Index     int           `json:"index"`
-	Name      string        `json:"name"`
-	Direction string        `json:"direction"`
-	Priority  int           `json:"priority"`
-	Info      []interface{} `json:"info"`
-}
-
-type RouteData struct {
-	Properties map[string]string
-}
+// PipeWire 对象模型与缓存由 pkg/pwmon 提供
+type (
+	Node          = pwmon.Node
+	NodeProps     = pwmon.NodeProps
+	Device        = pwmon.Device
+	RouteInfo     = pwmon.RouteInfo
+	ProfileInfo   = pwmon.ProfileInfo
+	MetadataEntry = pwmon.MetadataEntry
+	PropString    = pwmon.PropString
+	Client        = pwmon.Client
+)
 
-type MetadataEntry struct {
-	Subject int         `json:"subject"`
-	Key     string      `json:"key"`
-	Type    string      `json:"type"`
-	Value   interface{} `json:"value"`
+func GetDeviceIDByNodeName(nodeName string) (int, bool) {
+	return deviceIDByNode(nodeName, GetNodeIDByName)
 }
 
-type MetadataUpdate struct {
-	ID       int             `json:"id"`
-	Metadata []MetadataEntry `json:"metadata"`
+func GetDeviceIDBySinkName(nodeName string) (int, bool) {
+	return deviceIDByNode(nodeName, GetSinkIDByName)
 }
 
-func GetDeviceIDByNodeName(nodeName string) (int, bool) {
-	nodeID, ok := GetNodeIDByName(nodeName)
+func deviceIDByNode(nodeName string, lookup func(string) (int, bool)) (int, bool) {
+	nodeID, ok := lookup(nodeName)
 	if !ok {
 		zap.L().Debug("无法找到节点索引", zap.String("name", nodeName))
 		return 0, false
 	}
 
-	nodesMu.RLock()
-	node, exists := GlobalNodes[nodeID]
-	nodesMu.RUnlock()
+	node, exists := registry.Node(nodeID)
 
 	if !exists {
 		zap.L().Debug("无法找到节点", zap.Int("id", nodeID))
 		return 0, false
 	}
+	if isVirtualNode(node) {
+		// 虚拟节点以其下层的硬件设备为准，组合输出取第一个硬件设备
+		hw := hardwareNodes(node)
+		if len(hw) == 0 {
+			zap.L().Debug("无法解析虚拟节点下层的硬件设备", zap.String("name", nodeName))
+			return 0, false
+		}
+		return hw[0].Info.Props.DeviceID, true
+	}
 	return node.Info.Props.DeviceID, true
 }
 
 func GetHighestPriorityOutputRoute(dev Device) (RouteInfo, bool) {
+	return ActiveRoute(dev, "output")
+}
+
+func GetHighestPriorityRoute(dev Device, direction string) (RouteInfo, bool) {
 	var bestRoute RouteInfo
 	found := false
 
 	for _, r := range dev.Info.Params.Route {
-		if strings.EqualFold(r.Direction, "output") {
-			if !found || r.Priority > bestRoute.Priority {
-				bestRoute = r
-				found = true
+		if !strings.EqualFold(r.Direction, direction) {
+			continue
+		}
+		// 优先选择可用的路由：未插入的 HDMI 等路由优先级再高也不代表当前输出
+		switch {
+		case !found:
+		case (r.Available == "no") != (bestRoute.Available == "no"):
+			if r.Available == "no" {
+				continue
 			}
+		case r.Priority <= bestRoute.Priority:
+			continue
 		}
+		bestRoute = r
+		found = true
 	}
 	return bestRoute, found
 }
 
-func checkDeviceCategory(dev Device, keywords []string) bool {
-	topRoute, ok := GetHighestPriorityOutputRoute(dev)
+// ActiveRoute 返回设备当前生效的路由：只考虑属于当前配置文件（Profile 参数）的路由，
+// 存在多个时再按可用性与优先级选择
+func ActiveRoute(dev Device, direction string) (RouteInfo, bool) {
+	if len(dev.Info.Params.Profile) > 0 {
+		current := dev.Info.Params.Profile[0].Index
+		var routes []RouteInfo
+		for _, r := range dev.Info.Params.Route {
+			if r.Profile == current {
+				routes = append(routes, r)
+			}
+		}
+		if len(routes) > 0 {
+			dev.Info.Params.Route = routes
+		}
+	}
+	return GetHighestPriorityRoute(dev, direction)
+}
+
+// nodeDevice 将设备的路由限定为节点所对应的声卡设备（card.profile.device），
+// 使同时包含 HDMI 与模拟输出的配置文件中各个 sink 按各自的路由分类
+func nodeDevice(dev Device, node Node) Device {
+	cardDevice, err := strconv.Atoi(node.Info.Props.CardDevice.String())
+	if err != nil {
+		return dev
+	}
+	var routes []RouteInfo
+	for _, r := range dev.Info.Params.Route {
+		if r.Device == cardDevice {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) > 0 {
+		dev.Info.Params.Route = routes
+	}
+	return dev
+}
+
+func checkDeviceCategory(dev Device, keywords []string, public bool) bool {
+	if chosen, ok := deviceChoice(dev); ok {
+		return chosen == public
+	}
+	return checkRouteCategory(dev, "output", keywords, public)
+}
+
+func checkRouteCategory(dev Device, direction string, keywords []string, public bool) bool {
+	topRoute, ok := ActiveRoute(dev, direction)
 	if !ok {
 		return false
 	}
+	return routeIs(topRoute, keywords, public)
+}
 
-	info := topRoute.Info
-	if len(info) < 3 {
+func routeMatches(route RouteInfo, keywords []string) bool {
+	portType := strings.ToLower(route.PortType())
+	if portType == "" {
 		return false
 	}
-
-	for i := 1; i+1 < len(info); i += 2 {
-		key, kOk := info[i].(string)
-		if kOk && key == "port.type" {
-			val, vOk := info[i+1].(string)
-			if vOk {
-				portType := strings.ToLower(val)
-				for _, kw := range keywords {
-					if strings.Contains(portType, kw) {
-						return true
-					}
-				}
-			}
-			break
+	for _, kw := range keywords {
+		if strings.Contains(portType, kw) {
+			return true
 		}
 	}
 	return false
 }
 
+func DeviceDisplayName(dev Device) string {
+	if route, ok := GetHighestPriorityOutputRoute(dev); ok && route.Description != "" {
+		return route.Description
+	}
+	for _, name := range []string{dev.Info.Props.DeviceDescription, dev.Info.Props.DeviceAlias, dev.Info.Props.DeviceName} {
+		if name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("#%d", dev.ID)
+}
+
 func IsPublicDevice(dev Device) bool {
-	return checkDeviceCategory(dev, publicDevice)
+	return checkDeviceCategory(dev, publicDevice, true)
 }
 
 func IsPrivateDevice(dev Device) bool {
-	return checkDeviceCategory(dev, privateDevice)
+	return checkDeviceCategory(dev, privateDevice, false)
 }
 
-func setPipewireMute(nodeID int, mute bool) {
-	if pwCliStdin == nil {
-		return
+func sinkClass(dev Device) policy.Class {
+	switch {
+	case IsPrivateDevice(dev):
+		return policy.Private
+	case IsPublicDevice(dev):
+		return policy.Public
+	}
+	return policy.Unclassified
+}
+
+func sourceClass(dev Device) policy.Class {
+	switch {
+	case IsPrivateSource(dev):
+		return policy.Private
+	case IsPublicSource(dev):
+		return policy.Public
 	}
+	return policy.Unclassified
+}
 
-	volume := "[1.0, 1.0]"
-	if mute {
-		volume = "[0.0, 0.0]"
+// classifyNode 按 lookup 解析节点名称并对其所属设备分类
+func classifyNode(nodeName string, lookup func(string) (int, bool), classify func(Device) policy.Class) (Device, policy.Class) {
+	if nodeID, ok := lookup(nodeName); ok {
+		if node, ok := registry.Node(nodeID); ok && isVirtualNode(node) {
+			return classifyHardware(node, classify)
+		}
+	}
+	devID, ok := deviceIDByNode(nodeName, lookup)
+	if !ok {
+		return Device{}, policy.Unclassified
 	}
+	dev, exists := registry.Device(devID)
+	if !exists {
+		return Device{}, policy.Unclassified
+	}
+	if nodeID, ok := lookup(nodeName); ok {
+		if node, ok := registry.Node(nodeID); ok {
+			return dev, classify(nodeDevice(dev, node))
+		}
+	}
+	return dev, classify(dev)
+}
 
-	cmd := fmt.Sprintf("set-param %d Props { channelVolumes: %s }\n", nodeID, volume)
+func IsPublicSource(dev Device) bool {
+	return checkRouteCategory(dev, "input", publicSource, true)
+}
+
+func IsPrivateSource(dev Device) bool {
+	return checkRouteCategory(dev, "input", privateSource, false)
+}
 
-	stdinMu.Lock()
-	defer stdinMu.Unlock()
-	_, err := io.WriteString(pwCliStdin, cmd)
+func playerIdentity(ctx context.Context, playerName string) string {
+	reg := mprisPlayers()
+	if reg == nil {
+		return ""
+	}
+	identity, err := reg.Identity(ctx, playerName)
 	if err != nil {
-		zap.L().Error("向控制进程发送指令失败", zap.Error(err))
+		zap.L().Debug("获取播放器名称失败", zap.String("player", playerName), zap.Error(err))
 	}
+	return identity
 }
 
-func pauseAllPlayers(ctx context.Context) {
-	if dbusConn == nil {
-		zap.L().Error("未建立与会话总线的连接")
-		return
+func playerStatus(ctx context.Context, playerName string) (string, error) {
+	reg := mprisPlayers()
+	if reg == nil {
+		return mpris.Status(ctx, sessionBus(), playerName)
 	}
+	return reg.Status(ctx, playerName)
+}
 
-	var names []string
-	err := dbusConn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.ListNames", 0).Store(&names)
-	if err != nil {
-		zap.L().Error("获取名单列表失败", zap.Error(err))
-		return
+func matchPlayer(playerName, identity string, patterns []string) bool {
+	suffix := strings.TrimPrefix(playerName, mprisPrefix)
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "re:") {
+			if matchName(p, suffix) || matchName(p, identity) {
+				return true
+			}
+			continue
+		}
+		if suffix == p || strings.HasPrefix(suffix, p+".") {
+			return true
+		}
+		if identity != "" && strings.EqualFold(identity, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPlayerAllowed(ctx context.Context, playerName string) bool {
+	allow, deny := config.Players.Allow, config.Players.Deny
+	if len(allow) == 0 && len(deny) == 0 {
+		return true
 	}
 
-	var wg sync.WaitGroup
+	identity := playerIdentity(ctx, playerName)
+	if matchPlayer(playerName, identity, deny) {
+		zap.L().Debug("播放器位于黑名单中，已跳过", zap.String("player", playerName), zap.String("identity", identity))
+		return false
+	}
+	if len(allow) > 0 && !matchPlayer(playerName, identity, allow) {
+		zap.L().Debug("播放器不在白名单中，已跳过", zap.String("player", playerName), zap.String("identity", identity))
+		return false
+	}
+	return true
+}
+
+func pauseAllPlayers(ctx context.Context, entry int) {
+	pausePlayers(ctx, entry, nil)
+}
+
+// pausePlayers 暂停正在播放的播放器，match 不为 nil 时仅处理其返回 true 的播放器；返回已发出暂停指令的播放器
+func pausePlayers(ctx context.Context, entry int, match func(ctx context.Context, playerName string) bool) []string {
+	conn := sessionBus()
+	if conn == nil {
+		if !headless.Load() {
+			zap.L().Error("未建立与会话总线的连接")
+		}
+		return nil
+	}
+
+	names := playerNames()
+	if len(names) == 0 {
+		var err error
+		if names, err = mpris.ListNames(ctx, conn); err != nil {
+			zap.L().Error("获取名单列表失败", zap.Error(err))
+			return nil
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		paused  []string
+		results = make(map[string]string)
+		tracks  = make(map[string]mpris.Track)
+	)
 	for _, name := range names {
-		if strings.HasPrefix(name, "org.mpris.MediaPlayer2.") {
+		if _, tracked := lookupPlayer(name); tracked || mpris.IsPlayer(name) {
 			wg.Add(1)
 			go func(playerName string) {
+				defer restoreOnPanic()
 				defer wg.Done()
-				
-				obj := dbusConn.Object(playerName, "/org/mpris/MediaPlayer2")
-				call := obj.CallWithContext(ctx, "org.mpris.MediaPlayer2.Player.Pause", 0)
 
-				if call.Err != nil {
-					zap.L().Warn("尝试暂停播放器失败", zap.String("player", playerName), zap.Error(call.Err))
+				// 每个播放器单独限时，避免个别无响应的播放器耗尽整体的超时时间
+				ctx, cancel := context.WithTimeout(ctx, config.PlayerCallTimeout)
+				defer cancel()
+
+				if !isPlayerAllowed(ctx, playerName) {
+					return
+				}
+				if match != nil && !match(ctx, playerName) {
+					return
+				}
+
+				status, err := playerStatus(ctx, playerName)
+				if err != nil {
+					zap.L().Debug("获取播放器状态失败", zap.String("player", playerName), zap.Error(err))
+					return
+				}
+				if status != "Playing" {
+					return
+				}
+
+				method := "Pause"
+				switch playerAction(ctx, playerName) {
+				case "stop":
+					method = "Stop"
+				case "ignore":
+					zap.L().Debug("按规则忽略播放器", zap.String("player", playerName))
+					return
+				case "mute":
+					mutePlayer(playerName)
+					return
+				}
+				if !playerControllable(ctx, playerName) {
+					zap.L().Info("播放器不支持暂停，改为静音其输出流", zap.String("player", playerName))
+					mutePlayer(playerName)
+					return
+				}
+
+				if dryRun {
+					zap.L().Info("[dry-run] 将暂停播放器", zap.String("player", playerName), zap.String("identity", playerIdentity(ctx, playerName)), zap.String("method", method))
+					mu.Lock()
+					paused = append(paused, playerName)
+					mu.Unlock()
+					return
+				}
+
+				if err := mpris.Call(ctx, conn, playerName, method); err != nil {
+					zap.L().Warn("尝试暂停播放器失败，改为静音其输出流", zap.String("player", playerName), zap.Error(err))
+					mutePlayer(playerName)
+					return
+				}
+				result := verifyPaused(ctx, conn, playerName, method)
+				// 记录暂停时的曲目与位置，供状态查询、事件记录与恢复时回退使用
+				track, trackErr := mpris.CurrentTrack(ctx, conn, playerName)
+				if trackErr == nil {
+					if result != "" {
+						result += "，"
+					}
+					result += trackSummary(track)
+				}
+				mu.Lock()
+				paused = append(paused, playerName)
+				if result != "" {
+					results[playerName] = result
+				}
+				if trackErr == nil {
+					tracks[playerName] = track
 				}
+				mu.Unlock()
 			}(name)
 		}
 	}
-	wg.Wait() 
+	wg.Wait()
+	attachResults(entry, results)
+
+	if len(paused) == 0 {
+		return nil
+	}
+	pausedMu.Lock()
+	notePausedLocked(paused, tracks, time.Now())
+	pausedMu.Unlock()
+	saveState()
+	go notifyStateChanged()
+	attachPlayers(entry, "pause", paused)
+	return paused
+}
+
+// resumePausedPlayers 恢复待恢复列表中 only 内的播放器，only 为 nil 时恢复全部
+func resumePausedPlayers(ctx context.Context, entry int, force bool, only []string) int {
+	conn := sessionBus()
+	if conn == nil {
+		if !headless.Load() {
+			zap.L().Error("未建立与会话总线的连接")
+		}
+		return 0
+	}
+
+	pausedMu.Lock()
+	players, expired, tracks := takePausedLocked(force, only, time.Now())
+	pausedMu.Unlock()
+
+	if len(players)+len(expired) == 0 {
+		return 0
+	}
+	saveState()
+	go notifyStateChanged()
+	if len(expired) > 0 {
+		zap.L().Info("距离暂停已超过恢复窗口，不再恢复播放器", zap.Strings("players", expired), zap.Duration("window", config.Resume.Window))
+	}
+	if len(players) == 0 {
+		return 0
+	}
+	attachPlayers(entry, "resume", players)
+
+	var wg sync.WaitGroup
+	for _, name := range players {
+		wg.Add(1)
+		go func(playerName string) {
+			defer restoreOnPanic()
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, config.PlayerCallTimeout)
+			defer cancel()
+
+			if dryRun {
+				zap.L().Info("[dry-run] 将恢复播放器", zap.String("player", playerName))
+				return
+			}
+			if t, ok := tracks[playerName]; ok {
+				rewindPlayer(ctx, conn, playerName, t)
+			}
+
+			if err := mpris.Play(ctx, conn, playerName); err != nil {
+				zap.L().Warn("尝试恢复播放器失败", zap.String("player", playerName), zap.Error(err))
+			}
+		}(name)
+	}
+	wg.Wait()
+	return len(players)
 }
 
-func pauseWithMute(nodeID int) {
-	go setPipewireMute(nodeID, true)
+func resumeAsync(entry, nodeID int, reason string, dev Device) {
+	resumePlayersAsync(entry, nil, nodeID, reason, dev)
+}
 
+// resumePlayersAsync 只恢复 players 中仍在待恢复列表内的播放器，其余暂停的播放器保持不变；players 为 nil 时恢复全部
+func resumePlayersAsync(entry int, players []string, nodeID int, reason string, dev Device) {
+	if !config.Resume.Enabled {
+		return
+	}
+	if dndPolicy("never-resume") {
+		zap.L().Info("勿扰模式已开启，不自动恢复播放器", zap.String("reason", reason))
+		return
+	}
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer restoreOnPanic()
+		ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
 		defer cancel()
 
-		pauseAllPlayers(ctx)
+		if resumePausedPlayers(ctx, entry, false, players) > 0 {
+			runHook(HookResume, newHookContext(nodeID, reason, dev))
+		}
+	}()
+}
 
-		select {
-		case <-time.After(1000 * time.Millisecond):
-		case <-ctx.Done():
-			zap.L().Warn("暂停播放器时超时")
-			return
+func pauseWithMute(entry, nodeID int, reason string, dev Device) {
+	pauseWithGuard(entry, nodeID, guardTargets(nodeID), reason, dev)
+}
+
+func pauseWithGuard(entry, nodeID int, targets []int, reason string, dev Device) {
+	pauseMatching(entry, nodeID, targets, reason, dev, routedTo(nodeID))
+}
+
+// pauseMatching 静音 targets 后暂停 match 选出的播放器，实际暂停的播放器补充到编号为 entry 的记录中
+func pauseMatching(entry, nodeID int, targets []int, reason string, dev Device, match func(ctx context.Context, playerName string) bool) {
+	if !sessionIsActive() {
+		zap.L().Info("登录会话不在前台，跳过本次暂停", zap.String("reason", reason))
+		return
+	}
+	if isSnoozed() {
+		zap.L().Info("自动暂停已暂时停用，跳过本次暂停", zap.String("reason", reason))
+		return
+	}
+	if suppressedByCall(reason) || suppressedByScreencast(reason) {
+		return
+	}
+
+	notifyPaused(reason, dev)
+	runHook(HookPause, newHookContext(nodeID, reason, dev))
+	applyStreamPolicy(nodeID)
+
+	gated := isGateNode(nodeID)
+	if gated && holdGate() {
+		// 由断开防护输出代替静音，无需等待淡出；未能及时断开时仍然静音
+		targets = nil
+	}
+
+	var muted sync.WaitGroup
+	for _, id := range targets {
+		muted.Add(1)
+		go func(id int) {
+			defer restoreOnPanic()
+			defer muted.Done()
+			setPipewireMute(id, true)
+		}(id)
+	}
+
+	go func() {
+		defer restoreOnPanic()
+		// 等待淡出结束后再暂停播放器
+		muted.Wait()
+
+		ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
+		defer cancel()
+
+		paused := pausePlayers(ctx, entry, match)
+
+		if reg := mprisPlayers(); len(paused) > 0 && reg != nil && !dryRun {
+			// 等待播放器确认已暂停后再取消静音，响应慢的播放器不会在取消静音后继续外放
+			waitCtx, cancel := context.WithTimeout(context.Background(), config.PauseConfirmTimeout)
+			pending := reg.WaitStatus(waitCtx, paused, mpris.Paused, mpris.Stopped)
+			cancel()
+			if len(pending) > 0 {
+				zap.L().Warn("等待播放器确认暂停超时", zap.Strings("players", pending))
+				if playing := stillPlaying(pending); len(playing) > 0 && config.KeepMutedOnTimeout {
+					if gated {
+						keepGate()
+					}
+					keepMuted(targets, playing, dev)
+					return
+				}
+			}
+		} else {
+			select {
+			case <-time.After(config.UnmuteDelay):
+			case <-ctx.Done():
+				zap.L().Warn("暂停播放器时超时")
+				if gated {
+					releaseGate()
+				}
+				return
+			}
 		}
 
-		setPipewireMute(nodeID, false)
+		for _, id := range targets {
+			setPipewireMute(id, false)
+		}
+		if gated {
+			releaseGate()
+		}
+		if len(paused) > 0 {
+			playEarcon(nodeID)
+		}
 	}()
 }
 
-func handleDefaultRouteChange(newDev Device) {
-	currentDevID, ok := GetDeviceIDByNodeName(currentDefaultSink)
-	if !ok {
-		return
+// pauseVerifyWindow 为每次发送暂停指令后等待 PlaybackStatus 变化的时间
+const pauseVerifyWindow = 500 * time.Millisecond
+
+// verifyPaused 确认播放器在发送 method 后离开 Playing 状态，未确认时重新发送，最多重试 pause_retries 次；
+// 返回用于事件记录的结果，无法确认（如未建立播放器缓存）时返回空字符串
+func verifyPaused(ctx context.Context, conn *dbus.Conn, playerName, method string) string {
+	reg := mprisPlayers()
+	if reg == nil {
+		return ""
 	}
+	for attempt := 1; ; attempt++ {
+		verifyCtx, cancel := context.WithTimeout(ctx, pauseVerifyWindow)
+		pending := reg.WaitStatus(verifyCtx, []string{playerName}, mpris.Paused, mpris.Stopped)
+		cancel()
+		switch {
+		case len(pending) == 0 && attempt == 1:
+			return "已暂停"
+		case len(pending) == 0:
+			zap.L().Info("重试后播放器已暂停", zap.String("player", playerName), zap.Int("attempts", attempt))
+			return fmt.Sprintf("第 %d 次尝试后暂停", attempt)
+		case attempt > config.PauseRetries || ctx.Err() != nil:
+			zap.L().Warn("播放器未响应暂停指令", zap.String("player", playerName), zap.Int("attempts", attempt))
+			return fmt.Sprintf("未能暂停（已尝试 %d 次）", attempt)
+		}
+		zap.L().Debug("播放器仍在播放，重新发送暂停指令", zap.String("player", playerName), zap.Int("attempt", attempt))
+		if err := mpris.Call(ctx, conn, playerName, method); err != nil {
+			zap.L().Warn("重新发送暂停指令失败", zap.String("player", playerName), zap.Error(err))
+			return fmt.Sprintf("未能暂停（%v）", err)
+		}
+	}
+}
 
-	if newDev.ID != currentDevID {
-		return
+// stillPlaying 返回 names 中仍处于 Playing 状态的播放器
+func stillPlaying(names []string) []string {
+	var playing []string
+	for _, name := range names {
+		if p, ok := lookupPlayer(name); ok && p.PlaybackStatus == mpris.Playing {
+			playing = append(playing, name)
+		}
 	}
+	return playing
+}
 
-	devsMu.RLock()
-	oldDev, exists := GlobalDevices[newDev.ID]
-	devsMu.RUnlock()
+// keepMuted 在播放器拒绝暂停时保持防护静音，直到切回私有设备或用户点击通知中的「仍然继续播放」
+func keepMuted(targets []int, playing []string, dev Device) {
+	zap.L().Warn("仍有播放器未暂停，保持静音", zap.Strings("players", playing), zap.Ints("nodes", targets))
+	policyMu.Lock()
+	policyMuted = append(policyMuted, targets...)
+	policyMu.Unlock()
 
-	if !exists {
+	go func() {
+		id := sendNotification("播放器未能暂停",
+			fmt.Sprintf("%s 仍在播放，已保持 %s 静音", formatPlayers(playing), DeviceDisplayName(dev)),
+			[]string{actionResume, "仍然继续播放"})
+		pausedNotificationID.Store(id)
+	}()
+}
+
+func handleDefaultRouteChange(newDev Device) {
+	sink := sinkPolicy.Sink()
+	currentDevID, ok := GetDeviceIDBySinkName(sink)
+	if !ok || newDev.ID != currentDevID {
 		return
 	}
 
-	nodeID, nOk := GetNodeIDByName(currentDefaultSink)
+	nodeID, nOk := GetSinkIDByName(sink)
 	if !nOk {
 		return
 	}
-	if IsPrivateDevice(oldDev) && IsPublicDevice(newDev) {
-		// FIXME: 无法通过静音输出设备彻底屏蔽正在输出的流
-		zap.L().Info("暂停播放器，触发事件为【设备路由变更】")
-		pauseWithMute(nodeID)
+	classified := newDev
+	if node, ok := registry.Node(nodeID); ok {
+		for _, hw := range hardwareNodes(node) {
+			if hw.Info.Props.DeviceID == newDev.ID {
+				classified = nodeDevice(newDev, hw)
+				break
+			}
+		}
 	}
-}
-
-func onDeviceUpdate(data []byte) {
-	var dev Device
-	if err := json.Unmarshal(data, &dev); err == nil {
-		cancelDelete(dev.ID)
-		handleDefaultRouteChange(dev)
 
-		devsMu.Lock()
-		GlobalDevices[dev.ID] = dev
-		devsMu.Unlock()
+	d := sinkPolicy.Handle(policy.Event{Type: policy.RouteChanged, Class: sinkClass(classified)})
+	if d.From != d.To {
+		go notifyStateChanged()
+		noteAmbiguous(classified, d.To)
 	}
+	// FIXME: 无法通过静音输出设备彻底屏蔽正在输出的流
+	submitTransition(transition{oldSink: sink, newSink: sink, from: d.From, to: d.To, dev: newDev, nodeID: nodeID, reason: "设备路由变更"})
+}
+
+func onDeviceUpdate(dev Device) {
+	cancelDelete(dev.ID)
+	handleRouteAvailability(dev)
+	handleProfileChange(dev)
+	handleDefaultRouteChange(dev)
+	handleSinkRouteChange(dev)
+	handleDefaultSourceRouteChange(dev)
+	noteDeviceAppearance(dev)
+	noteNewDevice(dev)
+
+	registry.PutDevice(dev)
+	promptClassification(dev)
 }
 
 func GetNodeIDByName(nodeName string) (int, bool) {
-	nodesMu.RLock()
-	defer nodesMu.RUnlock()
-	for id, node := range GlobalNodes {
-		if node.Info.Props.NodeName == nodeName {
-			return id, true
-		}
-	}
-	return 0, false
+	return registry.NodeIDByName(nodeName)
 }
 
-func onNodeUpdate(data []byte) {
-	var node Node
-	if err := json.Unmarshal(data, &node); err == nil {
-		cancelDelete(node.ID)
-		nodesMu.Lock()
-		GlobalNodes[node.ID] = node
-		nodesMu.Unlock()
-	}
+// GetSinkIDByName 只在 media.class 属于 sink_media_classes 的节点中查找，
+// 避免匹配到 node.name 相同的 monitor 或双工节点而操作错误的节点
+func GetSinkIDByName(nodeName string) (int, bool) {
+	return registry.NodeIDByName(nodeName, config.SinkMediaClasses...)
+}
+
+func onNodeUpdate(node Node) {
+	cancelDelete(node.ID)
+	registry.PutNode(node)
+	restorePendingNodes()
 }
 
 func handleDefaultSinkChange(metadata []MetadataEntry) {
 	for _, entry := range metadata {
-		if entry.Key != "default.audio.sink" && entry.Key != "default.configured.audio.sink" {
+		switch entry.Key {
+		case "default.audio.sink", "default.configured.audio.sink":
+		case "default.audio.source", "default.configured.audio.source":
+		case "target.object", "target.node":
+			handleStreamTargetChange(entry)
+			continue
+		default:
 			continue
 		}
 
-		var nodeName string
-		switch v := entry.Value.(type) {
-		case map[string]interface{}:
-			nodeName, _ = v["name"].(string)
-		case string:
-			var subMap map[string]interface{}
-			if err := json.Unmarshal([]byte(v), &subMap); err == nil {
-				nodeName, _ = subMap["name"].(string)
-			} else {
-				nodeName = strings.Trim(v, "\"")
-			}
-		}
-
+		nodeName := metadataNodeName(entry.Value)
 		if nodeName == "" {
 			continue
 		}
 
 		switch entry.Key {
 		case "default.audio.sink":
-			oldDevID, oldOk := GetDeviceIDByNodeName(currentDefaultSink)
-			newDevID, newOk := GetDeviceIDByNodeName(nodeName)
-			nodeID, nOk := GetNodeIDByName(nodeName)
-
-			if oldOk && newOk && nOk {
-				devsMu.RLock()
-				oldDev := GlobalDevices[oldDevID]
-				newDev := GlobalDevices[newDevID]
-				devsMu.RUnlock()
-
-				if !IsUserOperation && IsPrivateDevice(oldDev) && IsPublicDevice(newDev) {
-					zap.L().Info("暂停播放器，触发事件为【输出设备变更】")
-					pauseWithMute(nodeID)
+			noteDefaultForGate(nodeName)
+			oldSink := sinkPolicy.Sink()
+			first := oldSink == ""
+			dev, class := classifyNode(nodeName, GetSinkIDByName, sinkClass)
+			d := sinkPolicy.Handle(policy.Event{Type: policy.SinkChanged, Sink: nodeName, Class: class})
+			// 由设置面板、wpctl 等客户端选择的设备即使没有落在会话管理器的写入顺序窗口内也视为用户操作
+			by := sinkInitiator(nodeName)
+			d.User = d.User || by.Kind == initiatorClient
+			go notifyStateChanged()
+			if oldSink != nodeName {
+				noteAmbiguous(dev, class)
+			}
+			emitEvent("sink", map[string]any{
+				"old":       oldSink,
+				"new":       nodeName,
+				"device":    DeviceDisplayName(dev),
+				"class":     class.String(),
+				"user":      d.User,
+				"initiator": by.Name,
+			})
+
+			if nodeID, ok := GetSinkIDByName(nodeName); ok {
+				t := transition{
+					oldSink:   oldSink,
+					newSink:   nodeName,
+					from:      d.From,
+					to:        d.To,
+					dev:       dev,
+					nodeID:    nodeID,
+					reason:    "输出设备变更",
+					user:      d.User,
+					initiator: by.Name,
 				}
+				if hotpluggedDisplay(nodeName) {
+					// HDMI/DisplayPort 接入引起的自动切换：不视为用户操作，且无论原设备类别都暂停
+					t.reason = "HDMI 设备接入"
+					t.user = false
+					t.force = true
+				} else if privateRemovedRecently() && d.To != policy.Private {
+					// 私有设备被移除后回退到其他设备：无论新设备能否识别都暂停
+					t.reason = "私有设备移除"
+					t.user = false
+					t.force = true
+				}
+				submitTransition(t)
 			}
 
-			if currentDefaultSink == "" {
+			if first {
 				zap.L().Info("默认输出设备初始化为", zap.String("sink", nodeName))
+				restoreSavedState()
+				markReady()
 			}
-			currentDefaultSink = nodeName
-			IsUserOperation = false
 		case "default.configured.audio.sink":
-			IsUserOperation = true
+			noteConfiguredSink(nodeName)
+			switch by := noteConfiguredInitiator(nodeName); by.Kind {
+			case initiatorSessionManager, initiatorSelf:
+				// 会话管理器重启后恢复保存的选择、安全输出的切换等不是用户操作
+				zap.L().Debug("默认输出设备的选择不是用户操作", zap.String("sink", nodeName), zap.String("client", by.Name))
+			default:
+				if d := sinkPolicy.Handle(policy.Event{Type: policy.UserConfigured, Sink: nodeName}); d.User || by.Kind == initiatorClient {
+					markTransitionUser(nodeName)
+				}
+			}
+		case "default.audio.source":
+			handleDefaultSourceChange(nodeName)
+		case "default.configured.audio.source":
+			sourcePolicy.Handle(policy.Event{Type: policy.UserConfigured, Sink: nodeName})
 		}
 	}
 }
 
-func onMetadataUpdate(data []byte) {
-	var meta MetadataUpdate
-	if err := json.Unmarshal(data, &meta); err == nil {
-		handleDefaultSinkChange(meta.Metadata)
+func metadataNodeName(value interface{}) string {
+	var subMap map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		subMap = v
+	case string:
+		if err := json.Unmarshal([]byte(v), &subMap); err != nil {
+			return strings.Trim(v, "\"")
+		}
 	}
-}
 
-func onDelete(pwObj PwObject) {
-	isTypeEmpty := pwObj.Type == ""
-	isInfoNull := len(pwObj.Info) == 0 || string(pwObj.Info) == "null"
-
-	if !(isTypeEmpty && isInfoNull) {
-		return
+	if nodeName, ok := subMap["name"].(string); ok {
+		return nodeName
 	}
+	// 部分设置工具以 {"id": N} 的形式记录默认设备
+	if id, ok := subMap["id"].(float64); ok {
+		if node, exists := registry.Node(int(id)); exists {
+			return node.Info.Props.NodeName
+		}
+		zap.L().Debug("无法根据索引找到默认设备节点", zap.Int("id", int(id)))
+	}
+	return ""
+}
 
-	triggerDelete(pwObj.ID)
+func onDelete(id int) {
+	handlePrivateRemoval(id)
+	handleGateTargetRemoved(id)
+	triggerDelete(id)
 }
 
-func StartSmartCleaner(delay time.Duration) (func(int), func(int)) {
+func StartSmartCleaner(delay time.Duration) (func(int), func(int), func()) {
 	pendingDelete := make(map[int]time.Time)
 	input := make(chan int, 100)
 	cancelSignal := make(chan int, 100)
+	resetSignal := make(chan chan struct{})
 
 	go func() {
 		timer := time.NewTimer(delay)
@@ -389,132 +868,348 @@ func StartSmartCleaner(delay time.Duration) (func(int), func(int)) {
 				timer.Stop()
 				timer.Reset(delay)
 
+			case done := <-resetSignal:
+				pendingDelete = make(map[int]time.Time)
+				close(done)
+
 			case <-timer.C:
 				if len(pendingDelete) == 0 {
 					continue
 				}
-				nodesMu.Lock()
-				devsMu.Lock()
 				for id := range pendingDelete {
-					delete(GlobalNodes, id)
-					delete(GlobalDevices, id)
+					registry.Remove(id)
 					zap.L().Debug("清理过期缓存", zap.Int("id", id))
 				}
 				pendingDelete = make(map[int]time.Time)
-				devsMu.Unlock()
-				nodesMu.Unlock()
+				// 录音流被移除后才能判断屏幕共享是否已结束
+				checkScreencast()
 			}
 		}
 	}()
 
-	return func(id int) { input <- id }, func(id int) { cancelSignal <- id }
+	reset := func() {
+		done := make(chan struct{})
+		resetSignal <- done
+		<-done
+	}
+	return func(id int) { input <- id }, func(id int) { cancelSignal <- id }, reset
+}
+
+func resetCaches() {
+	resetDelete()
+
+	registry.Reset()
+
+	sinkPolicy.Reset()
+	sourcePolicy.Reset()
 }
 
 func dispatcher(rawObjects []json.RawMessage) {
-	for _, raw := range rawObjects {
-		var base PwObject
-		if err := json.Unmarshal(raw, &base); err != nil {
-			continue
-		}
-		switch base.Type {
-		case "PipeWire:Interface:Node":
-			onNodeUpdate(raw)
-		case "PipeWire:Interface:Metadata":
-			onMetadataUpdate(raw)
-		case "PipeWire:Interface:Device":
-			onDeviceUpdate(raw)
-		default:
-			onDelete(base)
-		}
-	}
+	handleEvents(pwmon.Decode(rawObjects))
 }
 
-func main() {
-	cfg := zap.NewDevelopmentConfig()
-	if os.Getenv("DEBUG") == "1" {
-		cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
-	} else {
-		cfg.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+func handleEvents(events []pwmon.Event) {
+	defer restoreOnPanic()
+	var linksChanged bool
+	for _, ev := range events {
+		switch ev.Type {
+		case pwmon.NodeChanged:
+			onNodeUpdate(ev.Node)
+		case pwmon.DeviceChanged:
+			onDeviceUpdate(ev.Device)
+		case pwmon.MetadataChanged:
+			handleDefaultSinkChange(ev.Metadata)
+		case pwmon.LinkChanged:
+			registry.PutLink(ev.Link)
+			linksChanged = true
+		case pwmon.ClientChanged:
+			onClientUpdate(ev.Client)
+		case pwmon.Removed:
+			onDelete(ev.ID)
+		}
 	}
-	cfg.EncoderConfig.TimeKey = ""
-	cfg.EncoderConfig.CallerKey = ""
-	logger, _ := cfg.Build()
-	zap.ReplaceGlobals(logger)
-	defer logger.Sync()
+	if linksChanged {
+		handleVirtualSinkLinks()
+	}
+	checkScreencast()
+	reportDecodeFailures()
+	reportStartup()
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
+func runSubprocessBackend(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	zap.L().Info("正在启动控制进程...")
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-	cliCmd := exec.CommandContext(ctx, "pw-cli")
-	var err error
-	pwCliStdin, err = cliCmd.StdinPipe()
+	ctl, stopController, err := startController(ctx, func(err error) {
+		zap.L().Warn("控制进程已退出", zap.Error(err))
+		cancel()
+	})
 	if err != nil {
-		zap.L().Fatal("无法创建控制进程输入管道", zap.Error(err))
+		zap.L().Error("无法启动控制进程", zap.Error(err))
+		return err
 	}
-	if err := cliCmd.Start(); err != nil {
-		zap.L().Fatal("无法启动控制进程", zap.Error(err))
+	defer stopController()
+
+	controllerMu.Lock()
+	controller = ctl
+	controllerMu.Unlock()
+	defer func() {
+		controllerMu.Lock()
+		controller = nil
+		controllerMu.Unlock()
+	}()
+
+	zap.L().Info("正在启动监听进程...")
+
+	monitor := &pwmon.Monitor{Compat: probeDumpCompat(ctx)}
+	if recordFile != nil {
+		monitor.Record = recordFile
 	}
+	batches := make(chan []pwmon.Event)
+	// 快照与监听事件在同一协程中处理，避免快照覆盖较新的事件
+	snapshots := StartReconciler(ctx, config.ReconcileInterval)
 
+	wg.Add(2)
 	go func() {
-		err := cliCmd.Wait()
-		zap.L().Warn("控制进程已退出", zap.Error(err))
+		defer wg.Done()
+		err := monitor.Run(ctx, batches)
+		zap.L().Warn("监听进程已退出", zap.Error(err))
 		cancel()
 	}()
 
-	zap.L().Info("正在启动监听进程...")
+	go func() {
+		defer wg.Done()
+		zap.L().Info("正在监听事件...")
+		for {
+			select {
+			case events := <-batches:
+				handleEvents(events)
+			case s := <-snapshots:
+				reconcileCaches(s)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	dumpCmd := exec.CommandContext(ctx, "pw-dump", "--monitor", "--no-colors")
-	stdout, err := dumpCmd.StdoutPipe()
-	if err != nil {
-		zap.L().Fatal("无法创建监听进程输出管道", zap.Error(err))
+	<-ctx.Done()
+	return nil
+}
+
+func superviseBackend(ctx context.Context) {
+	const (
+		minBackoff = time.Second
+		maxBackoff = 30 * time.Second
+	)
+	backoff := minBackoff
+
+	for {
+		started := time.Now()
+
+		err := newBackend(config.Backend).Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		resetCaches()
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		zap.L().Warn("与 PipeWire 的连接已中断，稍后将重新连接", zap.Duration("backoff", backoff), zap.Error(err))
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func watchReload() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			sdNotify("RELOADING=1")
+			if conf, err := LoadConfig(ConfigPaths()...); err != nil {
+				zap.L().Warn("重新加载配置文件失败，继续使用当前配置", zap.Strings("paths", ConfigPaths()), zap.Error(err))
+			} else {
+				config = conf
+				applySessionManager()
+				loadDeviceChoices()
+				zap.L().Info("配置文件已重新加载", zap.String("path", ConfigPath()))
+			}
+			sdNotify("READY=1")
+		}
+	}()
+}
+
+func newLogger(debug, quiet bool, format string) (*zap.Logger, error) {
+	var cfg zap.Config
+	switch format {
+	case "json":
+		cfg = zap.NewProductionConfig()
+		cfg.Sampling = nil
+	case "console", "journald":
+		cfg = zap.NewDevelopmentConfig()
+		cfg.EncoderConfig.TimeKey = ""
+		cfg.EncoderConfig.CallerKey = ""
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
 	}
-	if err := dumpCmd.Start(); err != nil {
-		zap.L().Fatal("无法启动监听进程", zap.Error(err))
+
+	switch {
+	case debug:
+		cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+	case quiet:
+		cfg.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+	default:
+		cfg.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+	}
+	cfg.DisableStacktrace = !debug
+	if format == "journald" {
+		core, err := newJournalCore(cfg.Level)
+		if err != nil {
+			return nil, err
+		}
+		return zap.New(core), nil
 	}
+	return cfg.Build()
+}
 
-	zap.L().Info("正在连接会话总线...")
+func main() {
+	var (
+		debug      bool
+		quiet      bool
+		logFormat  string
+		replayPath string
+		recordPath string
+		eventsPath string
+	)
+	flag.BoolVar(&debug, "v", os.Getenv("DEBUG") == "1", "输出调试日志")
+	flag.BoolVar(&debug, "debug", os.Getenv("DEBUG") == "1", "输出调试日志")
+	flag.BoolVar(&quiet, "quiet", false, "仅输出警告与错误")
+	flag.StringVar(&logFormat, "log-format", "console", "日志格式：console、json 或 journald（直接写入 systemd 日志并附带结构化字段）")
+	flag.BoolVar(&dryRun, "dry-run", false, "仅记录将要执行的操作，不实际静音节点或暂停播放器")
+	flag.StringVar(&replayPath, "replay", "", "从文件回放录制的 pw-dump 事件流而不连接 PipeWire（隐含 --dry-run）")
+	flag.StringVar(&recordPath, "record", "", "将 pw-dump 事件流保存到文件，供 --replay 使用")
+	flag.StringVar(&eventsPath, "events-json", "", "将检测到的事件与执行的动作以每行一个 JSON 对象的形式写入文件或 FIFO（- 表示标准输出）")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
 
-	dbusConn, err = dbus.SessionBus()
+	logger, err := newLogger(debug, quiet, logFormat)
 	if err != nil {
-		zap.L().Fatal("无法连接会话总线", zap.Error(err))
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	zap.ReplaceGlobals(logger)
+	defer logger.Sync()
+
+	if replayPath != "" {
+		dryRun = true
+	}
+	if dryRun {
+		zap.L().Info("以 dry-run 模式运行：仅记录将要执行的操作")
+	}
+	if recordPath != "" {
+		f, err := os.Create(recordPath)
+		if err != nil {
+			zap.L().Fatal("无法创建录制文件", zap.String("path", recordPath), zap.Error(err))
+		}
+		defer f.Close()
+		recordFile = f
+	}
+	if eventsPath != "" {
+		w, err := openEvents(eventsPath)
+		if err != nil {
+			zap.L().Fatal("无法打开事件输出", zap.String("path", eventsPath), zap.Error(err))
+		}
+		defer w.Close()
+		startEvents(w)
 	}
 
-	go func() {
-		<-dbusConn.Context().Done()
-		zap.L().Warn("已从会话总线断开")
-		cancel()
-	}()
+	if conf, err := LoadConfig(ConfigPaths()...); err != nil {
+		zap.L().Warn("读取配置文件失败，使用默认配置", zap.Strings("paths", ConfigPaths()), zap.Error(err))
+	} else {
+		config = conf
+	}
+	applySessionManager()
+	loadDeviceChoices()
 
-	triggerDelete, cancelDelete = StartSmartCleaner(2 * time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	go func() {
-		zap.L().Info("正在监听事件...")
-		decoder := json.NewDecoder(stdout)
-		for {
-			var rawObjects []json.RawMessage
-			if err := decoder.Decode(&rawObjects); err != nil {
-				if err == io.EOF {
-					break
-				}
-				zap.L().Warn("从监听进程解析事件发生错误", zap.Error(err))
-				break
+	triggerDelete, cancelDelete, resetDelete = StartSmartCleaner(2 * time.Second)
+	loadState()
+
+	switch {
+	case config.SessionBus == "none":
+		enterHeadless(nil)
+	case config.SessionBus != "required" && replayPath == "" && !sessionBusAvailable():
+		enterHeadless(errors.New("DBUS_SESSION_BUS_ADDRESS 未设置且找不到会话总线的套接字"))
+		go waitForSessionBus(ctx)
+	default:
+		zap.L().Info("正在连接会话总线...")
+		if err := connectSessionBus(); err != nil {
+			switch {
+			case replayPath != "":
+				zap.L().Warn("无法连接会话总线，回放时不查询播放器", zap.Error(err))
+			case config.SessionBus == "required":
+				zap.L().Fatal("无法连接会话总线", zap.Error(err))
+			default:
+				enterHeadless(err)
+				go waitForSessionBus(ctx)
 			}
-			dispatcher(rawObjects)
+		} else {
+			go superviseSessionBus(ctx)
 		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-stop
+		zap.L().Info("收到退出信号", zap.String("signal", sig.String()))
 		cancel()
 	}()
 
+	startBluezMonitor()
+	startLogindMonitor()
+	startSessionMonitor()
+	startDNDMonitor(ctx)
+	startGate(ctx)
+	watchReload()
+	StartWatchdog()
+	StartScheduler()
+
+	if replayPath != "" {
+		if err := runReplay(replayPath); err != nil {
+			zap.L().Error("回放事件失败", zap.String("path", replayPath), zap.Error(err))
+		}
+		cancelPendingTransition()
+		return
+	}
+
+	backendCtx, cancelBackend := context.WithCancel(context.Background())
+	backendDone := make(chan struct{})
 	go func() {
-		err := dumpCmd.Wait()
-		zap.L().Warn("监听进程已退出", zap.Error(err))
-		cancel()
+		superviseBackend(backendCtx)
+		close(backendDone)
 	}()
 
 	<-ctx.Done()
-	
-	zap.L().Info("子进程意外退出，正在退出主进程...")
-	time.Sleep(500 * time.Millisecond)
-	os.Exit(1)
-}
\ No newline at end of file
+
+	zap.L().Info("正在退出主进程...")
+	sdNotify("STOPPING=1")
+	shutdown(cancelBackend, backendDone)
+	logger.Sync()
+}
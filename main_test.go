@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// builtinSpeaker 为板载声卡切换到扬声器路由后的设备
+const builtinSpeaker = `{"id":40,"info":{"props":{"device.name":"alsa_card.pci-0000_00_1f.3","device.description":"Built-in Audio"},
+	"params":{"Route":[{"index":2,"name":"analog-output-speaker","direction":"Output","available":"yes","info":[1,"port.type","speaker"]}]}}}`
+
+// setupDispatchTest 以板载声卡的耳机为默认输出完成初始化，另有 USB 音箱可以切换
+func setupDispatchTest(t *testing.T) *fakeController {
+	t.Helper()
+	fake := setupTest(t)
+	putDevice(t, builtinDevice)
+	putNode(t, builtinSink)
+	putDevice(t, usbDevice)
+	putNode(t, usbSink)
+	setMetadata("default.audio.sink", "alsa_out")
+	fake.reset()
+	return fake
+}
+
+// changeRoute 按 onDeviceUpdate 的顺序先处理路由变更，再更新缓存
+func changeRoute(t *testing.T, data string) {
+	t.Helper()
+	var dev Device
+	if err := json.Unmarshal([]byte(data), &dev); err != nil {
+		t.Fatal(err)
+	}
+	handleDefaultRouteChange(dev)
+	registry.PutDevice(dev)
+}
+
+func checkMuted(t *testing.T, fake *fakeController, muted, unmuted []int) {
+	t.Helper()
+	if got := fake.muted(true); !slices.Equal(got, muted) {
+		t.Errorf("静音的节点为 %v，期望 %v", got, muted)
+	}
+	if got := fake.muted(false); !slices.Equal(got, unmuted) {
+		t.Errorf("取消静音的节点为 %v，期望 %v", got, unmuted)
+	}
+}
+
+func TestDefaultSinkChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(t *testing.T)
+		muted   []int
+		unmuted []int
+	}{
+		{
+			name:  "会话管理器切换到公共设备",
+			run:   func(t *testing.T) { setMetadata("default.audio.sink", "usb_out") },
+			muted: []int{51},
+		},
+		{
+			name: "切回耳机后取消静音",
+			run: func(t *testing.T) {
+				setMetadata("default.audio.sink", "usb_out")
+				setMetadata("default.audio.sink", "alsa_out")
+			},
+			muted:   []int{51},
+			unmuted: []int{51},
+		},
+		{
+			name: "用户选择公共设备",
+			run: func(t *testing.T) {
+				setMetadata("default.configured.audio.sink", "usb_out")
+				setMetadata("default.audio.sink", "usb_out")
+			},
+		},
+		{
+			name: "用户切回耳机时撤销自动切换留下的静音",
+			run: func(t *testing.T) {
+				setMetadata("default.audio.sink", "usb_out")
+				setMetadata("default.configured.audio.sink", "alsa_out")
+				setMetadata("default.audio.sink", "alsa_out")
+			},
+			muted:   []int{51},
+			unmuted: []int{51},
+		},
+		{
+			name: "用户选择公共设备后会话管理器再次切换",
+			run: func(t *testing.T) {
+				setMetadata("default.configured.audio.sink", "usb_out")
+				setMetadata("default.audio.sink", "usb_out")
+				setMetadata("default.audio.sink", "alsa_out")
+				setMetadata("default.audio.sink", "usb_out")
+			},
+			muted: []int{51},
+		},
+		{
+			name: "默认设备未变化",
+			run:  func(t *testing.T) { setMetadata("default.audio.sink", "alsa_out") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := setupDispatchTest(t)
+			tt.run(t)
+			checkMuted(t, fake, tt.muted, tt.unmuted)
+		})
+	}
+}
+
+func TestDefaultRouteChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(t *testing.T)
+		muted   []int
+		unmuted []int
+	}{
+		{
+			name:  "当前设备切换到扬声器路由",
+			run:   func(t *testing.T) { changeRoute(t, builtinSpeaker) },
+			muted: []int{50},
+		},
+		{
+			name: "切回耳机路由后取消静音",
+			run: func(t *testing.T) {
+				changeRoute(t, builtinSpeaker)
+				changeRoute(t, builtinDevice)
+			},
+			muted:   []int{50},
+			unmuted: []int{50},
+		},
+		{
+			name: "路由未变化",
+			run:  func(t *testing.T) { changeRoute(t, builtinDevice) },
+		},
+		{
+			name: "其他设备的路由变更不处理",
+			run:  func(t *testing.T) { changeRoute(t, usbDevice) },
+		},
+		{
+			name: "用户选择公共设备后该设备的路由变更",
+			run: func(t *testing.T) {
+				setMetadata("default.configured.audio.sink", "usb_out")
+				setMetadata("default.audio.sink", "usb_out")
+				changeRoute(t, usbDevice)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := setupDispatchTest(t)
+			tt.run(t)
+			checkMuted(t, fake, tt.muted, tt.unmuted)
+		})
+	}
+}
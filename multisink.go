@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+// streamExplicitlyTargets 判断流是否通过 target.object/node.target 显式指定了 sink，
+// 跟随默认输出设备的流由默认设备的切换逻辑处理
+func streamExplicitlyTargets(stream Node, sink Node) bool {
+	target := streamTarget(stream)
+	if target == "" {
+		return false
+	}
+	return target == sink.Info.Props.NodeName ||
+		target == strconv.Itoa(sink.ID) ||
+		target == sink.Info.Props.ObjectSerial.String()
+}
+
+// pinnedStreams 返回设备上各个 sink 中被显式指定输出或实际连接到这些 sink 的流节点
+func pinnedStreams(devID int) []Node {
+	nodes := registry.Nodes()
+
+	var streams []Node
+	for _, sink := range nodes {
+		if sink.Info.Props.DeviceID != devID || sink.Info.Props.MediaClass != "Audio/Sink" {
+			continue
+		}
+		for _, node := range nodes {
+			if node.Info.Props.MediaClass != "Stream/Output/Audio" {
+				continue
+			}
+			if linked, _ := streamLinkedTo(node, sink); linked || streamExplicitlyTargets(node, sink) {
+				streams = append(streams, node)
+			}
+		}
+	}
+	return streams
+}
+
+// streamOwner 返回判断播放器是否拥有 ids 中的流的函数：按 playerStreams 的进程号关联判断，
+// 找不到播放器或其输出流时才按流的应用名称 apps 匹配
+func streamOwner(ids []int, apps []string) func(ctx context.Context, playerName string) bool {
+	return func(ctx context.Context, playerName string) bool {
+		if p, ok := lookupPlayer(playerName); ok {
+			if streams := playerStreams(p); len(streams) > 0 {
+				return slices.ContainsFunc(streams, func(id int) bool { return slices.Contains(ids, id) })
+			}
+		}
+		return matchPlayer(playerName, playerIdentity(ctx, playerName), apps)
+	}
+}
+
+// resumeStreamOwners 只恢复待恢复列表中拥有 ids 中的流的播放器，其余暂停的播放器保持不变
+func resumeStreamOwners(entry int, ids []int, apps []string, nodeID int, reason string, dev Device) {
+	pausedMu.Lock()
+	candidates := slices.Clone(pausedPlayers)
+	pausedMu.Unlock()
+
+	// 查询播放器名称可能需要调用 D-Bus，不阻塞事件处理
+	go func() {
+		defer restoreOnPanic()
+		owns := streamOwner(ids, apps)
+		ctx, cancel := context.WithTimeout(context.Background(), config.PlayerCallTimeout)
+		defer cancel()
+
+		var players []string
+		for _, name := range candidates {
+			if owns(ctx, name) {
+				players = append(players, name)
+			}
+		}
+		if len(players) == 0 {
+			zap.L().Debug("没有需要恢复的播放器", zap.String("reason", reason), zap.Strings("streams", streamNames(ids)))
+			return
+		}
+		resumePlayersAsync(entry, players, nodeID, reason, dev)
+	}()
+}
+
+// handleSinkRouteChange 处理非默认输出设备的路由变更：仅暂停输出到该设备的流所属的播放器
+func handleSinkRouteChange(newDev Device) {
+	if !config.Streams.PerSink {
+		return
+	}
+	if devID, ok := GetDeviceIDBySinkName(sinkPolicy.Sink()); ok && devID == newDev.ID {
+		return
+	}
+
+	oldDev, exists := registry.Device(newDev.ID)
+	if !exists {
+		return
+	}
+
+	action := transitionAction(sinkClass(oldDev), sinkClass(newDev), false)
+	if action == policy.None {
+		return
+	}
+
+	streams := pinnedStreams(newDev.ID)
+	if len(streams) == 0 {
+		return
+	}
+
+	var (
+		ids  []int
+		apps []string
+	)
+	for _, s := range streams {
+		ids = append(ids, s.ID)
+		props := s.Info.Props
+		for _, name := range []string{props.ApplicationName.String(), props.ApplicationBinary.String()} {
+			if name != "" {
+				apps = append(apps, name)
+			}
+		}
+	}
+
+	switch action {
+	case policy.Pause:
+		zap.L().Info("暂停输出到该设备的播放器，触发事件为【非默认设备路由变更】",
+			zap.String("device", DeviceDisplayName(newDev)), zap.Strings("apps", apps))
+		id := addHistory(HistoryEntry{Trigger: "非默认设备路由变更", Device: DeviceDisplayName(newDev), Action: "pause"})
+		pauseMatching(id, ids[0], ids, "非默认设备路由变更", newDev, streamOwner(ids, apps))
+	case policy.Resume:
+		zap.L().Info("恢复播放器，触发事件为【非默认设备路由变更】", zap.String("device", DeviceDisplayName(newDev)))
+		id := addHistory(HistoryEntry{Trigger: "非默认设备路由变更", Device: DeviceDisplayName(newDev), Action: "resume"})
+		resumeStreamOwners(id, ids, apps, ids[0], "非默认设备路由变更", newDev)
+	}
+}
+
+var (
+	streamMovesMu sync.Mutex
+	// 通过 target.object/target.node 元数据被移动到其他 sink 的流，值为目标 sink 的节点索引
+	streamMoves = make(map[int]int)
+)
+
+func metadataString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.Trim(v, "\"")
+	case float64:
+		return strconv.Itoa(int(v))
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// resolveSink 按节点名称、object.serial 或节点索引查找 sink
+func resolveSink(target string) (Node, bool) {
+	for _, node := range registry.Nodes() {
+		if node.Info.Props.MediaClass != "Audio/Sink" {
+			continue
+		}
+		if target == node.Info.Props.NodeName || target == node.Info.Props.ObjectSerial.String() || target == strconv.Itoa(node.ID) {
+			return node, true
+		}
+	}
+	return Node{}, false
+}
+
+// handleStreamTargetChange 处理用户在 pavucontrol 等工具中将单个流移动到其他 sink 的操作，
+// 从私有设备移动到公共设备时仅暂停该流所属的播放器，移回私有设备时也只恢复该播放器
+func handleStreamTargetChange(entry MetadataEntry) {
+	stream, ok := registry.Node(entry.Subject)
+	if !ok || stream.Info.Props.MediaClass != "Stream/Output/Audio" {
+		return
+	}
+
+	var newSink Node
+	target := metadataString(entry.Value)
+	if target != "" && target != "-1" {
+		if newSink, ok = resolveSink(target); !ok {
+			zap.L().Debug("无法找到流的目标设备", zap.Int("stream", stream.ID), zap.String("target", target))
+			return
+		}
+	} else if id, ok := GetSinkIDByName(sinkPolicy.Sink()); ok {
+		newSink, _ = registry.Node(id)
+	}
+
+	streamMovesMu.Lock()
+	oldID, moved := streamMoves[stream.ID]
+	if target != "" && target != "-1" {
+		streamMoves[stream.ID] = newSink.ID
+	} else {
+		delete(streamMoves, stream.ID)
+	}
+	for id := range streamMoves {
+		if _, exists := registry.Node(id); !exists {
+			delete(streamMoves, id)
+		}
+	}
+	streamMovesMu.Unlock()
+
+	if !config.Streams.PerSink || sinkPolicy.Sink() == "" {
+		return
+	}
+	if !moved {
+		oldID, _ = GetSinkIDByName(sinkPolicy.Sink())
+	}
+	oldSink, ok := registry.Node(oldID)
+	if !ok || oldSink.ID == newSink.ID {
+		return
+	}
+
+	_, from := classifyNode(oldSink.Info.Props.NodeName, GetSinkIDByName, sinkClass)
+	dev, to := classifyNode(newSink.Info.Props.NodeName, GetSinkIDByName, sinkClass)
+
+	props := stream.Info.Props
+	var apps []string
+	for _, name := range []string{props.ApplicationName.String(), props.ApplicationBinary.String()} {
+		if name != "" {
+			apps = append(apps, name)
+		}
+	}
+	entryFor := func(action string) HistoryEntry {
+		return HistoryEntry{
+			Trigger: "应用流切换输出设备",
+			OldSink: oldSink.Info.Props.NodeName,
+			NewSink: newSink.Info.Props.NodeName,
+			Device:  DeviceDisplayName(dev),
+			Action:  action,
+		}
+	}
+
+	switch transitionAction(from, to, true) {
+	case policy.Pause:
+		zap.L().Info("暂停该流所属的播放器，触发事件为【应用流切换输出设备】",
+			zap.Int("stream", stream.ID), zap.String("sink", newSink.Info.Props.NodeName), zap.Strings("apps", apps))
+		id := addHistory(entryFor("pause"))
+		pauseMatching(id, stream.ID, []int{stream.ID}, "应用流切换输出设备", dev, streamOwner([]int{stream.ID}, apps))
+	case policy.Resume:
+		zap.L().Info("恢复播放器，触发事件为【应用流切换输出设备】", zap.Int("stream", stream.ID))
+		id := addHistory(entryFor("resume"))
+		resumeStreamOwners(id, []int{stream.ID}, apps, stream.ID, "应用流切换输出设备", dev)
+	}
+}
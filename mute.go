@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type mutedState struct {
+	byVolume bool
+	volumes  []float64
+	refs     int
+}
+
+// setParamsAttempts 为每条节点参数指令的最多尝试次数
+const setParamsAttempts = 3
+
+var (
+	mutedMu    sync.Mutex
+	mutedNodes = make(map[int]mutedState)
+)
+
+func nodeProps(nodeID int) (NodeProps, bool) {
+	node, ok := registry.Node(nodeID)
+	if !ok {
+		return NodeProps{}, false
+	}
+
+	var props NodeProps
+	found := false
+	for _, p := range node.Info.Params.Props {
+		if p.Mute != nil && props.Mute == nil {
+			props.Mute = p.Mute
+			found = true
+		}
+		if len(p.ChannelVolumes) > 0 && props.ChannelVolumes == nil {
+			props.ChannelVolumes = append([]float64(nil), p.ChannelVolumes...)
+			found = true
+		}
+	}
+	return props, found
+}
+
+func formatVolumes(volumes []float64) string {
+	parts := make([]string, len(volumes))
+	for i, v := range volumes {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return "[ " + strings.Join(parts, ", ") + " ]"
+}
+
+func filledVolumes(n int, v float64) []float64 {
+	volumes := make([]float64, n)
+	for i := range volumes {
+		volumes[i] = v
+	}
+	return volumes
+}
+
+func setNodeParams(nodeID int, values map[string]any) {
+	if dryRun {
+		zap.L().Info("[dry-run] 将设置节点参数", zap.Int("id", nodeID), zap.String("node", nodeNameByID(nodeID)), zap.Any("props", values))
+		return
+	}
+
+	controllerMu.Lock()
+	defer controllerMu.Unlock()
+
+	if controller == nil {
+		return
+	}
+
+	// 指令失败时按退避重试；输入管道损坏时先重新启动控制进程
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := controller.SetNodeProps(nodeID, values)
+		if err == nil {
+			return
+		}
+		var cmdErr *commandError
+		if errors.As(err, &cmdErr) {
+			zap.L().Error("PipeWire 拒绝了节点参数指令", zap.Int("id", nodeID), zap.Error(err))
+			return
+		}
+		restartable, canRestart := controller.(restartableController)
+		if attempt == setParamsAttempts || stopping.Load() {
+			zap.L().Error("向 PipeWire 发送指令失败", zap.Int("id", nodeID), zap.Int("attempts", attempt), zap.Error(err))
+			if canRestart && !stopping.Load() {
+				// 控制进程持续无响应，重新启动以免后续指令同样失败
+				restartable.Restart()
+			}
+			return
+		}
+		zap.L().Warn("向 PipeWire 发送指令失败，稍后重试", zap.Int("id", nodeID), zap.Duration("backoff", backoff), zap.Error(err))
+		if canRestart && (errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed)) {
+			if err := restartable.Restart(); err != nil {
+				zap.L().Error("无法重新启动控制进程", zap.Error(err))
+				return
+			}
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func setPipewireMute(nodeID int, mute bool) {
+	if mute {
+		muteNode(nodeID)
+	} else {
+		unmuteNode(nodeID)
+	}
+}
+
+func muteNode(nodeID int) {
+	if stopping.Load() {
+		return
+	}
+	props, _ := nodeProps(nodeID)
+
+	state := mutedState{
+		byVolume: config.MuteMethod == "volume" || props.Mute == nil,
+		volumes:  props.ChannelVolumes,
+	}
+	if state.byVolume && len(state.volumes) == 0 {
+		state.volumes = []float64{1.0, 1.0}
+	}
+
+	// 同一节点可能被多个策略同时静音，只有全部释放后才真正恢复
+	mutedMu.Lock()
+	prev, exists := mutedNodes[nodeID]
+	if exists {
+		state = prev
+	}
+	state.refs++
+	mutedNodes[nodeID] = state
+	mutedMu.Unlock()
+	if !exists {
+		saveState()
+	}
+
+	zeros := filledVolumes(len(state.volumes), 0)
+	if !exists && fadeEnabled() && len(state.volumes) > 0 {
+		rampVolumes(nodeID, state.volumes, zeros)
+	}
+
+	if state.byVolume {
+		setNodeParams(nodeID, map[string]any{"channelVolumes": zeros})
+	} else if !exists && fadeEnabled() && len(state.volumes) > 0 {
+		// 淡出后设置静音标志，并将音量还原，避免影响用户设置的音量
+		setNodeParams(nodeID, map[string]any{"mute": true, "channelVolumes": state.volumes})
+	} else {
+		setNodeParams(nodeID, map[string]any{"mute": true})
+	}
+}
+
+func unmuteNode(nodeID int) {
+	releaseNode(nodeID, false)
+}
+
+func releaseNode(nodeID int, force bool) {
+	mutedMu.Lock()
+	state, exists := mutedNodes[nodeID]
+	if exists {
+		state.refs--
+		if state.refs > 0 && !force {
+			mutedNodes[nodeID] = state
+			mutedMu.Unlock()
+			return
+		}
+		delete(mutedNodes, nodeID)
+	}
+	mutedMu.Unlock()
+
+	if !exists {
+		zap.L().Debug("节点未被静音，无需恢复", zap.Int("id", nodeID))
+		return
+	}
+	saveState()
+
+	fade := fadeEnabled() && len(state.volumes) > 0
+	zeros := filledVolumes(len(state.volumes), 0)
+	switch {
+	case state.byVolume && fade:
+		rampVolumes(nodeID, zeros, state.volumes)
+	case state.byVolume:
+		setNodeParams(nodeID, map[string]any{"channelVolumes": state.volumes})
+	case fade:
+		setNodeParams(nodeID, map[string]any{"mute": false, "channelVolumes": zeros})
+		rampVolumes(nodeID, zeros, state.volumes)
+	default:
+		setNodeParams(nodeID, map[string]any{"mute": false})
+	}
+}
+
+func unmuteAll() {
+	mutedMu.Lock()
+	ids := make([]int, 0, len(mutedNodes))
+	for id := range mutedNodes {
+		ids = append(ids, id)
+	}
+	mutedMu.Unlock()
+
+	for _, id := range ids {
+		releaseNode(id, true)
+	}
+}
+
+var (
+	duckedMu    sync.Mutex
+	duckedNodes = make(map[int][]float64)
+)
+
+// duckNode 将节点音量降至原音量的 level 倍（按界面显示的百分比计算）
+func duckNode(nodeID int, level float64) {
+	if stopping.Load() {
+		return
+	}
+	props, _ := nodeProps(nodeID)
+	volumes := props.ChannelVolumes
+	if len(volumes) == 0 {
+		volumes = []float64{1.0, 1.0}
+	}
+
+	duckedMu.Lock()
+	if prev, exists := duckedNodes[nodeID]; exists {
+		volumes = prev
+	} else {
+		duckedNodes[nodeID] = volumes
+	}
+	duckedMu.Unlock()
+	saveState()
+
+	// channelVolumes 为线性值，界面显示的百分比为其立方根
+	factor := level * level * level
+	ducked := make([]float64, len(volumes))
+	for i, v := range volumes {
+		ducked[i] = v * factor
+	}
+	setNodeParams(nodeID, map[string]any{"channelVolumes": ducked})
+}
+
+func restoreDucked() {
+	duckedMu.Lock()
+	nodes := duckedNodes
+	duckedNodes = make(map[int][]float64)
+	duckedMu.Unlock()
+
+	if len(nodes) == 0 {
+		return
+	}
+	saveState()
+
+	for id, volumes := range nodes {
+		zap.L().Info("恢复输出音量", zap.Int("id", id))
+		setNodeParams(id, map[string]any{"channelVolumes": volumes})
+	}
+}
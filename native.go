@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nsplup/pw-autopaused/pipewire"
+	"go.uber.org/zap"
+)
+
+func runNativeBackend(ctx context.Context) error {
+	zap.L().Info("正在连接 PipeWire...", zap.String("socket", pipewire.SocketPath()))
+
+	client, err := pipewire.Dial(func(globals []pipewire.Global) {
+		rawObjects := make([]json.RawMessage, 0, len(globals))
+		for _, g := range globals {
+			raw, err := json.Marshal(g)
+			if err != nil {
+				zap.L().Warn("无法序列化 PipeWire 对象", zap.Uint32("id", g.ID), zap.Error(err))
+				continue
+			}
+			rawObjects = append(rawObjects, raw)
+		}
+		if recordFile != nil {
+			if err := json.NewEncoder(recordFile).Encode(rawObjects); err != nil {
+				zap.L().Warn("写入录制文件失败", zap.Error(err))
+			}
+		}
+		dispatcher(rawObjects)
+	})
+	if err != nil {
+		zap.L().Error("无法连接 PipeWire", zap.Error(err))
+		return err
+	}
+
+	controllerMu.Lock()
+	controller = nativeController{client: client}
+	controllerMu.Unlock()
+	defer func() {
+		controllerMu.Lock()
+		controller = nil
+		controllerMu.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		client.Close()
+		<-client.Done()
+		return nil
+	case <-client.Done():
+		return client.Err()
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+const actionResume = "resume"
+
+var pausedNotificationID atomic.Uint32
+
+func sendNotification(summary, body string, actions []string) uint32 {
+	conn := sessionBus()
+	if !config.Notify.Enabled || conn == nil {
+		return 0
+	}
+	if config.DND.SkipNotifications && isDND() {
+		zap.L().Debug("勿扰模式已开启，不发送通知", zap.String("summary", summary))
+		return 0
+	}
+	if dryRun {
+		zap.L().Info("[dry-run] 将发送通知", zap.String("summary", summary), zap.String("body", body))
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if actions == nil {
+		actions = []string{}
+	}
+
+	var id uint32
+	obj := conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	err := obj.CallWithContext(ctx, "org.freedesktop.Notifications.Notify", 0,
+		"pw-autopaused", uint32(0), "audio-volume-muted", summary, body,
+		actions, map[string]dbus.Variant{}, int32(-1)).Store(&id)
+	if err != nil {
+		zap.L().Warn("发送桌面通知失败", zap.Error(err))
+		return 0
+	}
+	return id
+}
+
+func notifyPaused(reason string, dev Device) {
+	go func() {
+		id := sendNotification("播放已暂停",
+			fmt.Sprintf("输出已切换至 %s（触发事件：%s）", DeviceDisplayName(dev), reason),
+			[]string{actionResume, "仍然继续播放"})
+		pausedNotificationID.Store(id)
+	}()
+}
+
+func startNotificationActions() {
+	conn := sessionBus()
+	if !config.Notify.Enabled || conn == nil {
+		return
+	}
+
+	err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.Notifications"),
+		dbus.WithMatchMember("ActionInvoked"),
+	)
+	if err != nil {
+		zap.L().Warn("无法订阅通知操作事件", zap.Error(err))
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	go func() {
+		for sig := range signals {
+			if sig.Name != "org.freedesktop.Notifications.ActionInvoked" || len(sig.Body) < 2 {
+				continue
+			}
+			id, _ := sig.Body[0].(uint32)
+			action, _ := sig.Body[1].(string)
+			if handlePromptAction(id, action) {
+				continue
+			}
+			if id == 0 || id != pausedNotificationID.Load() || action != actionResume {
+				continue
+			}
+
+			zap.L().Info("用户选择仍然继续播放，正在恢复播放器")
+			unmuteAll()
+
+			ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
+			if resumePausedPlayers(ctx, 0, true, nil) > 0 {
+				runHook(HookResume, HookContext{Reason: "用户操作"})
+			}
+			cancel()
+		}
+	}()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+const (
+	panicTrigger   = "紧急暂停"
+	recoverTrigger = "解除紧急暂停"
+)
+
+// panicPause 由用户主动触发，不受 snooze 与通话的限制：静音默认输出并暂停所有播放器，
+// 静音保持到 resumeNow 或切回私有设备
+func panicPause(reason string) {
+	zap.L().Info("静音并暂停所有播放器，触发事件为【" + reason + "】")
+	id := addHistory(HistoryEntry{Trigger: reason, NewSink: sinkPolicy.Sink(), Action: "pause"})
+	runHook(HookPause, HookContext{Reason: reason})
+
+	if nodeID, ok := GetSinkIDByName(sinkPolicy.Sink()); ok {
+		gated := isGateNode(nodeID)
+		if gated {
+			keepGate()
+		}
+		// 防护输出未能及时断开时仍然静音
+		if !gated || !holdGate() {
+			targets := guardTargets(nodeID)
+			for _, id := range targets {
+				setPipewireMute(id, true)
+			}
+			policyMu.Lock()
+			policyMuted = append(policyMuted, targets...)
+			policyMu.Unlock()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
+	defer cancel()
+	pauseAllPlayers(ctx, id)
+}
+
+// resumeNow 撤销所有静音与降低音量，并立即恢复被暂停的播放器，不受恢复窗口限制
+func resumeNow(reason string) {
+	zap.L().Info("立即恢复播放，触发事件为【" + reason + "】")
+	releasePolicy()
+	unmuteAll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
+	defer cancel()
+	if resumePausedPlayers(ctx, 0, true, nil) > 0 {
+		runHook(HookResume, HookContext{Reason: reason})
+	}
+}
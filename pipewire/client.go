@@ -0,0 +1,705 @@
+package pipewire
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+const (
+	coreID     = 0
+	clientID   = 1
+	registryID = 2
+
+	protocolVersion = 3
+)
+
+const (
+	coreMethodHello       = 1
+	coreMethodSync        = 2
+	coreMethodPong        = 3
+	coreMethodGetRegistry = 5
+
+	coreEventDone     = 1
+	coreEventPing     = 2
+	coreEventError    = 3
+	coreEventRemoveID = 4
+
+	clientMethodUpdateProperties = 2
+
+	registryMethodBind        = 1
+	registryEventGlobal       = 0
+	registryEventGlobalRemove = 1
+
+	methodSubscribeParams = 1
+	methodSetParam        = 3
+
+	metadataMethodSetProperty = 1
+
+	eventInfo  = 0
+	eventParam = 1
+)
+
+// info 事件中 change_mask 的各位，只有置位的字段携带了新值
+const (
+	nodeChangeInputPorts  = 1 << 0
+	nodeChangeOutputPorts = 1 << 1
+	nodeChangeState       = 1 << 2
+	nodeChangeProps       = 1 << 3
+
+	deviceChangeProps = 1 << 0
+	clientChangeProps = 1 << 0
+
+	linkChangeState = 1 << 0
+	linkChangeProps = 1 << 2
+)
+
+var nodeStates = map[int32]string{-1: "error", 0: "creating", 1: "suspended", 2: "idle", 3: "running"}
+
+var linkStates = map[int32]string{-2: "error", -1: "unlinked", 0: "init", 1: "negotiating", 2: "allocating", 3: "paused", 4: "active"}
+
+type MetadataEntry struct {
+	Subject uint32 `json:"subject"`
+	Key     string `json:"key"`
+	Type    string `json:"type,omitempty"`
+	Value   any    `json:"value"`
+}
+
+// Global 是与 pw-dump 输出格式一致的对象快照，Info 为空时表示对象已被移除
+type Global struct {
+	ID       uint32          `json:"id"`
+	Type     string          `json:"type,omitempty"`
+	Version  uint32          `json:"version,omitempty"`
+	Props    map[string]any  `json:"props,omitempty"`
+	Info     map[string]any  `json:"info,omitempty"`
+	Metadata []MetadataEntry `json:"metadata,omitempty"`
+}
+
+type Handler func([]Global)
+
+type proxy struct {
+	id          uint32
+	global      uint32
+	typ         string
+	version     uint32
+	globalProps map[string]string
+	info        map[string]any
+	params      map[uint32][]any
+	metadata    []MetadataEntry
+}
+
+type Client struct {
+	conn    *net.UnixConn
+	handler Handler
+
+	writeMu sync.Mutex
+	seq     uint32
+
+	mu          sync.Mutex
+	nextID      uint32
+	proxies     map[uint32]*proxy
+	globals     map[uint32]*proxy
+	dirty       map[uint32]bool
+	removed     []uint32
+	syncSeq     int32
+	syncPending bool
+	ready       []Global
+
+	done chan struct{}
+	err  error
+}
+
+func SocketPath() string {
+	remote := os.Getenv("PIPEWIRE_REMOTE")
+	if remote == "" {
+		remote = "pipewire-0"
+	}
+	if filepath.IsAbs(remote) {
+		return remote
+	}
+	dir := os.Getenv("PIPEWIRE_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.Getenv("XDG_RUNTIME_DIR")
+	}
+	return filepath.Join(dir, remote)
+}
+
+// Dial 连接 PipeWire 守护进程，并在每次对象状态变化后以批量方式回调 handler
+func Dial(handler Handler) (*Client, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: SocketPath(), Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    conn,
+		handler: handler,
+		nextID:  registryID + 1,
+		proxies: make(map[uint32]*proxy),
+		globals: make(map[uint32]*proxy),
+		dirty:   make(map[uint32]bool),
+		done:    make(chan struct{}),
+	}
+
+	err = c.send(coreID, coreMethodHello, func(b *Builder) {
+		b.Struct(func() { b.Int(protocolVersion) })
+	})
+	if err == nil {
+		err = c.send(clientID, clientMethodUpdateProperties, func(b *Builder) {
+			b.Struct(func() {
+				b.Dict(map[string]string{"application.name": "pw-autopaused"})
+			})
+		})
+	}
+	if err == nil {
+		err = c.send(coreID, coreMethodGetRegistry, func(b *Builder) {
+			b.Struct(func() {
+				b.Int(protocolVersion)
+				b.Int(registryID)
+			})
+		})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go c.readLoop()
+
+	c.mu.Lock()
+	c.scheduleSync()
+	c.mu.Unlock()
+	return c, nil
+}
+
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) send(id uint32, opcode uint8, build func(b *Builder)) error {
+	var b Builder
+	build(&b)
+	payload := b.Bytes()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	msg := make([]byte, 16, 16+len(payload))
+	binary.LittleEndian.PutUint32(msg[0:], id)
+	binary.LittleEndian.PutUint32(msg[4:], uint32(opcode)<<24|uint32(len(payload))&0xffffff)
+	binary.LittleEndian.PutUint32(msg[8:], c.seq)
+	binary.LittleEndian.PutUint32(msg[12:], 0)
+	c.seq++
+
+	_, err := c.conn.Write(append(msg, payload...))
+	return err
+}
+
+func (c *Client) readLoop() {
+	var (
+		buf []byte
+		tmp = make([]byte, 64*1024)
+		oob = make([]byte, syscall.CmsgSpace(28*4))
+	)
+	for {
+		n, oobn, _, _, err := c.conn.ReadMsgUnix(tmp, oob)
+		if oobn > 0 {
+			closeRights(oob[:oobn])
+		}
+		if err != nil {
+			c.err = err
+			close(c.done)
+			return
+		}
+		buf = append(buf, tmp[:n]...)
+
+		for len(buf) >= 16 {
+			id := binary.LittleEndian.Uint32(buf[0:])
+			word := binary.LittleEndian.Uint32(buf[4:])
+			opcode, size := uint8(word>>24), int(word&0xffffff)
+			if len(buf) < 16+size {
+				break
+			}
+			c.dispatch(id, opcode, buf[16:16+size])
+			buf = buf[16+size:]
+		}
+		buf = append([]byte(nil), buf...)
+	}
+}
+
+func closeRights(oob []byte) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return
+	}
+	for _, m := range msgs {
+		fds, err := syscall.ParseUnixRights(&m)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+	}
+}
+
+func (c *Client) dispatch(id uint32, opcode uint8, payload []byte) {
+	v, _, err := ParsePod(payload)
+	if err != nil {
+		zap.L().Debug("无法解析原生协议消息", zap.Uint32("id", id), zap.Uint8("opcode", opcode), zap.Error(err))
+		return
+	}
+	args, _ := v.([]any)
+
+	c.mu.Lock()
+	c.handleEvent(id, opcode, args)
+	batch := c.ready
+	c.ready = nil
+	c.mu.Unlock()
+
+	if len(batch) > 0 && c.handler != nil {
+		c.handler(batch)
+	}
+}
+
+func (c *Client) handleEvent(id uint32, opcode uint8, args []any) {
+	switch id {
+	case coreID:
+		c.onCoreEvent(opcode, args)
+		return
+	case registryID:
+		c.onRegistryEvent(opcode, args)
+		return
+	}
+
+	p, ok := c.proxies[id]
+	if !ok {
+		return
+	}
+	switch p.typ {
+	case TypeInterfaceNode:
+		c.onNodeEvent(p, opcode, args)
+	case TypeInterfaceDevice:
+		c.onDeviceEvent(p, opcode, args)
+	case TypeInterfaceLink:
+		c.onLinkEvent(p, opcode, args)
+	case TypeInterfaceClient:
+		c.onClientEvent(p, opcode, args)
+	case TypeInterfaceMetadata:
+		c.onMetadataEvent(p, opcode, args)
+	}
+}
+
+func (c *Client) onCoreEvent(opcode uint8, args []any) {
+	switch opcode {
+	case coreEventDone:
+		if argInt(args, 0) == coreID && argInt(args, 1) == c.syncSeq && c.syncPending {
+			c.syncPending = false
+			c.flush()
+		}
+	case coreEventPing:
+		id, seq := argInt(args, 0), argInt(args, 1)
+		c.send(coreID, coreMethodPong, func(b *Builder) {
+			b.Struct(func() {
+				b.Int(id)
+				b.Int(seq)
+			})
+		})
+	case coreEventError:
+		zap.L().Debug("PipeWire 返回错误",
+			zap.Int32("id", argInt(args, 0)),
+			zap.Int32("res", argInt(args, 2)),
+			zap.String("message", argString(args, 3)))
+	case coreEventRemoveID:
+		delete(c.proxies, uint32(argInt(args, 0)))
+	}
+}
+
+func (c *Client) onRegistryEvent(opcode uint8, args []any) {
+	switch opcode {
+	case registryEventGlobal:
+		global := uint32(argInt(args, 0))
+		typ := argString(args, 2)
+		version := uint32(argInt(args, 3))
+		switch typ {
+		case TypeInterfaceNode, TypeInterfaceDevice, TypeInterfaceLink, TypeInterfaceClient, TypeInterfaceMetadata:
+		default:
+			return
+		}
+		if version > protocolVersion {
+			version = protocolVersion
+		}
+		c.bind(global, typ, version, parseDict(argAt(args, 4)))
+	case registryEventGlobalRemove:
+		global := uint32(argInt(args, 0))
+		if _, ok := c.globals[global]; !ok {
+			return
+		}
+		delete(c.globals, global)
+		delete(c.dirty, global)
+		c.removed = append(c.removed, global)
+		c.scheduleSync()
+	}
+}
+
+func (c *Client) bind(global uint32, typ string, version uint32, props map[string]string) {
+	p := &proxy{
+		id:          c.nextID,
+		global:      global,
+		typ:         typ,
+		version:     version,
+		globalProps: props,
+		params:      make(map[uint32][]any),
+	}
+	c.nextID++
+	c.proxies[p.id] = p
+	c.globals[global] = p
+
+	// 代理 ID 必须按分配顺序发送给服务端，因此绑定请求不能并发发出
+	err := c.send(registryID, registryMethodBind, func(b *Builder) {
+		b.Struct(func() {
+			b.Int(int32(global))
+			b.String(typ)
+			b.Int(int32(version))
+			b.Int(int32(p.id))
+		})
+	})
+	if err != nil {
+		return
+	}
+
+	var ids []uint32
+	switch typ {
+	case TypeInterfaceNode:
+		ids = []uint32{ParamProps}
+	case TypeInterfaceDevice:
+		ids = []uint32{ParamEnumProfile, ParamProfile, ParamEnumRoute, ParamRoute}
+	default:
+		return
+	}
+	c.send(p.id, methodSubscribeParams, func(b *Builder) {
+		b.Struct(func() { b.IdArray(ids) })
+	})
+}
+
+// updateInfo 返回对象当前的 info，首次收到 info 事件时创建；
+// info 事件只携带 change_mask 中置位的字段，其余字段需保留之前的值
+func (p *proxy) updateInfo() map[string]any {
+	if p.info == nil {
+		p.info = make(map[string]any)
+	}
+	return p.info
+}
+
+func (c *Client) onNodeEvent(p *proxy, opcode uint8, args []any) {
+	switch opcode {
+	case eventInfo:
+		mask := argInt(args, 3)
+		info := p.updateInfo()
+		info["max-input-ports"] = argInt(args, 1)
+		info["max-output-ports"] = argInt(args, 2)
+		if mask&nodeChangeInputPorts != 0 {
+			info["n-input-ports"] = argInt(args, 4)
+		}
+		if mask&nodeChangeOutputPorts != 0 {
+			info["n-output-ports"] = argInt(args, 5)
+		}
+		if mask&nodeChangeState != 0 {
+			info["state"] = nodeStates[argInt(args, 6)]
+			info["error"] = argAt(args, 7)
+		}
+		if mask&nodeChangeProps != 0 {
+			info["props"] = dictJSON(parseDict(argAt(args, 8)))
+		}
+		c.markDirty(p)
+	case eventParam:
+		c.onParam(p, args)
+	}
+}
+
+func (c *Client) onDeviceEvent(p *proxy, opcode uint8, args []any) {
+	switch opcode {
+	case eventInfo:
+		if argInt(args, 1)&deviceChangeProps != 0 {
+			p.updateInfo()["props"] = dictJSON(parseDict(argAt(args, 2)))
+		}
+		c.markDirty(p)
+	case eventParam:
+		c.onParam(p, args)
+	}
+}
+
+func (c *Client) onLinkEvent(p *proxy, opcode uint8, args []any) {
+	if opcode != eventInfo {
+		return
+	}
+	mask := argInt(args, 5)
+	info := p.updateInfo()
+	info["output-node-id"] = argInt(args, 1)
+	info["output-port-id"] = argInt(args, 2)
+	info["input-node-id"] = argInt(args, 3)
+	info["input-port-id"] = argInt(args, 4)
+	if mask&linkChangeState != 0 {
+		info["state"] = linkStates[argInt(args, 6)]
+		info["error"] = argAt(args, 7)
+	}
+	if mask&linkChangeProps != 0 {
+		info["props"] = dictJSON(parseDict(argAt(args, 9)))
+	}
+	c.markDirty(p)
+}
+
+func (c *Client) onClientEvent(p *proxy, opcode uint8, args []any) {
+	if opcode != eventInfo {
+		return
+	}
+	if argInt(args, 1)&clientChangeProps != 0 {
+		p.updateInfo()["props"] = dictJSON(parseDict(argAt(args, 2)))
+	}
+	c.markDirty(p)
+}
+
+func (c *Client) onMetadataEvent(p *proxy, opcode uint8, args []any) {
+	if opcode != eventInfo {
+		return
+	}
+	entry := MetadataEntry{
+		Subject: uint32(argInt(args, 0)),
+		Key:     argString(args, 1),
+		Type:    argString(args, 2),
+	}
+	if value, ok := argAt(args, 3).(string); ok {
+		entry.Value = value
+		if entry.Type == "Spa:String:JSON" {
+			var parsed any
+			if json.Unmarshal([]byte(value), &parsed) == nil {
+				entry.Value = parsed
+			}
+		}
+	}
+	p.metadata = append(p.metadata, entry)
+	c.markDirty(p)
+}
+
+func (c *Client) onParam(p *proxy, args []any) {
+	id, _ := argAt(args, 1).(Id)
+	index := argInt(args, 2)
+	if index == 0 {
+		p.params[uint32(id)] = nil
+	}
+	if param := argAt(args, 4); param != nil {
+		p.params[uint32(id)] = append(p.params[uint32(id)], ToJSON(param))
+	}
+	c.markDirty(p)
+}
+
+func (c *Client) markDirty(p *proxy) {
+	c.dirty[p.global] = true
+	c.scheduleSync()
+}
+
+func (c *Client) scheduleSync() {
+	if c.syncPending {
+		return
+	}
+	c.syncPending = true
+	c.syncSeq++
+	seq := c.syncSeq
+	c.send(coreID, coreMethodSync, func(b *Builder) {
+		b.Struct(func() {
+			b.Int(coreID)
+			b.Int(seq)
+		})
+	})
+}
+
+func (c *Client) flush() {
+	var batch []Global
+	for _, id := range c.removed {
+		batch = append(batch, Global{ID: id})
+	}
+	c.removed = nil
+
+	ids := make([]uint32, 0, len(c.dirty))
+	for id := range c.dirty {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		p, ok := c.globals[id]
+		if !ok {
+			continue
+		}
+		g := Global{ID: p.global, Type: p.typ, Version: p.version}
+		if p.typ == TypeInterfaceMetadata {
+			if len(p.metadata) == 0 {
+				continue
+			}
+			g.Props = dictJSON(p.globalProps)
+			g.Metadata = p.metadata
+			p.metadata = nil
+		} else {
+			if p.info == nil {
+				continue
+			}
+			info := make(map[string]any, len(p.info)+1)
+			for k, v := range p.info {
+				info[k] = v
+			}
+			params := make(map[string]any, len(p.params))
+			for pid, values := range p.params {
+				params[ParamName(pid)] = values
+			}
+			info["params"] = params
+			g.Info = info
+		}
+		batch = append(batch, g)
+	}
+	c.dirty = make(map[uint32]bool)
+	c.ready = append(c.ready, batch...)
+}
+
+func (c *Client) lookup(global uint32, typ string) (*proxy, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.globals[global]
+	if !ok || p.typ != typ {
+		return nil, fmt.Errorf("pipewire: no %s with id %d", typ, global)
+	}
+	return p, nil
+}
+
+// SetNodeProps 直接向节点写入 Props 参数，键名与 pw-cli 中的写法一致（如 mute、channelVolumes）
+func (c *Client) SetNodeProps(global uint32, props map[string]any) error {
+	p, err := c.lookup(global, TypeInterfaceNode)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		if _, ok := PropKey(k); !ok {
+			return fmt.Errorf("pipewire: unknown prop %q", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buildErr error
+	err = c.send(p.id, methodSetParam, func(b *Builder) {
+		b.Struct(func() {
+			b.Id(ParamProps)
+			b.Int(0)
+			b.Object(objectTypeProps, ParamProps, func() {
+				for _, k := range keys {
+					key, _ := PropKey(k)
+					b.Prop(key, 0)
+					if err := b.Value(props[k]); err != nil {
+						buildErr = err
+					}
+				}
+			})
+		})
+	})
+	if buildErr != nil {
+		return buildErr
+	}
+	return err
+}
+
+// SetMetadata 修改名为 name 的元数据对象中的属性，value 为 nil 时删除该属性
+func (c *Client) SetMetadata(name string, subject uint32, key, typ string, value *string) error {
+	c.mu.Lock()
+	var target *proxy
+	for _, p := range c.globals {
+		if p.typ == TypeInterfaceMetadata && p.globalProps["metadata.name"] == name {
+			target = p
+			break
+		}
+	}
+	c.mu.Unlock()
+	if target == nil {
+		return fmt.Errorf("pipewire: no metadata named %q", name)
+	}
+
+	return c.send(target.id, metadataMethodSetProperty, func(b *Builder) {
+		b.Struct(func() {
+			b.Int(int32(subject))
+			b.String(key)
+			if value == nil {
+				b.None()
+				b.None()
+				return
+			}
+			b.String(typ)
+			b.String(*value)
+		})
+	})
+}
+
+func argAt(args []any, i int) any {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+func argInt(args []any, i int) int32 {
+	switch v := argAt(args, i).(type) {
+	case int32:
+		return v
+	case Id:
+		return int32(v)
+	case int64:
+		return int32(v)
+	}
+	return 0
+}
+
+func argString(args []any, i int) string {
+	s, _ := argAt(args, i).(string)
+	return s
+}
+
+func parseDict(v any) map[string]string {
+	items, _ := v.([]any)
+	dict := make(map[string]string)
+	for i := 1; i+1 < len(items); i += 2 {
+		k, kOk := items[i].(string)
+		val, vOk := items[i+1].(string)
+		if kOk && vOk {
+			dict[k] = val
+		}
+	}
+	return dict
+}
+
+func dictJSON(dict map[string]string) map[string]any {
+	out := make(map[string]any, len(dict))
+	for k, v := range dict {
+		out[k] = DictValue(v)
+	}
+	return out
+}
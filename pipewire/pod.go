@@ -0,0 +1,387 @@
+package pipewire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	TypeNone      = 1
+	TypeBool      = 2
+	TypeId        = 3
+	TypeInt       = 4
+	TypeLong      = 5
+	TypeFloat     = 6
+	TypeDouble    = 7
+	TypeString    = 8
+	TypeBytes     = 9
+	TypeRectangle = 10
+	TypeFraction  = 11
+	TypeBitmap    = 12
+	TypeArray     = 13
+	TypeStruct    = 14
+	TypeObject    = 15
+	TypeSequence  = 16
+	TypePointer   = 17
+	TypeFd        = 18
+	TypeChoice    = 19
+	TypePod       = 20
+)
+
+var errShortPod = errors.New("pod: short buffer")
+
+type Id uint32
+
+type Fd int64
+
+type Rectangle struct {
+	Width  uint32 `json:"width"`
+	Height uint32 `json:"height"`
+}
+
+type Fraction struct {
+	Num   uint32 `json:"num"`
+	Denom uint32 `json:"denom"`
+}
+
+type Prop struct {
+	Key   uint32
+	Flags uint32
+	Value any
+}
+
+type Object struct {
+	Type  uint32
+	ID    uint32
+	Props []Prop
+}
+
+type Choice struct {
+	Type   uint32
+	Flags  uint32
+	Values []any
+}
+
+func pad8(n int) int {
+	return (n + 7) &^ 7
+}
+
+// ParsePod 解析一个完整的 POD，返回其值及占用的字节数（含对齐填充）
+func ParsePod(b []byte) (any, int, error) {
+	if len(b) < 8 {
+		return nil, 0, errShortPod
+	}
+	size := int(binary.LittleEndian.Uint32(b[0:4]))
+	typ := binary.LittleEndian.Uint32(b[4:8])
+	if size < 0 || 8+size > len(b) {
+		return nil, 0, errShortPod
+	}
+	v, err := parseBody(typ, b[8:8+size])
+	if err != nil {
+		return nil, 0, err
+	}
+	n := 8 + pad8(size)
+	if n > len(b) {
+		n = len(b)
+	}
+	return v, n, nil
+}
+
+func parseBody(typ uint32, body []byte) (any, error) {
+	le := binary.LittleEndian
+	need := func(n int) error {
+		if len(body) < n {
+			return errShortPod
+		}
+		return nil
+	}
+
+	switch typ {
+	case TypeNone:
+		return nil, nil
+	case TypeBool:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		return le.Uint32(body) != 0, nil
+	case TypeId:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		return Id(le.Uint32(body)), nil
+	case TypeInt:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		return int32(le.Uint32(body)), nil
+	case TypeLong:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return int64(le.Uint64(body)), nil
+	case TypeFloat:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(le.Uint32(body)), nil
+	case TypeDouble:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(le.Uint64(body)), nil
+	case TypeString:
+		for i, c := range body {
+			if c == 0 {
+				return string(body[:i]), nil
+			}
+		}
+		return string(body), nil
+	case TypeBytes:
+		return append([]byte(nil), body...), nil
+	case TypeRectangle:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return Rectangle{le.Uint32(body), le.Uint32(body[4:])}, nil
+	case TypeFraction:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return Fraction{le.Uint32(body), le.Uint32(body[4:])}, nil
+	case TypeFd:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return Fd(le.Uint64(body)), nil
+	case TypeArray:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return parseValues(le.Uint32(body), le.Uint32(body[4:]), body[8:])
+	case TypeStruct:
+		var values []any
+		for len(body) > 0 {
+			v, n, err := ParsePod(body)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			body = body[n:]
+		}
+		return values, nil
+	case TypeObject:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		obj := &Object{Type: le.Uint32(body), ID: le.Uint32(body[4:])}
+		body = body[8:]
+		for len(body) >= 8 {
+			key, flags := le.Uint32(body), le.Uint32(body[4:])
+			v, n, err := ParsePod(body[8:])
+			if err != nil {
+				return nil, err
+			}
+			obj.Props = append(obj.Props, Prop{Key: key, Flags: flags, Value: v})
+			body = body[8+n:]
+		}
+		return obj, nil
+	case TypeChoice:
+		if err := need(16); err != nil {
+			return nil, err
+		}
+		values, err := parseValues(le.Uint32(body[8:]), le.Uint32(body[12:]), body[16:])
+		if err != nil {
+			return nil, err
+		}
+		return &Choice{Type: le.Uint32(body), Flags: le.Uint32(body[4:]), Values: values}, nil
+	case TypePod:
+		v, _, err := ParsePod(body)
+		return v, err
+	default:
+		return nil, nil
+	}
+}
+
+func parseValues(childSize, childType uint32, body []byte) ([]any, error) {
+	if childSize == 0 {
+		return nil, nil
+	}
+	values := make([]any, 0, len(body)/int(childSize))
+	for len(body) >= int(childSize) {
+		v, err := parseBody(childType, body[:childSize])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		body = body[childSize:]
+	}
+	return values, nil
+}
+
+type Builder struct {
+	buf []byte
+}
+
+func (b *Builder) Bytes() []byte {
+	return b.buf
+}
+
+func (b *Builder) u32(v uint32) {
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, v)
+}
+
+func (b *Builder) u64(v uint64) {
+	b.buf = binary.LittleEndian.AppendUint64(b.buf, v)
+}
+
+func (b *Builder) pad() {
+	for len(b.buf)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *Builder) primitive(typ uint32, size uint32, body func()) {
+	b.u32(size)
+	b.u32(typ)
+	body()
+	b.pad()
+}
+
+func (b *Builder) None() {
+	b.primitive(TypeNone, 0, func() {})
+}
+
+func (b *Builder) Bool(v bool) {
+	var n uint32
+	if v {
+		n = 1
+	}
+	b.primitive(TypeBool, 4, func() { b.u32(n) })
+}
+
+func (b *Builder) Id(v uint32) {
+	b.primitive(TypeId, 4, func() { b.u32(v) })
+}
+
+func (b *Builder) Int(v int32) {
+	b.primitive(TypeInt, 4, func() { b.u32(uint32(v)) })
+}
+
+func (b *Builder) Long(v int64) {
+	b.primitive(TypeLong, 8, func() { b.u64(uint64(v)) })
+}
+
+func (b *Builder) Float(v float32) {
+	b.primitive(TypeFloat, 4, func() { b.u32(math.Float32bits(v)) })
+}
+
+func (b *Builder) String(v string) {
+	b.primitive(TypeString, uint32(len(v)+1), func() {
+		b.buf = append(b.buf, v...)
+		b.buf = append(b.buf, 0)
+	})
+}
+
+// NullableString 在 v 为 nil 时写入 None，用于协议中允许为空的字符串参数
+func (b *Builder) NullableString(v *string) {
+	if v == nil {
+		b.None()
+		return
+	}
+	b.String(*v)
+}
+
+func (b *Builder) FloatArray(values []float32) {
+	b.primitive(TypeArray, uint32(8+4*len(values)), func() {
+		b.u32(4)
+		b.u32(TypeFloat)
+		for _, v := range values {
+			b.u32(math.Float32bits(v))
+		}
+	})
+}
+
+func (b *Builder) IdArray(values []uint32) {
+	b.primitive(TypeArray, uint32(8+4*len(values)), func() {
+		b.u32(4)
+		b.u32(TypeId)
+		for _, v := range values {
+			b.u32(v)
+		}
+	})
+}
+
+func (b *Builder) container(typ uint32, header func(), body func()) {
+	start := len(b.buf)
+	b.u32(0)
+	b.u32(typ)
+	header()
+	body()
+	binary.LittleEndian.PutUint32(b.buf[start:], uint32(len(b.buf)-start-8))
+	b.pad()
+}
+
+func (b *Builder) Struct(body func()) {
+	b.container(TypeStruct, func() {}, body)
+}
+
+func (b *Builder) Object(typ, id uint32, body func()) {
+	b.container(TypeObject, func() {
+		b.u32(typ)
+		b.u32(id)
+	}, body)
+}
+
+// Prop 写入对象属性头，随后必须紧跟一个属性值
+func (b *Builder) Prop(key, flags uint32) {
+	b.u32(key)
+	b.u32(flags)
+}
+
+// Dict 按原生协议的字典格式写入键值对
+func (b *Builder) Dict(dict map[string]string) {
+	b.Struct(func() {
+		b.Int(int32(len(dict)))
+		for k, v := range dict {
+			b.String(k)
+			b.String(v)
+		}
+	})
+}
+
+// Value 根据 Go 类型写入对应的 POD
+func (b *Builder) Value(v any) error {
+	switch v := v.(type) {
+	case nil:
+		b.None()
+	case bool:
+		b.Bool(v)
+	case Id:
+		b.Id(uint32(v))
+	case int:
+		b.Int(int32(v))
+	case int32:
+		b.Int(v)
+	case int64:
+		b.Long(v)
+	case float32:
+		b.Float(v)
+	case float64:
+		b.Float(float32(v))
+	case string:
+		b.String(v)
+	case []float32:
+		b.FloatArray(v)
+	case []float64:
+		values := make([]float32, len(v))
+		for i, f := range v {
+			values[i] = float32(f)
+		}
+		b.FloatArray(values)
+	default:
+		return fmt.Errorf("pod: unsupported value type %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package pipewire
+
+import (
+	"math"
+	"strconv"
+)
+
+const (
+	TypeInterfaceCore     = "PipeWire:Interface:Core"
+	TypeInterfaceRegistry = "PipeWire:Interface:Registry"
+	TypeInterfaceClient   = "PipeWire:Interface:Client"
+	TypeInterfaceNode     = "PipeWire:Interface:Node"
+	TypeInterfaceDevice   = "PipeWire:Interface:Device"
+	TypeInterfaceLink     = "PipeWire:Interface:Link"
+	TypeInterfaceMetadata = "PipeWire:Interface:Metadata"
+)
+
+const (
+	ParamProps       = 2
+	ParamEnumProfile = 8
+	ParamProfile     = 9
+	ParamEnumRoute   = 12
+	ParamRoute       = 13
+)
+
+const (
+	objectTypeProps        = 0x40002
+	objectTypeParamProfile = 0x40007
+	objectTypeParamRoute   = 0x40009
+)
+
+var paramNames = map[uint32]string{
+	1:                "PropInfo",
+	ParamProps:       "Props",
+	3:                "EnumFormat",
+	4:                "Format",
+	5:                "Buffers",
+	6:                "Meta",
+	7:                "IO",
+	ParamEnumProfile: "EnumProfile",
+	ParamProfile:     "Profile",
+	10:               "EnumPortConfig",
+	11:               "PortConfig",
+	ParamEnumRoute:   "EnumRoute",
+	ParamRoute:       "Route",
+	14:               "Control",
+	15:               "Latency",
+	16:               "ProcessLatency",
+	17:               "Tag",
+}
+
+func ParamName(id uint32) string {
+	if name, ok := paramNames[id]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+var propKeys = map[uint32]string{
+	0x101:   "device",
+	0x102:   "deviceName",
+	0x103:   "deviceFd",
+	0x104:   "card",
+	0x105:   "cardName",
+	0x106:   "minLatency",
+	0x107:   "maxLatency",
+	0x108:   "periods",
+	0x109:   "periodSize",
+	0x10a:   "periodEvent",
+	0x10b:   "live",
+	0x10c:   "rate",
+	0x10d:   "quality",
+	0x10e:   "bluetoothAudioCodec",
+	0x10f:   "bluetoothOffloadActive",
+	0x10001: "waveType",
+	0x10002: "frequency",
+	0x10003: "volume",
+	0x10004: "mute",
+	0x10005: "patternType",
+	0x10006: "ditherType",
+	0x10007: "truncate",
+	0x10008: "channelVolumes",
+	0x10009: "volumeBase",
+	0x1000a: "volumeStep",
+	0x1000b: "channelMap",
+	0x1000c: "monitorMute",
+	0x1000d: "monitorVolumes",
+	0x1000e: "latencyOffsetNsec",
+	0x1000f: "softMute",
+	0x10010: "softVolumes",
+	0x10011: "iec958Codecs",
+	0x10012: "volumeRampSamples",
+	0x10013: "volumeRampStepSamples",
+	0x10014: "volumeRampTime",
+	0x10015: "volumeRampStepTime",
+	0x10016: "volumeRampScale",
+	0x80001: "params",
+}
+
+var routeKeys = map[uint32]string{
+	1:  "index",
+	2:  "direction",
+	3:  "device",
+	4:  "name",
+	5:  "description",
+	6:  "priority",
+	7:  "available",
+	8:  "info",
+	9:  "profiles",
+	10: "props",
+	11: "devices",
+	12: "profile",
+	13: "save",
+}
+
+var profileKeys = map[uint32]string{
+	1: "index",
+	2: "name",
+	3: "description",
+	4: "priority",
+	5: "available",
+	6: "info",
+	7: "classes",
+	8: "save",
+}
+
+var directionNames = []string{"Input", "Output"}
+
+var availabilityNames = []string{"unknown", "no", "yes"}
+
+var channelNames = []string{
+	"UNK", "NA", "MONO", "FL", "FR", "FC", "LFE", "SL", "SR", "FLC", "FRC",
+	"RC", "RL", "RR", "TC", "TFL", "TFC", "TFR", "TRL", "TRC", "TRR", "RLC",
+	"RRC", "FLW", "FRW", "LFE2", "FLH", "FCH", "FRH", "TFLC", "TFRC", "TSL",
+	"TSR", "LLFE", "RLFE", "BC", "BLC", "BRC",
+}
+
+func objectKeys(typ uint32) map[uint32]string {
+	switch typ {
+	case objectTypeProps:
+		return propKeys
+	case objectTypeParamRoute:
+		return routeKeys
+	case objectTypeParamProfile:
+		return profileKeys
+	}
+	return nil
+}
+
+func PropKey(name string) (uint32, bool) {
+	for k, v := range propKeys {
+		if v == name {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+func enumName(names []string, v any) any {
+	id, ok := v.(Id)
+	if !ok || int(id) >= len(names) {
+		return ToJSON(v)
+	}
+	return names[id]
+}
+
+// ToJSON 将解析后的 POD 值转换为与 pw-dump 输出一致的结构
+func ToJSON(v any) any {
+	switch v := v.(type) {
+	case Id:
+		return uint32(v)
+	case float32:
+		return finite(float64(v))
+	case float64:
+		return finite(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = ToJSON(e)
+		}
+		return out
+	case *Choice:
+		if len(v.Values) > 0 {
+			return ToJSON(v.Values[0])
+		}
+		return nil
+	case *Object:
+		keys := objectKeys(v.Type)
+		out := make(map[string]any, len(v.Props))
+		for _, p := range v.Props {
+			name, ok := keys[p.Key]
+			if !ok {
+				name = strconv.FormatUint(uint64(p.Key), 10)
+			}
+			switch {
+			case name == "direction":
+				out[name] = enumName(directionNames, p.Value)
+			case name == "available":
+				out[name] = enumName(availabilityNames, p.Value)
+			case name == "channelMap":
+				if ids, ok := p.Value.([]any); ok {
+					channels := make([]any, len(ids))
+					for i, id := range ids {
+						channels[i] = enumName(channelNames, id)
+					}
+					out[name] = channels
+				} else {
+					out[name] = ToJSON(p.Value)
+				}
+			default:
+				out[name] = ToJSON(p.Value)
+			}
+		}
+		return out
+	case []byte, Fd:
+		return nil
+	}
+	return v
+}
+
+func finite(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+// DictValue 按 pw-dump 的规则将字典中的字符串值转换为布尔、数值或字符串
+func DictValue(s string) any {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f
+	}
+	return s
+}
@@ -0,0 +1,216 @@
+package mpris
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// Prefix 为 MPRIS 播放器总线名称的公共前缀
+	Prefix = "org.mpris.MediaPlayer2."
+
+	ObjectPath      = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	RootInterface   = "org.mpris.MediaPlayer2"
+	PlayerInterface = "org.mpris.MediaPlayer2.Player"
+)
+
+const (
+	Playing = "Playing"
+	Paused  = "Paused"
+	Stopped = "Stopped"
+)
+
+type Player struct {
+	Name           string
+	Owner          string
+	Identity       string
+	DesktopEntry   string
+	PlaybackStatus string
+	CanPause       bool
+	CanControl     bool
+	PID            uint32
+	// Unlisted 表示播放器没有使用 org.mpris.MediaPlayer2.* 名称（如部分沙盒中的播放器），
+	// 由其发出的属性信号发现，Name 为连接的唯一名称
+	Unlisted bool
+}
+
+// ShortName 返回去掉公共前缀的总线名称，如 spotify、firefox.instance_1_23；
+// 未使用标准名称的播放器返回其 DesktopEntry
+func (p Player) ShortName() string {
+	if p.Unlisted && p.DesktopEntry != "" {
+		return p.DesktopEntry
+	}
+	return strings.TrimPrefix(p.Name, Prefix)
+}
+
+// Controllable 判断播放器是否接受 Pause 指令（CanControl 与 CanPause 均为 true）
+func (p Player) Controllable() bool {
+	return p.CanControl && p.CanPause
+}
+
+// IsPlayer 判断总线名称是否属于 MPRIS 播放器
+func IsPlayer(name string) bool {
+	return strings.HasPrefix(name, Prefix)
+}
+
+// ListNames 返回会话总线上所有 MPRIS 播放器的名称
+func ListNames(ctx context.Context, conn *dbus.Conn) ([]string, error) {
+	var names []string
+	if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return nil, err
+	}
+	players := names[:0]
+	for _, name := range names {
+		if IsPlayer(name) {
+			players = append(players, name)
+		}
+	}
+	return players, nil
+}
+
+// Verify 通过 Identity 属性确认 name 在 /org/mpris/MediaPlayer2 上实现了 MPRIS 接口
+func Verify(ctx context.Context, conn *dbus.Conn, name string) bool {
+	_, err := getProperty(ctx, conn, name, RootInterface, "Identity")
+	return err == nil
+}
+
+// Fetch 查询播放器的所有者、进程号与属性；属性读取失败时保留默认值（CanPause、CanControl 为 true）
+func Fetch(ctx context.Context, conn *dbus.Conn, name string) (Player, error) {
+	p := Player{Name: name, CanPause: true, CanControl: true}
+	if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetNameOwner", 0, name).Store(&p.Owner); err != nil {
+		return p, err
+	}
+	// 进程号仅用于关联输出流，获取失败不影响其他属性
+	conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetConnectionUnixProcessID", 0, p.Owner).Store(&p.PID)
+
+	obj := conn.Object(name, ObjectPath)
+	var root, player map[string]dbus.Variant
+	if err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, RootInterface).Store(&root); err == nil {
+		p.Identity, _ = root["Identity"].Value().(string)
+		p.DesktopEntry, _ = root["DesktopEntry"].Value().(string)
+	}
+	if err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, PlayerInterface).Store(&player); err == nil {
+		p.apply(player)
+	}
+	return p, nil
+}
+
+// apply 合并 PropertiesChanged 或 GetAll 返回的属性
+func (p *Player) apply(props map[string]dbus.Variant) {
+	if v, ok := props["PlaybackStatus"].Value().(string); ok {
+		p.PlaybackStatus = v
+	}
+	if v, ok := props["CanPause"].Value().(bool); ok {
+		p.CanPause = v
+	}
+	if v, ok := props["CanControl"].Value().(bool); ok {
+		p.CanControl = v
+	}
+	if v, ok := props["Identity"].Value().(string); ok {
+		p.Identity = v
+	}
+	if v, ok := props["DesktopEntry"].Value().(string); ok {
+		p.DesktopEntry = v
+	}
+}
+
+func getProperty(ctx context.Context, conn *dbus.Conn, name, iface, prop string) (dbus.Variant, error) {
+	var v dbus.Variant
+	err := conn.Object(name, ObjectPath).CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, iface, prop).Store(&v)
+	return v, err
+}
+
+// Identity 查询播放器的显示名称
+func Identity(ctx context.Context, conn *dbus.Conn, name string) (string, error) {
+	v, err := getProperty(ctx, conn, name, RootInterface, "Identity")
+	if err != nil {
+		return "", err
+	}
+	identity, _ := v.Value().(string)
+	return identity, nil
+}
+
+// Status 查询播放器的 PlaybackStatus
+func Status(ctx context.Context, conn *dbus.Conn, name string) (string, error) {
+	v, err := getProperty(ctx, conn, name, PlayerInterface, "PlaybackStatus")
+	if err != nil {
+		return "", err
+	}
+	status, _ := v.Value().(string)
+	return status, nil
+}
+
+// Controllable 查询播放器是否接受 Pause 指令，未实现相应属性的播放器视为可控制
+func Controllable(ctx context.Context, conn *dbus.Conn, name string) bool {
+	for _, prop := range []string{"CanControl", "CanPause"} {
+		v, err := getProperty(ctx, conn, name, PlayerInterface, prop)
+		if err != nil {
+			continue
+		}
+		if can, ok := v.Value().(bool); ok && !can {
+			return false
+		}
+	}
+	return true
+}
+
+// Call 调用播放器接口上的无参数方法，如 Pause、Play、Stop
+func Call(ctx context.Context, conn *dbus.Conn, name, method string) error {
+	return conn.Object(name, ObjectPath).CallWithContext(ctx, PlayerInterface+"."+method, 0).Err
+}
+
+func Pause(ctx context.Context, conn *dbus.Conn, name string) error {
+	return Call(ctx, conn, name, "Pause")
+}
+
+func Play(ctx context.Context, conn *dbus.Conn, name string) error {
+	return Call(ctx, conn, name, "Play")
+}
+
+func Stop(ctx context.Context, conn *dbus.Conn, name string) error {
+	return Call(ctx, conn, name, "Stop")
+}
+
+// Track 为播放器当前的曲目与播放位置
+type Track struct {
+	// ID 为 mpris:trackid，SetPosition 需要以此确认曲目未发生变化
+	ID       dbus.ObjectPath
+	Title    string
+	Position time.Duration
+	CanSeek  bool
+}
+
+// CurrentTrack 查询当前曲目与播放位置。Position 的变化不会通过 PropertiesChanged 通知，因此需要在暂停后主动读取
+func CurrentTrack(ctx context.Context, conn *dbus.Conn, name string) (Track, error) {
+	var t Track
+	v, err := getProperty(ctx, conn, name, PlayerInterface, "Position")
+	if err != nil {
+		return t, err
+	}
+	if us, ok := v.Value().(int64); ok {
+		t.Position = time.Duration(us) * time.Microsecond
+	}
+	if v, err := getProperty(ctx, conn, name, PlayerInterface, "Metadata"); err == nil {
+		metadata, _ := v.Value().(map[string]dbus.Variant)
+		switch id := metadata["mpris:trackid"].Value().(type) {
+		case dbus.ObjectPath:
+			t.ID = id
+		case string:
+			// 部分播放器以字符串给出 trackid
+			t.ID = dbus.ObjectPath(id)
+		}
+		t.Title, _ = metadata["xesam:title"].Value().(string)
+	}
+	if v, err := getProperty(ctx, conn, name, PlayerInterface, "CanSeek"); err == nil {
+		t.CanSeek, _ = v.Value().(bool)
+	}
+	return t, nil
+}
+
+// SetPosition 将曲目 trackID 的播放位置设为 position；当前曲目不是 trackID 时播放器会忽略该调用
+func SetPosition(ctx context.Context, conn *dbus.Conn, name string, trackID dbus.ObjectPath, position time.Duration) error {
+	return conn.Object(name, ObjectPath).CallWithContext(ctx, PlayerInterface+".SetPosition", 0, trackID, position.Microseconds()).Err
+}
@@ -0,0 +1,301 @@
+package mpris
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// refreshTimeout 为发现新播放器时查询其属性的时限
+const refreshTimeout = 2 * time.Second
+
+// Registry 通过 NameOwnerChanged 与 PropertiesChanged 维护会话总线上的播放器列表，
+// 查询播放器时优先使用缓存，缓存中没有时再调用总线
+type Registry struct {
+	conn *dbus.Conn
+
+	mu      sync.RWMutex
+	players map[string]*Player
+	// probed 记录已检查过的非标准名称的连接，避免对同一连接重复查询
+	probed map[string]bool
+	// changed 在播放器列表或播放状态变化时关闭并替换，用于唤醒 WaitStatus
+	changed chan struct{}
+
+	// 以下回调均在 Start 之前设置，在信号处理协程中调用
+	OnAdd func(p Player)
+	// OnRemove 在播放器退出后调用
+	OnRemove func(name string)
+	// OnStatusChanged 在播放器的 PlaybackStatus 发生变化后调用
+	OnStatusChanged func(name, status string)
+}
+
+func NewRegistry(conn *dbus.Conn) *Registry {
+	return &Registry{
+		conn:    conn,
+		players: make(map[string]*Player),
+		probed:  make(map[string]bool),
+		changed: make(chan struct{}),
+	}
+}
+
+// Start 订阅播放器信号并加载当前已存在的播放器，信号处理持续到连接关闭
+func (r *Registry) Start() error {
+	err := r.conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg0Namespace(RootInterface),
+	)
+	if err == nil {
+		err = r.conn.AddMatchSignal(
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+			dbus.WithMatchObjectPath(ObjectPath),
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan *dbus.Signal, 64)
+	r.conn.Signal(signals)
+
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	names, err := ListNames(ctx, r.conn)
+	cancel()
+	for _, name := range names {
+		r.Refresh(name)
+	}
+
+	go func() {
+		for sig := range signals {
+			switch sig.Name {
+			case "org.freedesktop.DBus.NameOwnerChanged":
+				r.onNameOwnerChanged(sig)
+			case "org.freedesktop.DBus.Properties.PropertiesChanged":
+				r.onPropertiesChanged(sig)
+			}
+		}
+	}()
+	return err
+}
+
+// Refresh 重新查询播放器的属性并更新缓存
+func (r *Registry) Refresh(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	p, err := Fetch(ctx, r.conn, name)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.players[name] = &p
+	r.broadcast()
+	r.mu.Unlock()
+	if r.OnAdd != nil {
+		r.OnAdd(p)
+	}
+	return nil
+}
+
+func (r *Registry) onNameOwnerChanged(sig *dbus.Signal) {
+	if len(sig.Body) < 3 {
+		return
+	}
+	name, _ := sig.Body[0].(string)
+	newOwner, _ := sig.Body[2].(string)
+	if !IsPlayer(name) {
+		r.mu.Lock()
+		_, tracked := r.players[name]
+		delete(r.probed, name)
+		r.mu.Unlock()
+		if !tracked || newOwner != "" {
+			return
+		}
+	}
+	if newOwner != "" {
+		go r.Refresh(name)
+		return
+	}
+
+	r.mu.Lock()
+	delete(r.players, name)
+	r.broadcast()
+	r.mu.Unlock()
+	if r.OnRemove != nil {
+		r.OnRemove(name)
+	}
+}
+
+func (r *Registry) onPropertiesChanged(sig *dbus.Signal) {
+	if len(sig.Body) < 2 {
+		return
+	}
+	iface, _ := sig.Body[0].(string)
+	changed, _ := sig.Body[1].(map[string]dbus.Variant)
+	if iface != PlayerInterface && iface != RootInterface {
+		return
+	}
+
+	type statusChange struct{ name, status string }
+	var (
+		touched []statusChange
+		known   bool
+	)
+	r.mu.Lock()
+	for _, p := range r.players {
+		if p.Owner != sig.Sender {
+			continue
+		}
+		known = true
+		p.apply(changed)
+		if v, ok := changed["PlaybackStatus"].Value().(string); ok {
+			touched = append(touched, statusChange{p.Name, v})
+		}
+	}
+	if len(touched) > 0 {
+		r.broadcast()
+	}
+	probe := !known && sig.Path == ObjectPath && !r.probed[sig.Sender]
+	if probe {
+		r.probed[sig.Sender] = true
+	}
+	r.mu.Unlock()
+
+	if probe {
+		go r.discover(sig.Sender)
+	}
+
+	if r.OnStatusChanged != nil {
+		for _, c := range touched {
+			r.OnStatusChanged(c.name, c.status)
+		}
+	}
+}
+
+// discover 检查发出播放器属性信号、却不拥有任何 org.mpris.MediaPlayer2.* 名称的连接，
+// 确认其实现了 MPRIS 接口后以唯一名称加入缓存，并跟踪该连接的退出
+func (r *Registry) discover(sender string) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+	if !Verify(ctx, r.conn, sender) {
+		return
+	}
+	// 名称可能在信号到达后才被注册，此时已由 NameOwnerChanged 加入缓存
+	if owned, err := ListNames(ctx, r.conn); err == nil {
+		for _, name := range owned {
+			var owner string
+			if r.conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetNameOwner", 0, name).Store(&owner) == nil && owner == sender {
+				return
+			}
+		}
+	}
+	r.conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, sender),
+	)
+
+	p, err := Fetch(ctx, r.conn, sender)
+	if err != nil {
+		return
+	}
+	p.Unlisted = true
+	r.mu.Lock()
+	r.players[sender] = &p
+	r.broadcast()
+	r.mu.Unlock()
+	if r.OnAdd != nil {
+		r.OnAdd(p)
+	}
+}
+
+// broadcast 唤醒所有等待中的 WaitStatus，调用时需持有 r.mu
+func (r *Registry) broadcast() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// WaitStatus 等待 names 中的播放器的 PlaybackStatus 变为 statuses 之一，已退出的播放器视为满足；
+// 返回 ctx 结束时仍未满足条件的播放器
+func (r *Registry) WaitStatus(ctx context.Context, names []string, statuses ...string) []string {
+	for {
+		r.mu.RLock()
+		var pending []string
+		for _, name := range names {
+			if p, ok := r.players[name]; ok && !slices.Contains(statuses, p.PlaybackStatus) {
+				pending = append(pending, name)
+			}
+		}
+		changed := r.changed
+		r.mu.RUnlock()
+
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return pending
+		}
+	}
+}
+
+func (r *Registry) Lookup(name string) (Player, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.players[name]
+	if !ok {
+		return Player{}, false
+	}
+	return *p, true
+}
+
+// Names 返回按名称排序的播放器列表
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.players))
+	for name := range r.players {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Players 返回按名称排序的播放器快照
+func (r *Registry) Players() []Player {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	players := make([]Player, 0, len(r.players))
+	for _, p := range r.players {
+		players = append(players, *p)
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Name < players[j].Name })
+	return players
+}
+
+func (r *Registry) Identity(ctx context.Context, name string) (string, error) {
+	if p, ok := r.Lookup(name); ok && p.Identity != "" {
+		return p.Identity, nil
+	}
+	return Identity(ctx, r.conn, name)
+}
+
+func (r *Registry) Status(ctx context.Context, name string) (string, error) {
+	if p, ok := r.Lookup(name); ok && p.PlaybackStatus != "" {
+		return p.PlaybackStatus, nil
+	}
+	return Status(ctx, r.conn, name)
+}
+
+func (r *Registry) Controllable(ctx context.Context, name string) bool {
+	if p, ok := r.Lookup(name); ok {
+		return p.Controllable()
+	}
+	return Controllable(ctx, r.conn, name)
+}
@@ -0,0 +1,196 @@
+package pwmon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Version 为 pw-dump 所链接的 libpipewire 版本
+type Version struct {
+	Major, Minor, Micro int
+}
+
+func (v Version) String() string {
+	if v == (Version{}) {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Micro)
+}
+
+var versionPattern = regexp.MustCompile(`libpipewire (\d+)\.(\d+)\.(\d+)`)
+
+// ParseVersion 从 pw-dump --version 的输出中解析版本，优先使用运行时链接的版本而不是编译时的版本
+func ParseVersion(out string) (Version, bool) {
+	var found []string
+	for _, line := range strings.Split(out, "\n") {
+		m := versionPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if found == nil || strings.HasPrefix(strings.TrimSpace(line), "Linked") {
+			found = m
+		}
+	}
+	if found == nil {
+		return Version{}, false
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(found[1])
+	v.Minor, _ = strconv.Atoi(found[2])
+	v.Micro, _ = strconv.Atoi(found[3])
+	return v, true
+}
+
+// Compat 描述所安装的 pw-dump 的命令行与输出差异，由 Probe 检测
+type Compat struct {
+	Version Version
+	// NoColors 表示 pw-dump 接受 --no-colors；没有彩色输出的旧版本不认识该参数，传入会直接退出
+	NoColors bool
+}
+
+// DefaultCompat 为无法检测时使用的设置，对应当前的 pw-dump
+var DefaultCompat = Compat{NoColors: true}
+
+// Probe 运行 pw-dump --version 与 --help 检测版本与支持的参数，失败时返回 DefaultCompat
+func Probe(ctx context.Context) Compat {
+	c := DefaultCompat
+	if out, err := exec.CommandContext(ctx, "pw-dump", "--version").CombinedOutput(); err == nil {
+		c.Version, _ = ParseVersion(string(out))
+	}
+	// 不认识的参数会让 --help 以非零状态退出，只看输出内容
+	if out, _ := exec.CommandContext(ctx, "pw-dump", "--help").CombinedOutput(); len(out) > 0 {
+		c.NoColors = strings.Contains(string(out), "no-colors")
+	}
+	return c
+}
+
+func (c Compat) args(extra ...string) []string {
+	if c.NoColors {
+		extra = append(extra, "--no-colors")
+	}
+	return extra
+}
+
+// Snapshot 运行一次 pw-dump 获取当前所有对象
+func (c Compat) Snapshot(ctx context.Context) ([]json.RawMessage, error) {
+	out, err := exec.CommandContext(ctx, "pw-dump", c.args()...).Output()
+	if err != nil {
+		return nil, err
+	}
+	var objects []json.RawMessage
+	err = json.Unmarshal(out, &objects)
+	return objects, err
+}
+
+// adapters 在对象无法按当前格式解码时依次尝试，将其他版本的输出改写为当前格式后重新解码
+var adapters = []func(obj map[string]any) bool{
+	unquoteNumbers,
+	wrapParams,
+}
+
+// numericKeys 为以数字解码的属性与字段，部分版本将其输出为字符串
+var numericKeys = map[string]bool{
+	"device.id":      true,
+	"output-node-id": true,
+	"output-port-id": true,
+	"input-node-id":  true,
+	"input-port-id":  true,
+}
+
+// unquoteNumbers 将 info、info.props 中以字符串形式输出的数字字段改为数字
+func unquoteNumbers(obj map[string]any) bool {
+	changed := false
+	fix := func(m map[string]any) {
+		for key, v := range m {
+			s, ok := v.(string)
+			if !ok || !numericKeys[key] {
+				continue
+			}
+			if n, err := strconv.Atoi(s); err == nil {
+				m[key] = n
+				changed = true
+			}
+		}
+	}
+	if info, ok := obj["info"].(map[string]any); ok {
+		fix(info)
+		if props, ok := info["props"].(map[string]any); ok {
+			fix(props)
+		}
+	}
+	return changed
+}
+
+// wrapParams 将 info.params 中以单个对象而非数组给出的参数包装为数组
+func wrapParams(obj map[string]any) bool {
+	info, _ := obj["info"].(map[string]any)
+	params, _ := info["params"].(map[string]any)
+	changed := false
+	for key, v := range params {
+		if m, ok := v.(map[string]any); ok {
+			params[key] = []any{m}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// adapt 依次应用 adapters，没有任何改写时返回 false
+func adapt(raw json.RawMessage) (json.RawMessage, bool) {
+	// 保留数字原文，避免重新编码后大数变为科学计数法
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var obj map[string]any
+	if dec.Decode(&obj) != nil {
+		return nil, false
+	}
+	changed := false
+	for _, fix := range adapters {
+		if fix(obj) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+	out, err := json.Marshal(obj)
+	return out, err == nil
+}
+
+// unmarshalObject 按当前格式解码对象，失败时经 adapters 改写后重试，仍然失败的对象计入 DecodeFailures
+func unmarshalObject(raw json.RawMessage, base Object, v any) bool {
+	err := json.Unmarshal(raw, v)
+	if err == nil {
+		return true
+	}
+	if adapted, ok := adapt(raw); ok && json.Unmarshal(adapted, v) == nil {
+		return true
+	}
+	decodeFailures.Add(1)
+	decodeMu.Lock()
+	lastDecodeErr = fmt.Errorf("decode %s %d: %w", base.Type, base.ID, err)
+	decodeMu.Unlock()
+	return false
+}
+
+var (
+	decodeFailures atomic.Uint64
+	decodeMu       sync.Mutex
+	lastDecodeErr  error
+)
+
+// DecodeFailures 返回累计无法解码而被丢弃的对象数与最近一次的错误，
+// 数量持续增加通常说明 pw-dump 的输出格式发生了变化
+func DecodeFailures() (uint64, error) {
+	decodeMu.Lock()
+	defer decodeMu.Unlock()
+	return decodeFailures.Load(), lastDecodeErr
+}
@@ -0,0 +1,158 @@
+package pwmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+type EventType int
+
+const (
+	NodeChanged EventType = iota
+	DeviceChanged
+	MetadataChanged
+	LinkChanged
+	ClientChanged
+	// Removed 对象已被移除，只有 ID 有效
+	Removed
+)
+
+// Event 为一个对象的变化，按 Type 使用 Node、Device、Metadata、Link 或 Client 中对应的字段
+type Event struct {
+	Type     EventType
+	ID       int
+	Node     Node
+	Device   Device
+	Metadata []MetadataEntry
+	Link     Link
+	Client   Client
+}
+
+// Decode 将 pw-dump 输出的一批对象解码为事件，忽略不关心的对象；
+// 无法按当前格式解析的对象会先经其他版本的适配规则改写，仍无法解析时丢弃并计入 DecodeFailures
+func Decode(objects []json.RawMessage) []Event {
+	events := make([]Event, 0, len(objects))
+	for _, raw := range objects {
+		var base Object
+		if err := json.Unmarshal(raw, &base); err != nil {
+			continue
+		}
+
+		ev := Event{ID: base.ID}
+		switch base.Type {
+		case "PipeWire:Interface:Node":
+			ev.Type = NodeChanged
+			if !unmarshalObject(raw, base, &ev.Node) {
+				continue
+			}
+		case "PipeWire:Interface:Device":
+			ev.Type = DeviceChanged
+			if !unmarshalObject(raw, base, &ev.Device) {
+				continue
+			}
+		case "PipeWire:Interface:Metadata":
+			var meta Metadata
+			if !unmarshalObject(raw, base, &meta) {
+				continue
+			}
+			ev.Type = MetadataChanged
+			ev.Metadata = meta.Metadata
+		case "PipeWire:Interface:Link":
+			ev.Type = LinkChanged
+			if !unmarshalObject(raw, base, &ev.Link) {
+				continue
+			}
+		case "PipeWire:Interface:Client":
+			ev.Type = ClientChanged
+			if !unmarshalObject(raw, base, &ev.Client) {
+				continue
+			}
+		case "":
+			if len(base.Info) != 0 && string(base.Info) != "null" {
+				continue
+			}
+			ev.Type = Removed
+		default:
+			continue
+		}
+		// 部分版本在移除通知中保留了 type，只有 info 为 null
+		if base.Type != "" && string(base.Info) == "null" {
+			ev = Event{ID: base.ID, Type: Removed}
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// Snapshot 以 DefaultCompat 运行一次 pw-dump 获取当前所有对象
+func Snapshot(ctx context.Context) ([]json.RawMessage, error) {
+	return DefaultCompat.Snapshot(ctx)
+}
+
+// Monitor 通过 pw-dump --monitor 监听 PipeWire 对象的变化
+type Monitor struct {
+	// Record 不为 nil 时写入 pw-dump 的原始输出，可用于之后回放
+	Record io.Writer
+	// Compat 为 nil 时使用 DefaultCompat
+	Compat *Compat
+}
+
+// Run 启动 pw-dump，将每批变化解码后发送到 events，直到 ctx 取消或 pw-dump 退出；
+// 返回时不会关闭 events
+func (m *Monitor) Run(ctx context.Context, events chan<- []Event) error {
+	compat := DefaultCompat
+	if m.Compat != nil {
+		compat = *m.Compat
+	}
+	cmd := exec.CommandContext(ctx, "pw-dump", compat.args("--monitor")...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 2 * time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var r io.Reader = stdout
+	if m.Record != nil {
+		r = io.TeeReader(stdout, m.Record)
+	}
+
+	decoder := json.NewDecoder(r)
+	var decodeErr error
+	for {
+		var objects []json.RawMessage
+		if err := decoder.Decode(&objects); err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				decodeErr = err
+			}
+			break
+		}
+		select {
+		case events <- Decode(objects):
+		case <-ctx.Done():
+		}
+	}
+
+	// 解析失败时结束 pw-dump，由调用方决定是否重新启动
+	if cmd.Process != nil {
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+	waitErr := cmd.Wait()
+	if decodeErr != nil {
+		return decodeErr
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return waitErr
+}
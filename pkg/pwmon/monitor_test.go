@@ -0,0 +1,189 @@
+package pwmon
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata 中的文件按各版本 pw-dump 的输出格式编写，只保留与本程序相关的对象：
+// pw-dump-1.0.json 为 1.0 的板载声卡与浏览器的输出流，pw-dump-0.3.json 为 0.3 的蓝牙耳机，
+// pw-dump-quirks.json 为以字符串输出数字、参数未包装为数组等需要经 adapters 改写的输出
+func loadDump(t *testing.T, name string) []Event {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objects []json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		t.Fatal(err)
+	}
+	return Decode(objects)
+}
+
+func eventByID(t *testing.T, events []Event, id int, typ EventType) Event {
+	t.Helper()
+	for _, ev := range events {
+		if ev.ID == id && ev.Type == typ {
+			return ev
+		}
+	}
+	t.Fatalf("没有找到对象 %d 的事件", id)
+	return Event{}
+}
+
+func countTypes(events []Event) map[EventType]int {
+	counts := make(map[EventType]int)
+	for _, ev := range events {
+		counts[ev.Type]++
+	}
+	return counts
+}
+
+func TestDecodePipeWire10(t *testing.T) {
+	failures, _ := DecodeFailures()
+	events := loadDump(t, "pw-dump-1.0.json")
+	if n, err := DecodeFailures(); n != failures {
+		t.Fatalf("解码失败：%v", err)
+	}
+
+	// Module 对象被忽略
+	want := map[EventType]int{NodeChanged: 2, DeviceChanged: 1, MetadataChanged: 1, LinkChanged: 1, ClientChanged: 2}
+	if got := countTypes(events); len(got) != len(want) {
+		t.Errorf("事件数量为 %v，期望 %v", got, want)
+	} else {
+		for typ, n := range want {
+			if got[typ] != n {
+				t.Errorf("事件数量为 %v，期望 %v", got, want)
+				break
+			}
+		}
+	}
+
+	sink := eventByID(t, events, 58, NodeChanged).Node
+	props := sink.Info.Props
+	if props.NodeName != "alsa_output.pci-0000_00_1f.3.analog-stereo" || props.MediaClass != "Audio/Sink" || props.DeviceID != 47 {
+		t.Errorf("sink 节点属性为 %+v", props)
+	}
+	if props.ObjectSerial.String() != "58" || props.PrioritySession.String() != "1009" || props.CardDevice.String() != "0" {
+		t.Errorf("数字属性为 serial %q、priority %q、card.profile.device %q", props.ObjectSerial, props.PrioritySession, props.CardDevice)
+	}
+	if params := sink.Info.Params.Props; len(params) != 2 || params[0].Mute == nil || *params[0].Mute || len(params[0].ChannelVolumes) != 2 {
+		t.Errorf("Props 参数为 %+v", params)
+	}
+
+	stream := eventByID(t, events, 70, NodeChanged).Node.Info.Props
+	if stream.ApplicationName.String() != "Firefox" || stream.ApplicationPID.String() != "4021" || stream.ClientID.String() != "41" {
+		t.Errorf("输出流属性为 %+v", stream)
+	}
+
+	dev := eventByID(t, events, 47, DeviceChanged).Device
+	if dev.Info.Props.DeviceDescription != "Built-in Audio" {
+		t.Errorf("设备描述为 %q", dev.Info.Props.DeviceDescription)
+	}
+	routes := dev.Info.Params.Route
+	if len(routes) != 2 || routes[0].PortType() != "headphones" || routes[0].Info["card.profile.port"] != "3" || routes[1].PortType() != "mic" {
+		t.Errorf("Route 为 %+v", routes)
+	}
+	if routes[0].Direction != "Output" || routes[0].Available != "yes" || routes[0].Profile != 1 || len(routes[0].Devices) != 1 {
+		t.Errorf("Route 字段为 %+v", routes[0])
+	}
+	if enum := dev.Info.Params.EnumRoute; len(enum) != 2 || enum[0].PortType() != "speaker" {
+		t.Errorf("EnumRoute 为 %+v", enum)
+	}
+	if profile := dev.Info.Params.Profile; len(profile) != 1 || profile[0].Name != "output:analog-stereo+input:analog-stereo" {
+		t.Errorf("Profile 为 %+v", profile)
+	}
+
+	link := eventByID(t, events, 88, LinkChanged).Link.Info
+	if link.OutputNodeID != 70 || link.OutputPortID != 73 || link.InputNodeID != 58 || link.InputPortID != 60 || link.State != "active" {
+		t.Errorf("连接为 %+v", link)
+	}
+
+	client := eventByID(t, events, 34, ClientChanged).Client.Info.Props
+	if client.ApplicationBinary.String() != "wireplumber" || client.SecPID.String() != "1523" || client.Protocol.String() != "protocol-native" {
+		t.Errorf("客户端属性为 %+v", client)
+	}
+	if pulse := eventByID(t, events, 41, ClientChanged).Client.Info.Props; pulse.SecPID.String() != "" || pulse.ApplicationPID.String() != "4021" {
+		t.Errorf("经 pipewire-pulse 连接的客户端属性为 %+v", pulse)
+	}
+
+	meta := eventByID(t, events, 38, MetadataChanged).Metadata
+	if len(meta) != 3 || meta[1].Key != "default.audio.sink" {
+		t.Fatalf("元数据为 %+v", meta)
+	}
+	if value, ok := meta[1].Value.(map[string]any); !ok || value["name"] != "alsa_output.pci-0000_00_1f.3.analog-stereo" {
+		t.Errorf("默认输出设备为 %v", meta[1].Value)
+	}
+}
+
+func TestDecodePipeWire03(t *testing.T) {
+	failures, _ := DecodeFailures()
+	events := loadDump(t, "pw-dump-0.3.json")
+	if n, err := DecodeFailures(); n != failures {
+		t.Fatalf("解码失败：%v", err)
+	}
+
+	dev := eventByID(t, events, 52, DeviceChanged).Device
+	if dev.Info.Props.BluezAddress != "00:1B:66:AA:BB:CC" || dev.Info.Props.DeviceAlias != "MOMENTUM 4" {
+		t.Errorf("蓝牙设备属性为 %+v", dev.Info.Props)
+	}
+	if routes := dev.Info.Params.Route; len(routes) != 1 || routes[0].PortType() != "headset" {
+		t.Errorf("Route 为 %+v", routes)
+	}
+	if profile := dev.Info.Params.Profile; len(profile) != 1 || profile[0].Name != "a2dp-sink" {
+		t.Errorf("Profile 为 %+v", profile)
+	}
+
+	sink := eventByID(t, events, 64, NodeChanged).Node.Info.Props
+	if sink.DeviceID != 52 || sink.CardDevice.String() != "1" || sink.ObjectSerial.String() != "" {
+		t.Errorf("蓝牙 sink 属性为 %+v", sink)
+	}
+
+	meta := eventByID(t, events, 31, MetadataChanged).Metadata
+	if len(meta) != 2 || meta[1].Key != "target.node" || meta[1].Subject != 70 || meta[1].Value != float64(64) {
+		t.Errorf("元数据为 %+v", meta)
+	}
+
+	// 0.3 的移除通知没有 type
+	eventByID(t, events, 75, Removed)
+}
+
+func TestDecodeQuirks(t *testing.T) {
+	failures, _ := DecodeFailures()
+	events := loadDump(t, "pw-dump-quirks.json")
+	// 最后一个节点的 device.id 无法解析，被丢弃并计入 DecodeFailures
+	if n, _ := DecodeFailures(); n != failures+1 {
+		t.Errorf("解码失败的对象数增加了 %d，期望 1", n-failures)
+	}
+	for _, ev := range events {
+		if ev.ID == 91 {
+			t.Errorf("无法解析的对象产生了事件 %+v", ev)
+		}
+	}
+
+	sink := eventByID(t, events, 60, NodeChanged).Node.Info.Props
+	if sink.DeviceID != 61 || sink.ObjectSerial.String() != "60" {
+		t.Errorf("以字符串输出的数字属性解析为 %+v", sink)
+	}
+
+	dev := eventByID(t, events, 61, DeviceChanged).Device
+	routes := dev.Info.Params.Route
+	if len(routes) != 1 || routes[0].PortType() != "speaker" || len(routes[0].Info) != 1 {
+		t.Errorf("未包装为数组的 Route 解析为 %+v", routes)
+	}
+	enum := dev.Info.Params.EnumRoute
+	if len(enum) != 2 || enum[0].PortType() != "headphones" || enum[1].Info["port.hidden"] != "true" || enum[1].Info["card.profile.port"] != "2" {
+		t.Errorf("EnumRoute 解析为 %+v", enum)
+	}
+
+	link := eventByID(t, events, 90, LinkChanged).Link.Info
+	if link.OutputNodeID != 70 || link.InputNodeID != 60 || link.InputPortID != 62 || link.State != "paused" {
+		t.Errorf("以字符串输出的连接解析为 %+v", link)
+	}
+
+	// 部分版本的移除通知保留了 type
+	eventByID(t, events, 88, Removed)
+}
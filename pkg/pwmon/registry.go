@@ -0,0 +1,360 @@
+package pwmon
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry 缓存节点、设备、连接与客户端对象
+type Registry struct {
+	nodesMu sync.RWMutex
+	nodes   map[int]Node
+
+	devsMu  sync.RWMutex
+	devices map[int]Device
+
+	linksMu sync.RWMutex
+	links   map[int]Link
+
+	clientsMu sync.RWMutex
+	clients   map[int]Client
+
+	// updatedAt 与 removedAt 记录对象最近一次更新与移除的时间，Sync 据此判断快照是否已经过时；
+	// 受 stampMu 保护，总是在持有对象所在的锁之后获取
+	stampMu   sync.Mutex
+	updatedAt map[int]time.Time
+	removedAt map[int]time.Time
+
+	onRemove func(id int)
+}
+
+// NewRegistry 创建缓存，onRemove 不为 nil 时在对象被移除后调用，用于清理调用方以对象索引为键的状态
+func NewRegistry(onRemove func(id int)) *Registry {
+	return &Registry{
+		nodes:     make(map[int]Node),
+		devices:   make(map[int]Device),
+		links:     make(map[int]Link),
+		clients:   make(map[int]Client),
+		updatedAt: make(map[int]time.Time),
+		removedAt: make(map[int]time.Time),
+		onRemove:  onRemove,
+	}
+}
+
+func (r *Registry) touch(id int) {
+	r.stampMu.Lock()
+	r.updatedAt[id] = time.Now()
+	r.stampMu.Unlock()
+}
+
+// changedSince 判断对象在 t 之后是否更新或移除过
+func (r *Registry) changedSince(id int, t time.Time) bool {
+	r.stampMu.Lock()
+	defer r.stampMu.Unlock()
+	return !r.updatedAt[id].Before(t) || !r.removedAt[id].Before(t)
+}
+
+// IsAudioNode 判断节点是否需要缓存：只有音频设备节点与音频流节点参与判断
+func IsAudioNode(node Node) bool {
+	class := node.Info.Props.MediaClass
+	return strings.HasPrefix(class, "Audio/") || strings.HasPrefix(class, "Stream/") && strings.HasSuffix(class, "/Audio")
+}
+
+// slimDevice 只保留路由中的 port.type 与当前配置文件，减小长时间运行时的内存占用
+func slimDevice(dev Device) Device {
+	slim := func(routes []RouteInfo) []RouteInfo {
+		out := make([]RouteInfo, len(routes))
+		for i, r := range routes {
+			if portType := r.PortType(); portType != "" {
+				r.Info = InfoDict{"port.type": portType}
+			} else {
+				r.Info = nil
+			}
+			out[i] = r
+		}
+		return out
+	}
+	dev.Info.Params.Route = slim(dev.Info.Params.Route)
+	dev.Info.Params.EnumRoute = slim(dev.Info.Params.EnumRoute)
+	if len(dev.Info.Params.Profile) > 0 {
+		p := dev.Info.Params.Profile[0]
+		dev.Info.Params.Profile = []ProfileInfo{{Index: p.Index, Name: p.Name}}
+	}
+	return dev
+}
+
+func (r *Registry) Node(id int) (Node, bool) {
+	r.nodesMu.RLock()
+	defer r.nodesMu.RUnlock()
+	node, ok := r.nodes[id]
+	return node, ok
+}
+
+func (r *Registry) Device(id int) (Device, bool) {
+	r.devsMu.RLock()
+	defer r.devsMu.RUnlock()
+	dev, ok := r.devices[id]
+	return dev, ok
+}
+
+func (r *Registry) PutNode(node Node) {
+	r.nodesMu.Lock()
+	defer r.nodesMu.Unlock()
+	r.touch(node.ID)
+	if !IsAudioNode(node) {
+		delete(r.nodes, node.ID)
+		return
+	}
+	r.nodes[node.ID] = node
+}
+
+func (r *Registry) PutDevice(dev Device) {
+	r.devsMu.Lock()
+	r.touch(dev.ID)
+	r.devices[dev.ID] = slimDevice(dev)
+	r.devsMu.Unlock()
+}
+
+func (r *Registry) PutLink(link Link) {
+	r.linksMu.Lock()
+	r.touch(link.ID)
+	r.links[link.ID] = link
+	r.linksMu.Unlock()
+}
+
+func (r *Registry) PutClient(c Client) {
+	r.clientsMu.Lock()
+	r.touch(c.ID)
+	r.clients[c.ID] = c
+	r.clientsMu.Unlock()
+}
+
+func (r *Registry) Client(id int) (Client, bool) {
+	r.clientsMu.RLock()
+	defer r.clientsMu.RUnlock()
+	c, ok := r.clients[id]
+	return c, ok
+}
+
+// Clients 返回当前所有客户端的快照
+func (r *Registry) Clients() []Client {
+	r.clientsMu.RLock()
+	defer r.clientsMu.RUnlock()
+	clients := make([]Client, 0, len(r.clients))
+	for _, c := range r.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// LinkedNodes 返回与节点直接相连的节点：downstream 为真时返回其输出端口连接到的节点，否则返回连接到其输入端口的节点
+func (r *Registry) LinkedNodes(id int, downstream bool) []int {
+	r.linksMu.RLock()
+	defer r.linksMu.RUnlock()
+	var out []int
+	for _, link := range r.links {
+		from, to := link.Info.OutputNodeID, link.Info.InputNodeID
+		if !downstream {
+			from, to = to, from
+		}
+		if from == id && !slices.Contains(out, to) {
+			out = append(out, to)
+		}
+	}
+	return out
+}
+
+// LinksFrom 返回从节点输出端口出发的连接
+func (r *Registry) LinksFrom(id int) []int {
+	r.linksMu.RLock()
+	defer r.linksMu.RUnlock()
+	var out []int
+	for linkID, link := range r.links {
+		if link.Info.OutputNodeID == id {
+			out = append(out, linkID)
+		}
+	}
+	return out
+}
+
+func (r *Registry) Remove(ids ...int) {
+	r.nodesMu.Lock()
+	r.devsMu.Lock()
+	r.linksMu.Lock()
+	r.clientsMu.Lock()
+	for _, id := range ids {
+		delete(r.nodes, id)
+		delete(r.devices, id)
+		delete(r.links, id)
+		delete(r.clients, id)
+	}
+	r.stampMu.Lock()
+	now := time.Now()
+	for _, id := range ids {
+		delete(r.updatedAt, id)
+		r.removedAt[id] = now
+	}
+	r.stampMu.Unlock()
+	r.clientsMu.Unlock()
+	r.linksMu.Unlock()
+	r.devsMu.Unlock()
+	r.nodesMu.Unlock()
+
+	r.removed(ids)
+}
+
+func (r *Registry) removed(ids []int) {
+	if r.onRemove == nil {
+		return
+	}
+	for _, id := range ids {
+		r.onRemove(id)
+	}
+}
+
+// Nodes 返回当前所有节点的快照
+func (r *Registry) Nodes() []Node {
+	r.nodesMu.RLock()
+	defer r.nodesMu.RUnlock()
+	nodes := make([]Node, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Devices 返回当前所有设备的快照
+func (r *Registry) Devices() []Device {
+	r.devsMu.RLock()
+	defer r.devsMu.RUnlock()
+	devices := make([]Device, 0, len(r.devices))
+	for _, dev := range r.devices {
+		devices = append(devices, dev)
+	}
+	return devices
+}
+
+// NodeIDByName 按 node.name 查找节点，classes 不为空时只匹配 media.class 属于其中的节点
+func (r *Registry) NodeIDByName(name string, classes ...string) (int, bool) {
+	r.nodesMu.RLock()
+	defer r.nodesMu.RUnlock()
+	for id, node := range r.nodes {
+		if node.Info.Props.NodeName != name {
+			continue
+		}
+		if len(classes) > 0 && !slices.Contains(classes, node.Info.Props.MediaClass) {
+			continue
+		}
+		return id, true
+	}
+	return 0, false
+}
+
+func (r *Registry) Len() (nodes, devices int) {
+	r.nodesMu.RLock()
+	nodes = len(r.nodes)
+	r.nodesMu.RUnlock()
+	r.devsMu.RLock()
+	devices = len(r.devices)
+	r.devsMu.RUnlock()
+	return nodes, devices
+}
+
+// Sync 以 taken 时开始获取的完整快照校正缓存。快照开始后更新或移除过的对象以缓存为准，不被快照覆盖；
+// 快照中已不存在的对象不在此处删除，而是返回给调用方按移除事件处理
+func (r *Registry) Sync(taken time.Time, nodes map[int]Node, devices map[int]Device, links map[int]Link, clients map[int]Client) []int {
+	var stale []int
+
+	r.nodesMu.Lock()
+	for id := range r.nodes {
+		if _, ok := nodes[id]; !ok && !r.changedSince(id, taken) {
+			stale = append(stale, id)
+		}
+	}
+	for id, node := range nodes {
+		switch {
+		case r.changedSince(id, taken):
+		case IsAudioNode(node):
+			r.nodes[id] = node
+		default:
+			delete(r.nodes, id)
+		}
+	}
+	r.nodesMu.Unlock()
+
+	r.devsMu.Lock()
+	for id := range r.devices {
+		if _, ok := devices[id]; !ok && !r.changedSince(id, taken) {
+			stale = append(stale, id)
+		}
+	}
+	for id, dev := range devices {
+		if !r.changedSince(id, taken) {
+			r.devices[id] = slimDevice(dev)
+		}
+	}
+	r.devsMu.Unlock()
+
+	r.linksMu.Lock()
+	for id := range r.links {
+		if _, ok := links[id]; !ok && !r.changedSince(id, taken) {
+			stale = append(stale, id)
+		}
+	}
+	for id, link := range links {
+		if !r.changedSince(id, taken) {
+			r.links[id] = link
+		}
+	}
+	r.linksMu.Unlock()
+
+	r.clientsMu.Lock()
+	for id := range r.clients {
+		if _, ok := clients[id]; !ok && !r.changedSince(id, taken) {
+			stale = append(stale, id)
+		}
+	}
+	for id, c := range clients {
+		if !r.changedSince(id, taken) {
+			r.clients[id] = c
+		}
+	}
+	r.clientsMu.Unlock()
+
+	// 早于本次快照的移除记录已不会再被用到
+	r.stampMu.Lock()
+	for id, t := range r.removedAt {
+		if t.Before(taken) {
+			delete(r.removedAt, id)
+		}
+	}
+	r.stampMu.Unlock()
+
+	return stale
+}
+
+// Reset 在连接中断时清空缓存；对象可能仍然存在，因此不调用 onRemove
+func (r *Registry) Reset() {
+	r.nodesMu.Lock()
+	r.nodes = make(map[int]Node)
+	r.nodesMu.Unlock()
+
+	r.devsMu.Lock()
+	r.devices = make(map[int]Device)
+	r.devsMu.Unlock()
+
+	r.linksMu.Lock()
+	r.links = make(map[int]Link)
+	r.linksMu.Unlock()
+
+	r.clientsMu.Lock()
+	r.clients = make(map[int]Client)
+	r.clientsMu.Unlock()
+
+	r.stampMu.Lock()
+	r.updatedAt = make(map[int]time.Time)
+	r.removedAt = make(map[int]time.Time)
+	r.stampMu.Unlock()
+}
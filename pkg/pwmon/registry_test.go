@@ -0,0 +1,61 @@
+package pwmon
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func sinkNode(id int, name string) Node {
+	var n Node
+	n.ID = id
+	n.Info.Props.NodeName = name
+	n.Info.Props.MediaClass = "Audio/Sink"
+	return n
+}
+
+func TestSync(t *testing.T) {
+	var removed []int
+	r := NewRegistry(func(id int) { removed = append(removed, id) })
+	r.PutNode(sinkNode(50, "old"))
+	r.PutNode(sinkNode(51, "gone"))
+	r.PutNode(sinkNode(52, "removed-later"))
+	r.PutClient(Client{ID: 30})
+
+	taken := time.Now()
+	// 快照开始后监听到的变化
+	r.PutNode(sinkNode(53, "added"))
+	r.PutNode(sinkNode(50, "renamed"))
+	r.Remove(52)
+	removed = nil
+
+	nodes := map[int]Node{
+		50: sinkNode(50, "old"),
+		52: sinkNode(52, "removed-later"),
+		54: sinkNode(54, "missed"),
+	}
+	stale := r.Sync(taken, nodes, nil, nil, nil)
+	slices.Sort(stale)
+
+	if !slices.Equal(stale, []int{30, 51}) {
+		t.Errorf("快照中已不存在的对象为 %v，期望 [30 51]", stale)
+	}
+	if len(removed) > 0 {
+		t.Errorf("Sync 直接移除了对象 %v", removed)
+	}
+	if _, ok := r.Node(51); !ok {
+		t.Error("快照中已不存在的对象应由调用方移除")
+	}
+	if n, _ := r.Node(50); n.Info.Props.NodeName != "renamed" {
+		t.Errorf("快照覆盖了较新的节点 %q", n.Info.Props.NodeName)
+	}
+	if _, ok := r.Node(53); !ok {
+		t.Error("快照开始后新增的节点被丢弃")
+	}
+	if _, ok := r.Node(52); ok {
+		t.Error("快照开始后移除的节点被恢复")
+	}
+	if _, ok := r.Node(54); !ok {
+		t.Error("没有从快照中补充缓存缺少的节点")
+	}
+}
@@ -0,0 +1,202 @@
+// Package pwmon 解析 pw-dump 输出的 PipeWire 对象，维护节点与设备缓存，
+// 并以事件通道的形式提供对象的变化
+package pwmon
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Object 为 pw-dump 输出中每个对象的公共部分；Type 为空且 Info 为 null 表示对象已被移除
+type Object struct {
+	ID   int             `json:"id"`
+	Type string          `json:"type"`
+	Info json.RawMessage `json:"info"`
+}
+
+// PropString 兼容 pw-dump 将数字形式的属性值输出为 JSON 数字的情况
+type PropString string
+
+func (p *PropString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = PropString(s)
+		return nil
+	}
+	*p = PropString(strings.Trim(string(data), "\""))
+	return nil
+}
+
+func (p PropString) String() string {
+	if p == "null" {
+		return ""
+	}
+	return string(p)
+}
+
+type Node struct {
+	ID   int `json:"id"`
+	Info struct {
+		Props struct {
+			NodeName        string     `json:"node.name"`
+			DeviceID        int        `json:"device.id"`
+			MediaClass      string     `json:"media.class"`
+			ObjectSerial    PropString `json:"object.serial"`
+			CardDevice      PropString `json:"card.profile.device"`
+			TargetObject    PropString `json:"target.object"`
+			NodeTarget      PropString `json:"node.target"`
+			LinkGroup       PropString `json:"node.link-group"`
+			PrioritySession PropString `json:"priority.session"`
+			NodeGroup       PropString `json:"node.group"`
+			CaptureSink     PropString `json:"stream.capture.sink"`
+			ClientID        PropString `json:"client.id"`
+
+			ApplicationName   PropString `json:"application.name"`
+			ApplicationBinary PropString `json:"application.process.binary"`
+			ApplicationPID    PropString `json:"application.process.id"`
+			MediaRole         PropString `json:"media.role"`
+			// PortalAppID 为通过门户连接的沙盒应用（Flatpak）的应用 ID，由 PipeWire 根据连接凭据设置
+			PortalAppID PropString `json:"pipewire.access.portal.app_id"`
+		} `json:"props"`
+		Params struct {
+			Props []NodeProps `json:"Props"`
+		} `json:"params"`
+	} `json:"info"`
+}
+
+type NodeProps struct {
+	Mute           *bool     `json:"mute"`
+	ChannelVolumes []float64 `json:"channelVolumes"`
+}
+
+type Device struct {
+	ID   int `json:"id"`
+	Info struct {
+		Props struct {
+			DeviceName        string `json:"device.name"`
+			DeviceAlias       string `json:"device.alias"`
+			DeviceDescription string `json:"device.description"`
+			BluezAddress      string `json:"api.bluez5.address"`
+		} `json:"props"`
+		Params struct {
+			Route     []RouteInfo   `json:"Route"`
+			EnumRoute []RouteInfo   `json:"EnumRoute"`
+			Profile   []ProfileInfo `json:"Profile"`
+		} `json:"params"`
+	} `json:"info"`
+}
+
+// Link 为两个节点端口之间的连接，用于追踪 filter-chain 等虚拟节点实际连接到的设备
+type Link struct {
+	ID   int `json:"id"`
+	Info struct {
+		OutputNodeID int    `json:"output-node-id"`
+		OutputPortID int    `json:"output-port-id"`
+		InputNodeID  int    `json:"input-node-id"`
+		InputPortID  int    `json:"input-port-id"`
+		State        string `json:"state"`
+	} `json:"info"`
+}
+
+// Client 为连接到 PipeWire 的客户端，用于判断默认设备的变更由哪个程序发起
+type Client struct {
+	ID   int `json:"id"`
+	Info struct {
+		Props struct {
+			ApplicationName   PropString `json:"application.name"`
+			ApplicationBinary PropString `json:"application.process.binary"`
+			ApplicationPID    PropString `json:"application.process.id"`
+			// SecPID 为 PipeWire 根据套接字凭据得到的进程 ID，经 pipewire-pulse 连接的客户端没有此项
+			SecPID   PropString `json:"pipewire.sec.pid"`
+			Protocol PropString `json:"pipewire.protocol"`
+		} `json:"props"`
+	} `json:"info"`
+}
+
+type ProfileInfo struct {
+	Index int      `json:"index"`
+	Name  string   `json:"name"`
+	Info  InfoDict `json:"info"`
+}
+
+type RouteInfo struct {
+	Index       int      `json:"index"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Direction   string   `json:"direction"`
+	Priority    int      `json:"priority"`
+	Available   string   `json:"available"`
+	Device      int      `json:"device"`
+	Profile     int      `json:"profile"`
+	Devices     []int    `json:"devices"`
+	Info        InfoDict `json:"info"`
+}
+
+// PortType 返回路由 info 中的 port.type
+func (r RouteInfo) PortType() string {
+	return r.Info["port.type"]
+}
+
+// InfoDict 为 Route、Profile 等参数中的 info 字典。pw-dump 将其输出为
+// [项数, 键, 值, 键, 值...] 形式的数组，值可能是数字或布尔值，均转换为字符串保存；
+// 缺少项数、长度为奇数或以对象形式给出时同样可以解析，无法解析的内容会被忽略而不影响所在对象的解码
+type InfoDict map[string]string
+
+func (d *InfoDict) UnmarshalJSON(data []byte) error {
+	*d = nil
+
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) == nil && object != nil {
+		dict := make(InfoDict, len(object))
+		for key, raw := range object {
+			dict[key] = infoValue(raw)
+		}
+		*d = dict
+		return nil
+	}
+
+	var items []json.RawMessage
+	if json.Unmarshal(data, &items) != nil || len(items) == 0 {
+		return nil
+	}
+	// 第一项为项数，部分版本或手工构造的数据可能省略
+	var count json.Number
+	if json.Unmarshal(items[0], &count) == nil {
+		items = items[1:]
+	}
+	dict := make(InfoDict, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		var key string
+		if json.Unmarshal(items[i], &key) != nil {
+			continue
+		}
+		dict[key] = infoValue(items[i+1])
+	}
+	*d = dict
+	return nil
+}
+
+// infoValue 将 info 中的值转换为字符串：字符串去掉引号，null 为空，其他值保留 JSON 原文
+func infoValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	text := strings.TrimSpace(string(raw))
+	if text == "null" {
+		return ""
+	}
+	return text
+}
+
+type MetadataEntry struct {
+	Subject int         `json:"subject"`
+	Key     string      `json:"key"`
+	Type    string      `json:"type"`
+	Value   interface{} `json:"value"`
+}
+
+type Metadata struct {
+	ID       int             `json:"id"`
+	Metadata []MetadataEntry `json:"metadata"`
+}
@@ -0,0 +1,71 @@
+package pwmon
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+)
+
+func TestInfoDict(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want InfoDict
+	}{
+		{name: "带项数的数组", data: `[2, "port.type", "headphones", "device.icon_name", "audio-headphones"]`, want: InfoDict{"port.type": "headphones", "device.icon_name": "audio-headphones"}},
+		{name: "省略项数", data: `["port.type", "speaker"]`, want: InfoDict{"port.type": "speaker"}},
+		{name: "长度为奇数时忽略最后的键", data: `[1, "port.type", "hdmi", "device.icon_name"]`, want: InfoDict{"port.type": "hdmi"}},
+		{name: "数字与布尔值", data: `[3, "card.profile.port", 3, "port.hidden", true, "volume", 0.5]`, want: InfoDict{"card.profile.port": "3", "port.hidden": "true", "volume": "0.5"}},
+		{name: "null 值", data: `[1, "port.availability-group", null]`, want: InfoDict{"port.availability-group": ""}},
+		{name: "非字符串的键被跳过", data: `[2, 1, "x", "port.type", "mic"]`, want: InfoDict{"port.type": "mic"}},
+		{name: "对象形式", data: `{"port.type": "headset", "priority": 10}`, want: InfoDict{"port.type": "headset", "priority": "10"}},
+		{name: "只有项数", data: `[0]`, want: InfoDict{}},
+		{name: "空数组", data: `[]`},
+		{name: "null", data: `null`},
+		{name: "无法解析的内容", data: `"port.type"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got InfoDict
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatalf("解析失败：%v", err)
+			}
+			if !maps.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("解析结果为 %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// info 无法解析时不影响所在路由的其他字段
+func TestRouteInfoMalformed(t *testing.T) {
+	var r RouteInfo
+	if err := json.Unmarshal([]byte(`{"index": 3, "name": "analog-output-headphones", "available": "no", "info": "garbage"}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Index != 3 || r.Name != "analog-output-headphones" || r.Available != "no" || r.PortType() != "" {
+		t.Errorf("解析结果为 %+v", r)
+	}
+}
+
+func TestPropString(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`"4021"`, "4021"},
+		{`4021`, "4021"},
+		{`true`, "true"},
+		{`null`, ""},
+		{`""`, ""},
+	}
+	for _, tt := range tests {
+		var p PropString
+		if err := json.Unmarshal([]byte(tt.data), &p); err != nil {
+			t.Fatalf("%s：%v", tt.data, err)
+		}
+		if p.String() != tt.want {
+			t.Errorf("%s 解析为 %q，期望 %q", tt.data, p.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/pkg/mpris"
+	"go.uber.org/zap"
+)
+
+const mprisPrefix = mpris.Prefix
+
+type Player = mpris.Player
+
+var (
+	playersMu      sync.RWMutex
+	playerRegistry *mpris.Registry
+)
+
+// mprisPlayers 返回当前会话总线连接上的播放器缓存，尚未连接时返回 nil
+func mprisPlayers() *mpris.Registry {
+	playersMu.RLock()
+	defer playersMu.RUnlock()
+	return playerRegistry
+}
+
+func lookupPlayer(name string) (Player, bool) {
+	reg := mprisPlayers()
+	if reg == nil {
+		return Player{}, false
+	}
+	return reg.Lookup(name)
+}
+
+func playerNames() []string {
+	reg := mprisPlayers()
+	if reg == nil {
+		return nil
+	}
+	return reg.Names()
+}
+
+// playerSummaries 返回形如「Spotify（Playing）」的播放器列表，用于状态查询
+func playerSummaries() []string {
+	reg := mprisPlayers()
+	if reg == nil {
+		return []string{}
+	}
+	players := reg.Players()
+	summaries := make([]string, 0, len(players))
+	for _, p := range players {
+		name := p.Identity
+		if name == "" {
+			name = p.ShortName()
+		}
+		status := p.PlaybackStatus
+		if t, ok := pausedTrack(p.Name); ok {
+			status += "，暂停于" + trackSummary(t)
+		}
+		if streams := streamNames(playerStreams(p)); len(streams) > 0 {
+			status += "，输出流：" + strings.Join(streams, "、")
+		}
+		summaries = append(summaries, name+"（"+status+"）")
+	}
+	sort.Strings(summaries)
+	return summaries
+}
+
+// forgetPausedPlayer 将播放器移出待恢复列表：只有最近一次暂停由本程序发出时才会自动恢复
+func forgetPausedPlayer(name, reason string) {
+	pausedMu.Lock()
+	// 忽略暂停指令发出前就已排队的状态变化信号
+	if time.Since(pausedAt) < time.Second {
+		pausedMu.Unlock()
+		return
+	}
+	kept := pausedPlayers[:0:0]
+	for _, p := range pausedPlayers {
+		if p != name {
+			kept = append(kept, p)
+		}
+	}
+	changed := len(kept) != len(pausedPlayers)
+	pausedPlayers = kept
+	delete(pausedSince, name)
+	delete(pausedTracks, name)
+	pausedMu.Unlock()
+
+	if changed {
+		zap.L().Debug("不再自动恢复播放器", zap.String("player", name), zap.String("reason", reason))
+		saveState()
+		go notifyStateChanged()
+	}
+}
+
+// startPlayerRegistry 通过 NameOwnerChanged 与 PropertiesChanged 维护 MPRIS 播放器列表，
+// 暂停时无需再逐一查询总线上的名称与播放状态
+func startPlayerRegistry() {
+	conn := sessionBus()
+	if conn == nil {
+		return
+	}
+
+	reg := mpris.NewRegistry(conn)
+	reg.OnAdd = func(p Player) {
+		zap.L().Debug("发现播放器", zap.String("player", p.Name), zap.String("identity", p.Identity))
+	}
+	reg.OnRemove = func(name string) {
+		zap.L().Debug("播放器已退出", zap.String("player", name))
+		forgetPausedPlayer(name, "播放器已退出")
+	}
+	reg.OnStatusChanged = func(name, status string) {
+		if status != mpris.Paused {
+			forgetPausedPlayer(name, "播放状态已被用户改变")
+		}
+	}
+
+	playersMu.Lock()
+	playerRegistry = reg
+	playersMu.Unlock()
+
+	if err := reg.Start(); err != nil {
+		zap.L().Warn("无法订阅播放器事件", zap.Error(err))
+	}
+}
+
+// playerAction 按 [[players.rules]] 返回对播放器执行的动作：pause、stop、mute 或 ignore
+func playerAction(ctx context.Context, playerName string) string {
+	p, _ := lookupPlayer(playerName)
+	identity := p.Identity
+	if identity == "" {
+		identity = playerIdentity(ctx, playerName)
+	}
+	for _, rule := range config.Players.Rules {
+		if matchPlayer(playerName, identity, []string{rule.Match}) || (p.DesktopEntry != "" && matchName(rule.Match, p.DesktopEntry)) {
+			return rule.Action
+		}
+	}
+	return "pause"
+}
+
+// playerControllable 判断播放器是否接受 Pause 指令（CanControl 与 CanPause 均为 true）
+func playerControllable(ctx context.Context, playerName string) bool {
+	if reg := mprisPlayers(); reg != nil {
+		return reg.Controllable(ctx, playerName)
+	}
+	return true
+}
+
+// routedElsewhere 判断流是否固定输出到 sink 以外的设备：显式指定了其他 sink，且没有实际连接到 sink
+func routedElsewhere(stream Node, sink Node) bool {
+	if linked, _ := streamLinkedTo(stream, sink); linked {
+		return false
+	}
+	return streamTarget(stream) != "" && !streamExplicitlyTargets(stream, sink)
+}
+
+// routedTo 返回判断播放器是否输出到 sinkID 的函数，只暂停声音实际会从该设备发出的播放器，
+// 例如不影响在另一台设备上进行的通话；找不到播放器的输出流时无法判断，仍视为输出到该设备
+func routedTo(sinkID int) func(ctx context.Context, playerName string) bool {
+	if !config.Players.OnlyRouted {
+		return nil
+	}
+	return func(ctx context.Context, playerName string) bool {
+		sink, ok := registry.Node(sinkID)
+		if !ok {
+			return true
+		}
+		p, ok := lookupPlayer(playerName)
+		if !ok {
+			return true
+		}
+		streams := playerStreams(p)
+		if len(streams) == 0 {
+			return true
+		}
+		for _, id := range streams {
+			if stream, ok := registry.Node(id); !ok || !routedElsewhere(stream, sink) {
+				return true
+			}
+		}
+		zap.L().Info("播放器输出到其他设备，已跳过", zap.String("player", playerName), zap.String("sink", sink.Info.Props.NodeName), zap.Strings("streams", streamNames(streams)))
+		return false
+	}
+}
+
+// mutePlayer 静音播放器的输出流而不暂停播放，切回私有设备时取消静音
+func mutePlayer(playerName string) {
+	p, ok := lookupPlayer(playerName)
+	if !ok {
+		p = Player{Name: playerName}
+	}
+	streams := playerStreams(p)
+	if len(streams) == 0 {
+		zap.L().Debug("未找到播放器的输出流", zap.String("player", playerName))
+		return
+	}
+
+	zap.L().Info("按规则静音播放器", zap.String("player", playerName), zap.Strings("streams", streamNames(streams)))
+	for _, id := range streams {
+		setPipewireMute(id, true)
+	}
+	policyMu.Lock()
+	policyMuted = append(policyMuted, streams...)
+	policyMu.Unlock()
+}
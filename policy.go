@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	policyMu    sync.Mutex
+	policyMuted []int
+)
+
+// applyPolicy 按 mode（通常为配置的 mode，规则表达式可以改为其他方式）处理切换到公共设备的事件，
+// targets 为空时按 guard 配置选择需要静音的节点
+func applyPolicy(entry int, mode string, nodeID int, targets []int, reason string, dev Device) {
+	if targets == nil {
+		targets = guardTargets(nodeID)
+	}
+	switch mode {
+	case "duck":
+		if isSnoozed() {
+			zap.L().Info("自动暂停已暂时停用，跳过本次降低音量", zap.String("reason", reason))
+			return
+		}
+		if suppressedByCall(reason) || suppressedByScreencast(reason) {
+			return
+		}
+		zap.L().Info("降低输出音量，触发事件为【"+reason+"】", zap.Float64("level", config.Duck.Level))
+		applyStreamPolicy(nodeID)
+		duckNode(nodeID, config.Duck.Level)
+	case "mute-only":
+		if isSnoozed() {
+			zap.L().Info("自动暂停已暂时停用，跳过本次静音", zap.String("reason", reason))
+			return
+		}
+		if suppressedByCall(reason) || suppressedByScreencast(reason) {
+			return
+		}
+		zap.L().Info("静音输出，触发事件为【"+reason+"】", zap.Ints("nodes", targets))
+		applyStreamPolicy(nodeID)
+		for _, id := range targets {
+			setPipewireMute(id, true)
+		}
+		policyMu.Lock()
+		policyMuted = append(policyMuted, targets...)
+		policyMu.Unlock()
+	case "safe-sink":
+		zap.L().Info("切换到安全输出并暂停播放器，触发事件为【" + reason + "】")
+		go pauseWithSafeSink(entry, nodeID, reason, dev)
+	default:
+		zap.L().Info("暂停播放器，触发事件为【" + reason + "】")
+		pauseWithGuard(entry, nodeID, targets, reason, dev)
+	}
+}
+
+// releasePolicy 撤销 duck 与 mute-only 模式留下的音量与静音状态
+func releasePolicy() {
+	restoreDucked()
+	releaseKeptGate()
+
+	policyMu.Lock()
+	targets := policyMuted
+	policyMuted = nil
+	policyMu.Unlock()
+
+	for _, id := range targets {
+		setPipewireMute(id, false)
+	}
+}
@@ -0,0 +1,228 @@
+// Package policy 以显式状态机描述默认设备在私有与公共设备之间的切换，
+// 调用方负责将 PipeWire 事件转换为 Event，并根据返回的 Decision 执行暂停或恢复
+package policy
+
+import (
+	"sync"
+	"time"
+)
+
+type Class int
+
+const (
+	Unclassified Class = iota
+	Private
+	Public
+)
+
+func (c Class) String() string {
+	switch c {
+	case Private:
+		return "private"
+	case Public:
+		return "public"
+	}
+	return "unknown"
+}
+
+type State int
+
+const (
+	Idle State = iota
+	PrivateActive
+	PublicActive
+	Transitioning
+)
+
+func (s State) String() string {
+	switch s {
+	case PrivateActive:
+		return "private-active"
+	case PublicActive:
+		return "public-active"
+	case Transitioning:
+		return "transitioning"
+	}
+	return "idle"
+}
+
+type EventType int
+
+const (
+	// SinkChanged 默认设备变更（default.audio.sink / default.audio.source）
+	SinkChanged EventType = iota
+	// RouteChanged 当前默认设备的活动路由变更
+	RouteChanged
+	// UserConfigured 用户手动选择了默认设备（default.configured.*），
+	// UserWindow 内切换到同一设备的 SinkChanged 视为用户操作
+	UserConfigured
+)
+
+type Event struct {
+	Type  EventType
+	Sink  string
+	Class Class
+}
+
+type Action int
+
+const (
+	None Action = iota
+	Pause
+	Resume
+)
+
+func (a Action) String() string {
+	switch a {
+	case Pause:
+		return "pause"
+	case Resume:
+		return "resume"
+	}
+	return "none"
+}
+
+// ParseAction 解析 pause、resume 或 none
+func ParseAction(s string) (Action, bool) {
+	switch s {
+	case "pause":
+		return Pause, true
+	case "resume":
+		return Resume, true
+	case "none":
+		return None, true
+	}
+	return None, false
+}
+
+type Decision struct {
+	Action Action
+	From   Class
+	To     Class
+	User   bool
+}
+
+// ActionFor 返回从 from 切换到 to 时应执行的动作
+func ActionFor(from, to Class) Action {
+	switch {
+	case from == Private && to == Public:
+		return Pause
+	case from == Public && to == Private:
+		return Resume
+	}
+	return None
+}
+
+// UserWindow 用户选择默认设备后，实际默认设备变更被视为用户操作的最长间隔
+const UserWindow = 2 * time.Second
+
+// Manager 表示会话管理器，不同的会话管理器写入 default.configured.* 与 default.* 的顺序不同
+type Manager int
+
+const (
+	// WirePlumber 先写入用户选择的 default.configured.*，再据此计算并写入 default.*
+	WirePlumber Manager = iota
+	// MediaSession 可能只写入 default.*，或在其之后才写入 default.configured.*
+	MediaSession
+)
+
+type Machine struct {
+	mu      sync.Mutex
+	manager Manager
+	sink    string
+	sinkAt  time.Time
+	class   Class
+
+	// 最近一次用户选择的设备及时间
+	configured   string
+	configuredAt time.Time
+
+	// now 返回当前时间，测试中可以替换
+	now func() time.Time
+}
+
+func NewMachine() *Machine {
+	return &Machine{now: time.Now}
+}
+
+func (m *Machine) userPending(now time.Time) bool {
+	return m.configured != "" && now.Sub(m.configuredAt) <= UserWindow
+}
+
+func (m *Machine) SetManager(manager Manager) {
+	m.mu.Lock()
+	m.manager = manager
+	m.mu.Unlock()
+}
+
+func (m *Machine) Handle(ev Event) Decision {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	switch ev.Type {
+	case UserConfigured:
+		if m.manager == MediaSession && ev.Sink != "" && ev.Sink == m.sink && now.Sub(m.sinkAt) <= UserWindow {
+			// 用户选择晚于实际的默认设备变更到达，返回 User 以便调用方追认尚未处理的切换
+			m.configured = ""
+			return Decision{From: m.class, To: m.class, User: true}
+		}
+		m.configured = ev.Sink
+		m.configuredAt = now
+		return Decision{From: m.class, To: m.class}
+	case SinkChanged:
+		user := m.userPending(now) && ev.Sink == m.configured
+		d := Decision{From: m.class, To: ev.Class, User: user}
+		d.Action = ActionFor(d.From, d.To)
+		m.sink = ev.Sink
+		m.sinkAt = now
+		m.class = ev.Class
+		if user {
+			m.configured = ""
+		}
+		return d
+	case RouteChanged:
+		d := Decision{From: m.class, To: ev.Class}
+		d.Action = ActionFor(d.From, d.To)
+		m.class = ev.Class
+		return d
+	}
+	return Decision{From: m.class, To: m.class}
+}
+
+func (m *Machine) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case m.userPending(m.now()) && m.configured != m.sink:
+		return Transitioning
+	case m.class == Private:
+		return PrivateActive
+	case m.class == Public:
+		return PublicActive
+	}
+	return Idle
+}
+
+func (m *Machine) Sink() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sink
+}
+
+func (m *Machine) Class() Class {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.class
+}
+
+func (m *Machine) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sink = ""
+	m.sinkAt = time.Time{}
+	m.class = Unclassified
+	m.configured = ""
+	m.configuredAt = time.Time{}
+}
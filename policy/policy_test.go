@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+// clock 为可以手动推进的时间，用于测试 UserWindow
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestMachine(manager Manager) (*Machine, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)}
+	m := NewMachine()
+	m.now = c.now
+	m.SetManager(manager)
+	return m, c
+}
+
+func TestActionFor(t *testing.T) {
+	tests := []struct {
+		from, to Class
+		want     Action
+	}{
+		{Private, Public, Pause},
+		{Public, Private, Resume},
+		{Private, Private, None},
+		{Public, Public, None},
+		{Unclassified, Public, None},
+		{Private, Unclassified, None},
+		{Unclassified, Private, None},
+	}
+	for _, tt := range tests {
+		if got := ActionFor(tt.from, tt.to); got != tt.want {
+			t.Errorf("ActionFor(%v, %v) = %v，期望 %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// step 为一次事件及其期望的决策，wait 为事件之前经过的时间
+type step struct {
+	wait   time.Duration
+	ev     Event
+	action Action
+	user   bool
+	state  State
+}
+
+func runSteps(t *testing.T, m *Machine, c *clock, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		c.advance(s.wait)
+		d := m.Handle(s.ev)
+		if d.Action != s.action || d.User != s.user {
+			t.Errorf("第 %d 步：决策为 %v（用户操作 %v），期望 %v（用户操作 %v）", i+1, d.Action, d.User, s.action, s.user)
+		}
+		if got := m.State(); got != s.state {
+			t.Errorf("第 %d 步：状态为 %v，期望 %v", i+1, got, s.state)
+		}
+	}
+}
+
+func TestMachineTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		manager Manager
+		steps   []step
+	}{
+		{
+			name: "耳机断开后回退到扬声器",
+			steps: []step{
+				{ev: Event{Type: SinkChanged, Sink: "headphones", Class: Private}, state: PrivateActive},
+				{wait: time.Minute, ev: Event{Type: SinkChanged, Sink: "speaker", Class: Public}, action: Pause, state: PublicActive},
+				{wait: time.Minute, ev: Event{Type: SinkChanged, Sink: "headphones", Class: Private}, action: Resume, state: PrivateActive},
+			},
+		},
+		{
+			name: "当前设备的路由变更",
+			steps: []step{
+				{ev: Event{Type: SinkChanged, Sink: "analog", Class: Private}, state: PrivateActive},
+				{wait: time.Second, ev: Event{Type: RouteChanged, Class: Public}, action: Pause, state: PublicActive},
+				{wait: time.Second, ev: Event{Type: RouteChanged, Class: Public}, state: PublicActive},
+				{wait: time.Second, ev: Event{Type: RouteChanged, Class: Private}, action: Resume, state: PrivateActive},
+			},
+		},
+		{
+			name: "用户选择的设备在 UserWindow 内生效",
+			steps: []step{
+				{ev: Event{Type: SinkChanged, Sink: "headphones", Class: Private}, state: PrivateActive},
+				{wait: time.Minute, ev: Event{Type: UserConfigured, Sink: "speaker"}, state: Transitioning},
+				{wait: 500 * time.Millisecond, ev: Event{Type: SinkChanged, Sink: "speaker", Class: Public}, action: Pause, user: true, state: PublicActive},
+			},
+		},
+		{
+			name: "用户选择超过 UserWindow 后不再视为用户操作",
+			steps: []step{
+				{ev: Event{Type: SinkChanged, Sink: "headphones", Class: Private}, state: PrivateActive},
+				{wait: time.Minute, ev: Event{Type: UserConfigured, Sink: "speaker"}, state: Transitioning},
+				{wait: UserWindow + time.Millisecond, ev: Event{Type: SinkChanged, Sink: "speaker", Class: Public}, action: Pause, state: PublicActive},
+			},
+		},
+		{
+			name: "用户选择只追认一次",
+			steps: []step{
+				{ev: Event{Type: SinkChanged, Sink: "headphones", Class: Private}, state: PrivateActive},
+				{wait: time.Minute, ev: Event{Type: UserConfigured, Sink: "speaker"}, state: Transitioning},
+				{wait: 100 * time.Millisecond, ev: Event{Type: SinkChanged, Sink: "speaker", Class: Public}, action: Pause, user: true, state: PublicActive},
+				{wait: 100 * time.Millisecond, ev: Event{Type: SinkChanged, Sink: "headphones", Class: Private}, action: Resume, state: PrivateActive},
+				{wait: 100 * time.Millisecond, ev: Event{Type: SinkChanged, Sink: "speaker", Class: Public}, action: Pause, state: PublicActive},
+			},
+		},
+		{
+			name:    "media-session 在默认设备变更之后写入用户选择",
+			manager: MediaSession,
+			steps: []step{
+				{ev: Event{Type: SinkChanged, Sink: "headphones", Class: Private}, state: PrivateActive},
+				{wait: time.Minute, ev: Event{Type: SinkChanged, Sink: "speaker", Class: Public}, action: Pause, state: PublicActive},
+				{wait: 300 * time.Millisecond, ev: Event{Type: UserConfigured, Sink: "speaker"}, user: true, state: PublicActive},
+			},
+		},
+		{
+			name:    "media-session 迟到的用户选择超过 UserWindow 后不追认",
+			manager: MediaSession,
+			steps: []step{
+				{ev: Event{Type: SinkChanged, Sink: "headphones", Class: Private}, state: PrivateActive},
+				{wait: time.Minute, ev: Event{Type: SinkChanged, Sink: "speaker", Class: Public}, action: Pause, state: PublicActive},
+				{wait: UserWindow + time.Millisecond, ev: Event{Type: UserConfigured, Sink: "speaker"}, state: PublicActive},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, c := newTestMachine(tt.manager)
+			runSteps(t, m, c, tt.steps)
+		})
+	}
+}
+
+func TestMachineTransitioningSettles(t *testing.T) {
+	m, c := newTestMachine(WirePlumber)
+	m.Handle(Event{Type: SinkChanged, Sink: "headphones", Class: Private})
+	m.Handle(Event{Type: UserConfigured, Sink: "speaker"})
+	if got := m.State(); got != Transitioning {
+		t.Fatalf("用户选择尚未生效时状态为 %v，期望 %v", got, Transitioning)
+	}
+	c.advance(UserWindow + time.Millisecond)
+	if got := m.State(); got != PrivateActive {
+		t.Errorf("UserWindow 结束后状态为 %v，期望 %v", got, PrivateActive)
+	}
+}
+
+func TestMachineReset(t *testing.T) {
+	m, _ := newTestMachine(WirePlumber)
+	m.Handle(Event{Type: SinkChanged, Sink: "headphones", Class: Private})
+	m.Handle(Event{Type: UserConfigured, Sink: "speaker"})
+	m.Reset()
+
+	if m.Sink() != "" || m.Class() != Unclassified || m.State() != Idle {
+		t.Fatalf("Reset 后仍保留状态：sink %q，class %v，state %v", m.Sink(), m.Class(), m.State())
+	}
+	// 重置前的用户选择不应影响之后的切换
+	if d := m.Handle(Event{Type: SinkChanged, Sink: "speaker", Class: Public}); d.User || d.Action != None {
+		t.Errorf("Reset 后首次切换的决策为 %+v", d)
+	}
+}
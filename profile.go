@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+const profileTrigger = "设备配置文件切换"
+
+// activeProfile 返回设备当前的配置文件名称，如 a2dp-sink、headset-head-unit 或 off
+func activeProfile(dev Device) string {
+	if len(dev.Info.Params.Profile) == 0 {
+		return ""
+	}
+	return dev.Info.Params.Profile[0].Name
+}
+
+// profileAction 返回第一条与配置文件切换匹配的规则的动作，留空的 from/to 匹配任意配置文件
+func profileAction(from, to string) string {
+	for _, rule := range config.Profiles.Rules {
+		if rule.From != "" && !matchPattern(rule.From, from) {
+			continue
+		}
+		if rule.To != "" && !matchPattern(rule.To, to) {
+			continue
+		}
+		return rule.Action
+	}
+	return ""
+}
+
+// handleProfileChange 监听当前默认输出所在设备的配置文件切换，如通话开始时蓝牙耳机从 A2DP 切换到 HFP，
+// 按 [profiles] 中的规则暂停或恢复播放器；配置文件切换时节点会被重建，需在更新缓存前与旧设备比较
+func handleProfileChange(newDev Device) {
+	oldDev, exists := registry.Device(newDev.ID)
+	if !exists {
+		return
+	}
+	from, to := activeProfile(oldDev), activeProfile(newDev)
+	if from == "" || to == "" || from == to {
+		return
+	}
+	sink := sinkPolicy.Sink()
+	devID, ok := GetDeviceIDBySinkName(sink)
+	if !ok || devID != newDev.ID {
+		zap.L().Debug("设备配置文件已切换", zap.String("device", DeviceDisplayName(newDev)), zap.String("from", from), zap.String("to", to))
+		return
+	}
+	nodeID, _ := GetSinkIDByName(sink)
+
+	action := profileAction(from, to)
+	zap.L().Info("默认输出设备的配置文件已切换", zap.String("device", DeviceDisplayName(newDev)), zap.String("from", from), zap.String("to", to), zap.String("action", action))
+	switch action {
+	case "pause":
+		pauseForProfile(nodeID, sink, newDev)
+	case "resume":
+		id := addHistory(HistoryEntry{Trigger: profileTrigger, OldSink: sink, NewSink: sink, Device: DeviceDisplayName(newDev), Action: "resume"})
+		resumeAsync(id, nodeID, profileTrigger, newDev)
+	}
+}
+
+// pauseForProfile 暂停输出到该设备的播放器。切换到 HFP 往往正是因为开始了通话，
+// 因此不静音输出、不受 suppress_during_calls 限制，并跳过 call_apps 中的会议类应用
+func pauseForProfile(nodeID int, sink string, dev Device) {
+	entry := HistoryEntry{Trigger: profileTrigger, OldSink: sink, NewSink: sink, Device: DeviceDisplayName(dev), Action: "pause"}
+	switch {
+	case !sessionIsActive():
+		entry.Action = "跳过（会话不在前台）"
+	case isSnoozed():
+		entry.Action = "跳过（自动暂停已暂时停用）"
+	}
+	id := addHistory(entry)
+	if entry.Action != "pause" {
+		return
+	}
+
+	zap.L().Info("暂停播放器，触发事件为【" + profileTrigger + "】")
+	runHook(HookPause, newHookContext(nodeID, profileTrigger, dev))
+	routed := routedTo(nodeID)
+	go func() {
+		defer restoreOnPanic()
+		ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
+		defer cancel()
+
+		pausePlayers(ctx, id, func(ctx context.Context, playerName string) bool {
+			if p, ok := lookupPlayer(playerName); ok && isCallPlayer(p) {
+				return false
+			}
+			return routed == nil || routed(ctx, playerName)
+		})
+	}()
+}
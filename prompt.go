@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+const (
+	actionTreatPublic  = "treat-public"
+	actionTreatPrivate = "treat-private"
+	actionIgnore       = "ignore"
+)
+
+// DeviceChoices 为用户在通知中为无法归类的设备做出的选择，按 device.name 记录
+type DeviceChoices struct {
+	Public  []string `toml:"public"`
+	Private []string `toml:"private"`
+	Ignore  []string `toml:"ignore"`
+}
+
+var (
+	choicesMu sync.RWMutex
+	choices   DeviceChoices
+
+	promptMu sync.Mutex
+	// 启动后新接入、尚待判断是否需要询问的设备
+	newDevices = make(map[int]bool)
+	// 已发出的询问通知，值为设备的 device.name
+	prompts = make(map[uint32]string)
+)
+
+// DeviceChoicesPath 为保存设备分类选择的文件，与配置文件位于同一目录
+func DeviceChoicesPath() string {
+	path := ConfigPath()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "devices.toml")
+}
+
+func loadDeviceChoices() {
+	path := DeviceChoicesPath()
+	if path == "" {
+		return
+	}
+	var c DeviceChoices
+	if _, err := toml.DecodeFile(path, &c); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		zap.L().Warn("读取设备分类文件失败", zap.String("path", path), zap.Error(err))
+		return
+	}
+	choicesMu.Lock()
+	choices = c
+	choicesMu.Unlock()
+}
+
+func saveDeviceChoices(c DeviceChoices) error {
+	path := DeviceChoicesPath()
+	if path == "" {
+		return errors.New("无法确定配置目录")
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# 由 pw-autopaused 在用户为新设备选择分类后写入，按 device.name 匹配，可以手动编辑\n")
+	if err := toml.NewEncoder(&buf).Encode(c); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// deviceChoice 返回用户为设备选择的分类，ok 为 false 表示没有记录
+func deviceChoice(dev Device) (public, ok bool) {
+	name := dev.Info.Props.DeviceName
+	if name == "" {
+		return false, false
+	}
+	choicesMu.RLock()
+	defer choicesMu.RUnlock()
+	switch {
+	case slices.Contains(choices.Public, name):
+		return true, true
+	case slices.Contains(choices.Private, name):
+		return false, true
+	}
+	return false, false
+}
+
+func deviceChoiceRecorded(name string) bool {
+	choicesMu.RLock()
+	defer choicesMu.RUnlock()
+	return slices.Contains(choices.Public, name) || slices.Contains(choices.Private, name) || slices.Contains(choices.Ignore, name)
+}
+
+// noteNewDevice 记录启动后新接入的设备，需在写入缓存之前调用
+func noteNewDevice(dev Device) {
+	if !config.Classify.Prompt || sinkPolicy.Sink() == "" {
+		return
+	}
+	if _, exists := registry.Device(dev.ID); exists {
+		return
+	}
+	promptMu.Lock()
+	newDevices[dev.ID] = true
+	promptMu.Unlock()
+}
+
+// promptClassification 新接入的设备出现输出路由后，若路由无法归类则通过通知询问用户
+func promptClassification(dev Device) {
+	promptMu.Lock()
+	pending := newDevices[dev.ID]
+	promptMu.Unlock()
+	if !pending {
+		return
+	}
+	if _, ok := ActiveRoute(dev, "output"); !ok {
+		// 路由可能在随后的更新中才出现
+		return
+	}
+
+	promptMu.Lock()
+	delete(newDevices, dev.ID)
+	promptMu.Unlock()
+
+	name := dev.Info.Props.DeviceName
+	if name == "" || sinkClass(dev) != policy.Unclassified || deviceChoiceRecorded(name) {
+		return
+	}
+
+	zap.L().Info("新设备无法归类，询问用户", zap.String("device", name))
+	go func() {
+		id := sendNotification("无法识别的输出设备",
+			"无法判断 "+DeviceDisplayName(dev)+" 是耳机还是扬声器，请选择切换到该设备时的处理方式",
+			[]string{actionTreatPublic, "视为公共设备", actionTreatPrivate, "视为私有设备", actionIgnore, "忽略"})
+		if id == 0 {
+			return
+		}
+		promptMu.Lock()
+		prompts[id] = name
+		promptMu.Unlock()
+	}()
+}
+
+func forgetNewDevice(id int) {
+	promptMu.Lock()
+	delete(newDevices, id)
+	promptMu.Unlock()
+}
+
+// handlePromptAction 处理询问通知中的选择，返回 false 表示该通知不是分类询问
+func handlePromptAction(id uint32, action string) bool {
+	promptMu.Lock()
+	name, ok := prompts[id]
+	delete(prompts, id)
+	promptMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	choicesMu.Lock()
+	c := DeviceChoices{
+		Public:  slices.DeleteFunc(slices.Clone(choices.Public), func(s string) bool { return s == name }),
+		Private: slices.DeleteFunc(slices.Clone(choices.Private), func(s string) bool { return s == name }),
+		Ignore:  slices.DeleteFunc(slices.Clone(choices.Ignore), func(s string) bool { return s == name }),
+	}
+	switch action {
+	case actionTreatPublic:
+		c.Public = append(c.Public, name)
+	case actionTreatPrivate:
+		c.Private = append(c.Private, name)
+	case actionIgnore:
+		c.Ignore = append(c.Ignore, name)
+	default:
+		choicesMu.Unlock()
+		return true
+	}
+	choices = c
+	choicesMu.Unlock()
+
+	zap.L().Info("已记录设备分类", zap.String("device", name), zap.String("action", action))
+	if err := saveDeviceChoices(c); err != nil {
+		zap.L().Warn("写入设备分类文件失败", zap.String("path", DeviceChoicesPath()), zap.Error(err))
+	}
+	go notifyStateChanged()
+	return true
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pulse 后端将 pactl 报告的对象转换为 pw-dump 格式，对象索引按类型映射到互不重叠的区间
+const pulseIDStride = 100000
+
+const (
+	pulseSink = iota + 1
+	pulseSource
+	pulseSinkInput
+	pulseSourceOutput
+	pulseSinkDevice
+	pulseSourceDevice
+)
+
+const pulseMetadataID = 1
+
+func pulseID(kind, index int) int {
+	return kind*pulseIDStride + index
+}
+
+type pulsePort struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Type         string `json:"type"`
+	Priority     int    `json:"priority"`
+	Availability string `json:"availability"`
+}
+
+type pulseObject struct {
+	Index       int               `json:"index"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Mute        bool              `json:"mute"`
+	ChannelMap  string            `json:"channel_map"`
+	Properties  map[string]string `json:"properties"`
+	Ports       []pulsePort       `json:"ports"`
+	ActivePort  string            `json:"active_port"`
+	Volume      map[string]struct {
+		Value float64 `json:"value"`
+	} `json:"volume"`
+}
+
+type pulseInfo struct {
+	DefaultSink   string `json:"default_sink_name"`
+	DefaultSource string `json:"default_source_name"`
+}
+
+// channelVolumes 按声道顺序返回线性音量（PulseAudio 音量为立方刻度）
+func (o pulseObject) channelVolumes() []float64 {
+	var volumes []float64
+	for _, ch := range strings.Split(o.ChannelMap, ",") {
+		if v, ok := o.Volume[ch]; ok {
+			volumes = append(volumes, math.Pow(v.Value/65536, 3))
+		}
+	}
+	return volumes
+}
+
+func pactlJSON(ctx context.Context, v any, args ...string) error {
+	out, err := exec.CommandContext(ctx, "pactl", append([]string{"--format=json"}, args...)...).Output()
+	if err != nil {
+		return fmt.Errorf("pactl %s: %w", strings.Join(args, " "), err)
+	}
+	return json.Unmarshal(out, v)
+}
+
+func pulseRoute(index int, port pulsePort, direction string) map[string]any {
+	available := "unknown"
+	switch port.Availability {
+	case "available":
+		available = "yes"
+	case "not available":
+		available = "no"
+	}
+	return map[string]any{
+		"index":       index,
+		"name":        port.Name,
+		"description": port.Description,
+		"direction":   direction,
+		"priority":    port.Priority,
+		"available":   available,
+		"info":        []any{1, "port.type", strings.ToLower(port.Type)},
+	}
+}
+
+// pulseDevice 为每个 sink/source 构造一个设备对象，以其端口作为路由
+func pulseDevice(id int, o pulseObject, direction string) map[string]any {
+	routes := []map[string]any{}
+	enum := []map[string]any{}
+	for i, port := range o.Ports {
+		r := pulseRoute(i, port, direction)
+		enum = append(enum, r)
+		if port.Name == o.ActivePort {
+			routes = append(routes, r)
+		}
+	}
+
+	props := map[string]any{
+		"device.name":        o.Name,
+		"device.description": o.Description,
+	}
+	if addr := o.Properties["api.bluez5.address"]; addr != "" {
+		props["api.bluez5.address"] = addr
+	} else if o.Properties["device.bus"] == "bluetooth" {
+		props["api.bluez5.address"] = o.Properties["device.string"]
+	}
+
+	return map[string]any{
+		"id":   id,
+		"type": "PipeWire:Interface:Device",
+		"info": map[string]any{
+			"props":  props,
+			"params": map[string]any{"Route": routes, "EnumRoute": enum},
+		},
+	}
+}
+
+func pulseNode(id int, o pulseObject, name, mediaClass string, deviceID int) map[string]any {
+	props := map[string]any{
+		"node.name":   name,
+		"media.class": mediaClass,
+	}
+	if deviceID != 0 {
+		props["device.id"] = deviceID
+	}
+	for _, key := range []string{"application.name", "application.process.binary", "application.process.id", "media.role"} {
+		if v := o.Properties[key]; v != "" {
+			props[key] = v
+		}
+	}
+
+	return map[string]any{
+		"id":   id,
+		"type": "PipeWire:Interface:Node",
+		"info": map[string]any{
+			"props": props,
+			"params": map[string]any{
+				"Props": []map[string]any{{"mute": o.Mute, "channelVolumes": o.channelVolumes()}},
+			},
+		},
+	}
+}
+
+type pulseBackend struct {
+	seen map[int][]byte
+}
+
+// snapshot 查询当前的 sink、source、流与默认设备，按设备、节点、元数据的顺序返回
+func (b *pulseBackend) snapshot(ctx context.Context) (map[int][]byte, []int, error) {
+	var (
+		sinks, sources, inputs, outputs []pulseObject
+		info                            pulseInfo
+	)
+	for _, q := range []struct {
+		v    any
+		args []string
+	}{
+		{&sinks, []string{"list", "sinks"}},
+		{&sources, []string{"list", "sources"}},
+		{&inputs, []string{"list", "sink-inputs"}},
+		{&outputs, []string{"list", "source-outputs"}},
+		{&info, []string{"info"}},
+	} {
+		if err := pactlJSON(ctx, q.v, q.args...); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	objects := make(map[int]any)
+	for _, o := range sinks {
+		devID := pulseID(pulseSinkDevice, o.Index)
+		objects[devID] = pulseDevice(devID, o, "Output")
+		objects[pulseID(pulseSink, o.Index)] = pulseNode(pulseID(pulseSink, o.Index), o, o.Name, "Audio/Sink", devID)
+	}
+	for _, o := range sources {
+		if o.Properties["device.class"] == "monitor" {
+			continue
+		}
+		devID := pulseID(pulseSourceDevice, o.Index)
+		objects[devID] = pulseDevice(devID, o, "Input")
+		objects[pulseID(pulseSource, o.Index)] = pulseNode(pulseID(pulseSource, o.Index), o, o.Name, "Audio/Source", devID)
+	}
+	for _, o := range inputs {
+		id := pulseID(pulseSinkInput, o.Index)
+		objects[id] = pulseNode(id, o, fmt.Sprintf("sink-input.%d", o.Index), "Stream/Output/Audio", 0)
+	}
+	for _, o := range outputs {
+		id := pulseID(pulseSourceOutput, o.Index)
+		objects[id] = pulseNode(id, o, fmt.Sprintf("source-output.%d", o.Index), "Stream/Input/Audio", 0)
+	}
+	metadata := []map[string]any{}
+	for key, name := range map[string]string{"default.audio.sink": info.DefaultSink, "default.audio.source": info.DefaultSource} {
+		if name != "" {
+			metadata = append(metadata, map[string]any{"subject": 0, "key": key, "type": "Spa:String:JSON", "value": map[string]any{"name": name}})
+		}
+	}
+	sort.Slice(metadata, func(i, j int) bool { return metadata[i]["key"].(string) < metadata[j]["key"].(string) })
+	objects[pulseMetadataID] = map[string]any{
+		"id":       pulseMetadataID,
+		"type":     "PipeWire:Interface:Metadata",
+		"props":    map[string]any{"metadata.name": "default"},
+		"metadata": metadata,
+	}
+
+	raws := make(map[int][]byte, len(objects))
+	ids := make([]int, 0, len(objects))
+	for id, obj := range objects {
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		raws[id] = raw
+		ids = append(ids, id)
+	}
+	// 设备先于节点，元数据最后，与 pw-dump 的初始输出顺序一致
+	order := func(id int) int {
+		switch id / pulseIDStride {
+		case pulseSinkDevice, pulseSourceDevice:
+			return 0
+		case 0:
+			return 2
+		}
+		return 1
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if oi, oj := order(ids[i]), order(ids[j]); oi != oj {
+			return oi < oj
+		}
+		return ids[i] < ids[j]
+	})
+	return raws, ids, nil
+}
+
+// sync 重新获取快照，仅将发生变化或已移除的对象送入 dispatcher
+func (b *pulseBackend) sync(ctx context.Context) error {
+	raws, ids, err := b.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	var batch []json.RawMessage
+	for _, id := range ids {
+		if !bytes.Equal(b.seen[id], raws[id]) {
+			batch = append(batch, raws[id])
+		}
+	}
+	for id := range b.seen {
+		if _, ok := raws[id]; !ok {
+			batch = append(batch, json.RawMessage(fmt.Sprintf(`{"id":%d,"info":null}`, id)))
+		}
+	}
+	b.seen = raws
+
+	if len(batch) == 0 {
+		return nil
+	}
+	if recordFile != nil {
+		if err := json.NewEncoder(recordFile).Encode(batch); err != nil {
+			zap.L().Warn("写入录制文件失败", zap.Error(err))
+		}
+	}
+	dispatcher(batch)
+	return nil
+}
+
+func (b *pulseBackend) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	zap.L().Info("正在启动 pactl 监听进程...")
+
+	cmd := exec.CommandContext(ctx, "pactl", "subscribe")
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 2 * time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		zap.L().Error("无法启动 pactl 监听进程", zap.Error(err))
+		return err
+	}
+	defer cmd.Wait()
+
+	controllerMu.Lock()
+	controller = pactlController{}
+	controllerMu.Unlock()
+	defer func() {
+		controllerMu.Lock()
+		controller = nil
+		controllerMu.Unlock()
+	}()
+
+	b.seen = make(map[int][]byte)
+	if err := b.sync(ctx); err != nil {
+		zap.L().Error("无法获取 PulseAudio 对象", zap.Error(err))
+		return err
+	}
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			zap.L().Debug("pactl 事件", zap.String("event", scanner.Text()))
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+		cancel()
+	}()
+
+	zap.L().Info("正在监听事件...")
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+		// 合并短时间内连续到达的事件
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-changed:
+		default:
+		}
+		if err := b.sync(ctx); err != nil && ctx.Err() == nil {
+			zap.L().Warn("同步 PulseAudio 对象失败", zap.Error(err))
+		}
+	}
+}
+
+type pactlController struct{}
+
+func (pactlController) SetNodeProps(nodeID int, values map[string]any) error {
+	kinds := map[int]string{
+		pulseSink:         "sink",
+		pulseSource:       "source",
+		pulseSinkInput:    "sink-input",
+		pulseSourceOutput: "source-output",
+	}
+	kind, ok := kinds[nodeID/pulseIDStride]
+	if !ok {
+		return fmt.Errorf("未知的节点：%d", nodeID)
+	}
+	index := fmt.Sprint(nodeID % pulseIDStride)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for key, v := range values {
+		var args []string
+		switch key {
+		case "mute":
+			mute := "0"
+			if b, _ := v.(bool); b {
+				mute = "1"
+			}
+			args = []string{"set-" + kind + "-mute", index, mute}
+		case "channelVolumes":
+			volumes, _ := v.([]float64)
+			args = []string{"set-" + kind + "-volume", index}
+			for _, vol := range volumes {
+				// 带小数点的数值被 pactl 视为线性系数
+				args = append(args, fmt.Sprintf("%.6f", vol))
+			}
+		default:
+			continue
+		}
+		if out, err := exec.CommandContext(ctx, "pactl", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("pactl %s: %w: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// pwCliAckTimeout 为等待 pw-cli 确认指令的最长时间
+	pwCliAckTimeout = time.Second
+	ackPrefix       = "pw-autopaused-ack-"
+)
+
+var errAckTimeout = errors.New("等待 pw-cli 确认超时")
+
+// pwCliFailures 统计 pw-cli 报告失败或未确认的指令数
+var pwCliFailures atomic.Uint64
+
+// commandError 为 pw-cli 在处理指令时报告的错误，重试同一指令不会成功
+type commandError struct {
+	command string
+	output  []string
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("pw-cli 拒绝了指令 %q：%s", e.command, strings.Join(e.output, "；"))
+}
+
+// pwCli 通过常驻的 pw-cli 进程写入节点参数。每条指令后附带一条不存在的命令，
+// pw-cli 按顺序处理输入，在标准错误中报告该命令不存在即表示之前的指令已处理完毕
+type pwCli struct {
+	ctx context.Context
+	// exited 在进程意外退出（而不是被 Restart 替换）时调用
+	exited func(error)
+
+	mu   sync.Mutex
+	proc *pwCliProc
+	seq  int
+	// 最近一次发出的指令，用于关联在确认之后才到达的错误
+	last string
+}
+
+type pwCliProc struct {
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	lines    chan string
+	replaced atomic.Bool
+}
+
+func startPwCli(ctx context.Context, exited func(error)) (*pwCli, error) {
+	c := &pwCli{ctx: ctx, exited: exited}
+	proc, err := c.spawn()
+	if err != nil {
+		return nil, err
+	}
+	c.proc = proc
+	return c, nil
+}
+
+func (c *pwCli) spawn() (*pwCliProc, error) {
+	cmd := exec.CommandContext(c.ctx, "pw-cli")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	// 退出时先关闭输入让 pw-cli 处理完剩余指令后自行退出，超时再强制结束
+	cmd.Cancel = stdin.Close
+	cmd.WaitDelay = 2 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	proc := &pwCliProc{cmd: cmd, stdin: stdin, lines: make(chan string, 64)}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			zap.L().Debug("pw-cli 输出", zap.String("line", scanner.Text()))
+		}
+	}()
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			select {
+			case proc.lines <- scanner.Text():
+			default:
+				// 无人等待确认时丢弃，避免 pw-cli 阻塞在输出上
+				zap.L().Warn("pw-cli 输出了未对应指令的错误", zap.String("line", scanner.Text()))
+			}
+		}
+	}()
+	go func() {
+		err := cmd.Wait()
+		if !proc.replaced.Load() && c.exited != nil {
+			c.exited(err)
+		}
+	}()
+	return proc, nil
+}
+
+func (c *pwCli) SetNodeProps(nodeID int, values map[string]any) error {
+	var fields []string
+	for k, v := range values {
+		switch v := v.(type) {
+		case []float64:
+			fields = append(fields, fmt.Sprintf("%s: %s", k, formatVolumes(v)))
+		default:
+			fields = append(fields, fmt.Sprintf("%s: %v", k, v))
+		}
+	}
+	return c.run(fmt.Sprintf("set-param %d Props { %s }", nodeID, strings.Join(fields, ", ")))
+}
+
+func (c *pwCli) run(command string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	proc := c.proc
+	// 上一条指令确认之后才到达的输出（如 PipeWire 异步返回的错误）
+	for len(proc.lines) > 0 {
+		line := <-proc.lines
+		if strings.Contains(line, ackPrefix) {
+			// 之前超时的指令迟到的确认
+			continue
+		}
+		pwCliFailures.Add(1)
+		zap.L().Warn("pw-cli 报告了之前指令的错误", zap.String("command", c.last), zap.String("line", line))
+	}
+
+	c.seq++
+	c.last = command
+	ack := fmt.Sprintf("%s%d", ackPrefix, c.seq)
+	if _, err := io.WriteString(proc.stdin, command+"\n"+ack+"\n"); err != nil {
+		pwCliFailures.Add(1)
+		return err
+	}
+
+	// 确认之前的输出都属于本条指令
+	var output []string
+	timer := time.NewTimer(pwCliAckTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line := <-proc.lines:
+			if !strings.Contains(line, ack) {
+				if !strings.Contains(line, ackPrefix) {
+					output = append(output, line)
+				}
+				continue
+			}
+			if len(output) > 0 {
+				pwCliFailures.Add(1)
+				return &commandError{command: command, output: output}
+			}
+			return nil
+		case <-timer.C:
+			pwCliFailures.Add(1)
+			return errAckTimeout
+		}
+	}
+}
+
+// Restart 结束当前的 pw-cli 进程并启动新的进程，用于输入管道损坏或进程无响应时
+func (c *pwCli) Restart() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	old := c.proc
+	old.replaced.Store(true)
+	old.stdin.Close()
+	old.cmd.Process.Kill()
+
+	proc, err := c.spawn()
+	if err != nil {
+		// 无法重新启动时交由后端重新连接
+		if c.exited != nil {
+			go c.exited(err)
+		}
+		return err
+	}
+	c.proc = proc
+	zap.L().Info("已重新启动控制进程")
+	return nil
+}
+
+// Close 关闭输入，让 pw-cli 处理完剩余指令后退出
+func (c *pwCli) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.proc.stdin.Close()
+}
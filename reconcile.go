@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/nsplup/pw-autopaused/pkg/pwmon"
+	"go.uber.org/zap"
+)
+
+// snapshot 为独立的 pw-dump 进程输出的完整快照，taken 为开始获取的时间
+type snapshot struct {
+	taken  time.Time
+	events []pwmon.Event
+}
+
+// reconcileCaches 以快照校正缓存，需要在处理监听事件的协程中调用；
+// 快照中已不存在的对象与监听到的移除事件一样处理
+func reconcileCaches(s snapshot) {
+	nodes := make(map[int]Node)
+	devices := make(map[int]Device)
+	links := make(map[int]pwmon.Link)
+	clients := make(map[int]pwmon.Client)
+	for _, ev := range s.events {
+		switch ev.Type {
+		case pwmon.NodeChanged:
+			nodes[ev.ID] = ev.Node
+		case pwmon.DeviceChanged:
+			devices[ev.ID] = ev.Device
+		case pwmon.LinkChanged:
+			links[ev.ID] = ev.Link
+		case pwmon.ClientChanged:
+			clients[ev.ID] = ev.Client
+		}
+	}
+
+	var removed []pwmon.Event
+	for _, id := range registry.Sync(s.taken, nodes, devices, links, clients) {
+		zap.L().Debug("快照中已不存在的对象，按移除处理", zap.Int("id", id))
+		removed = append(removed, pwmon.Event{Type: pwmon.Removed, ID: id})
+	}
+	if len(removed) > 0 {
+		handleEvents(removed)
+	}
+}
+
+// StartReconciler 定期获取完整快照并送入返回的通道，由处理监听事件的协程调用 reconcileCaches；
+// interval 不大于 0 时返回 nil
+func StartReconciler(ctx context.Context, interval time.Duration) <-chan snapshot {
+	if interval <= 0 {
+		return nil
+	}
+
+	out := make(chan snapshot)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			taken := time.Now()
+			rawObjects, err := currentDumpCompat().Snapshot(ctx)
+			if err != nil {
+				zap.L().Warn("获取完整快照失败", zap.Error(err))
+				continue
+			}
+			select {
+			case out <- snapshot{taken: taken, events: pwmon.Decode(rawObjects)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,22 @@
+package main
+
+// forgetObject 清理以已移除对象的索引为键的状态，避免索引被新对象复用时误用旧状态
+func forgetObject(id int) {
+	mutedMu.Lock()
+	delete(mutedNodes, id)
+	mutedMu.Unlock()
+
+	duckedMu.Lock()
+	delete(duckedNodes, id)
+	duckedMu.Unlock()
+
+	streamMovesMu.Lock()
+	delete(streamMoves, id)
+	streamMovesMu.Unlock()
+
+	hotplugMu.Lock()
+	delete(hotplugged, id)
+	hotplugMu.Unlock()
+
+	forgetNewDevice(id)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// runReplay 将录制的 pw-dump --monitor 输出逐批送入 dispatcher，用于复现用户报告的设备相关问题
+func runReplay(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zap.L().Info("正在回放事件...", zap.String("path", path))
+	decoder := json.NewDecoder(f)
+	batches := 0
+	for {
+		var rawObjects []json.RawMessage
+		if err := decoder.Decode(&rawObjects); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		dispatcher(rawObjects)
+		batches++
+		// 设备切换在合并窗口内处理，逐批回放时立即提交，使日志顺序与事件顺序一致
+		flushTransition()
+	}
+
+	// 等待异步的暂停流程记录完毕
+	time.Sleep(config.UnmuteDelay + 100*time.Millisecond)
+	zap.L().Info("回放完成", zap.Int("batches", batches))
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/nsplup/pw-autopaused/pkg/mpris"
+	"go.uber.org/zap"
+)
+
+var (
+	// pausedSince 记录待恢复列表中每个播放器被暂停的时间，受 pausedMu 保护；
+	// 每个播放器按各自的暂停时间判断是否超过恢复窗口
+	pausedSince = make(map[string]time.Time)
+	// pausedTracks 记录支持 Position 的播放器暂停时的曲目与位置，受 pausedMu 保护
+	pausedTracks = make(map[string]mpris.Track)
+)
+
+// resumeExpired 判断在 at 暂停的播放器是否已超过恢复窗口，此时再自动恢复播放会让用户感到意外
+func resumeExpired(at, now time.Time) bool {
+	return config.Resume.Window > 0 && now.Sub(at) > config.Resume.Window
+}
+
+// notePausedLocked 将本次暂停的播放器合并到待恢复列表，已超过恢复窗口的旧记录在此时丢弃；调用方需持有 pausedMu
+func notePausedLocked(players []string, tracks map[string]mpris.Track, now time.Time) {
+	kept := pausedPlayers[:0:0]
+	for _, p := range pausedPlayers {
+		if !slices.Contains(players, p) && !resumeExpired(pausedSince[p], now) {
+			kept = append(kept, p)
+		}
+	}
+	for p := range pausedSince {
+		if !slices.Contains(kept, p) {
+			delete(pausedSince, p)
+			delete(pausedTracks, p)
+		}
+	}
+	for _, p := range players {
+		pausedSince[p] = now
+		if t, ok := tracks[p]; ok {
+			pausedTracks[p] = t
+		}
+	}
+	pausedPlayers = append(kept, players...)
+	pausedAt = now
+}
+
+// takePausedLocked 从待恢复列表中取出 only 中的播放器（only 为 nil 时取出全部），返回仍在恢复窗口内的播放器、
+// 已过期的播放器与暂停时的曲目；force 时全部视为可以恢复。调用方需持有 pausedMu
+func takePausedLocked(force bool, only []string, now time.Time) (fresh, expired []string, tracks map[string]mpris.Track) {
+	tracks = make(map[string]mpris.Track)
+	kept := pausedPlayers[:0:0]
+	for _, p := range pausedPlayers {
+		if only != nil && !slices.Contains(only, p) {
+			kept = append(kept, p)
+			continue
+		}
+		if !force && resumeExpired(pausedSince[p], now) {
+			expired = append(expired, p)
+		} else {
+			fresh = append(fresh, p)
+		}
+		if t, ok := pausedTracks[p]; ok {
+			tracks[p] = t
+		}
+		delete(pausedSince, p)
+		delete(pausedTracks, p)
+	}
+	pausedPlayers = kept
+	if len(kept) == 0 {
+		pausedPlayers = nil
+	}
+	return fresh, expired, tracks
+}
+
+// pausedTrack 返回待恢复的播放器暂停时的曲目与位置
+func pausedTrack(name string) (mpris.Track, bool) {
+	pausedMu.Lock()
+	defer pausedMu.Unlock()
+	t, ok := pausedTracks[name]
+	return t, ok
+}
+
+// trackSummary 返回形如「《曲目》 1:23」的曲目与位置，用于状态查询与事件记录
+func trackSummary(t mpris.Track) string {
+	position := t.Position.Truncate(time.Second)
+	pos := fmt.Sprintf("%d:%02d", int(position/time.Minute), int(position%time.Minute/time.Second))
+	if t.Title == "" {
+		return "位置 " + pos
+	}
+	return "《" + t.Title + "》 " + pos
+}
+
+// rewindPlayer 在恢复播放前将位置回退 resume.rewind，只在播放器仍停留在暂停时的曲目上时回退
+func rewindPlayer(ctx context.Context, conn *dbus.Conn, name string, paused mpris.Track) {
+	if config.Resume.Rewind <= 0 || !paused.CanSeek || paused.ID == "" {
+		return
+	}
+	cur, err := mpris.CurrentTrack(ctx, conn, name)
+	if err != nil || cur.ID != paused.ID {
+		zap.L().Debug("曲目已变化，恢复时不回退播放位置", zap.String("player", name))
+		return
+	}
+	position := max(cur.Position-config.Resume.Rewind, 0)
+	if err := mpris.SetPosition(ctx, conn, name, cur.ID, position); err != nil {
+		zap.L().Warn("回退播放位置失败", zap.String("player", name), zap.Error(err))
+		return
+	}
+	zap.L().Debug("已回退播放位置", zap.String("player", name), zap.Duration("position", position))
+}
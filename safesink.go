@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	safeSinkMu     sync.Mutex
+	configuredSink string
+)
+
+// noteConfiguredSink 记录用户最近一次选择的默认输出设备，供切回时恢复
+func noteConfiguredSink(name string) {
+	if name == config.SafeSink.Name || gateEnabled() && name == config.Gate.Name {
+		return
+	}
+	safeSinkMu.Lock()
+	configuredSink = name
+	safeSinkMu.Unlock()
+}
+
+// ensureSafeSink 确保安全输出（null sink）节点存在，不存在时通过 pw-cli 创建
+func ensureSafeSink(ctx context.Context) error {
+	name := config.SafeSink.Name
+	if _, ok := GetSinkIDByName(name); ok {
+		return nil
+	}
+
+	props := fmt.Sprintf("{ factory.name=support.null-audio-sink node.name=%q node.description=%q media.class=Audio/Sink object.linger=true audio.position=[ FL FR ] }",
+		name, "pw-autopaused 安全输出")
+	if out, err := exec.CommandContext(ctx, "pw-cli", "create-node", "adapter", props).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	zap.L().Info("已创建安全输出节点", zap.String("name", name))
+
+	// 等待节点出现在缓存中
+	for {
+		if _, ok := GetSinkIDByName(name); ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// setConfiguredSink 通过 pw-metadata 写入 default.configured.audio.sink，name 为空时删除该键
+func setConfiguredSink(ctx context.Context, name string) error {
+	args := []string{"-n", "default", "0", "default.configured.audio.sink", fmt.Sprintf(`{ "name": %q }`, name), "Spa:String:JSON"}
+	if name == "" {
+		args = []string{"-n", "default", "-d", "0", "default.configured.audio.sink"}
+	}
+	if dryRun {
+		zap.L().Info("[dry-run] 将设置默认输出设备", zap.String("sink", name))
+		return nil
+	}
+	if out, err := exec.CommandContext(ctx, "pw-metadata", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
+
+// pauseWithSafeSink 先将默认输出切换到安全输出节点使声音不可闻，再暂停播放器，最后按配置切回原设备
+func pauseWithSafeSink(entry, nodeID int, reason string, dev Device) {
+	defer restoreOnPanic()
+	if isSnoozed() {
+		zap.L().Info("自动暂停已暂时停用，跳过本次暂停", zap.String("reason", reason))
+		return
+	}
+	if suppressedByCall(reason) || suppressedByScreencast(reason) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
+	defer cancel()
+
+	if !dryRun {
+		if err := ensureSafeSink(ctx); err != nil {
+			zap.L().Warn("无法创建安全输出节点，改为静音后暂停", zap.Error(err))
+			pauseWithMute(entry, nodeID, reason, dev)
+			return
+		}
+	}
+	if err := setConfiguredSink(ctx, config.SafeSink.Name); err != nil {
+		zap.L().Warn("无法切换到安全输出节点，改为静音后暂停", zap.Error(err))
+		pauseWithMute(entry, nodeID, reason, dev)
+		return
+	}
+
+	safeSinkMu.Lock()
+	previous := configuredSink
+	safeSinkMu.Unlock()
+
+	notifyPaused(reason, dev)
+	runHook(HookPause, newHookContext(nodeID, reason, dev))
+	paused := pausePlayers(ctx, entry, nil)
+
+	if !config.SafeSink.Restore {
+		return
+	}
+	time.Sleep(config.UnmuteDelay)
+	zap.L().Info("从安全输出节点切回", zap.String("sink", previous))
+	if err := setConfiguredSink(context.Background(), previous); err != nil {
+		zap.L().Warn("无法从安全输出节点切回", zap.Error(err))
+		return
+	}
+	if len(paused) > 0 {
+		playEarcon(nodeID)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func parseClock(s string) (time.Duration, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, true
+}
+
+func matchDay(days []string, wd time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if strings.EqualFold(d, weekdayNames[wd]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e ScheduleEntry) active(now time.Time) bool {
+	start, ok1 := parseClock(e.Start)
+	end, ok2 := parseClock(e.End)
+	if !ok1 || !ok2 {
+		return false
+	}
+	clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+
+	if start <= end {
+		return matchDay(e.Days, now.Weekday()) && clock >= start && clock < end
+	}
+	// 跨越午夜的时段，凌晨部分属于前一天
+	if clock >= start {
+		return matchDay(e.Days, now.Weekday())
+	}
+	return clock < end && matchDay(e.Days, now.AddDate(0, 0, -1).Weekday())
+}
+
+// scheduleMode 返回当前时段的策略：strict（切换到任何公共设备都会暂停）、normal 或 off
+func scheduleMode(now time.Time) string {
+	for _, e := range config.Schedule {
+		if e.active(now) {
+			return e.Mode
+		}
+	}
+	if config.ScheduleDefault == "" {
+		return "normal"
+	}
+	return config.ScheduleDefault
+}
+
+func StartScheduler() {
+	if len(config.Schedule) == 0 {
+		return
+	}
+
+	go func() {
+		last := scheduleMode(time.Now())
+		zap.L().Info("当前时段策略", zap.String("mode", last))
+
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			if mode := scheduleMode(time.Now()); mode != last {
+				zap.L().Info("时段策略已切换", zap.String("from", last), zap.String("to", mode))
+				addHistory(HistoryEntry{Trigger: "时段策略切换", Action: mode})
+				last = mode
+			}
+		}
+	}()
+}
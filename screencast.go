@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+const (
+	screencastStartTrigger = "开始屏幕共享"
+	screencastStopTrigger  = "结束屏幕共享"
+)
+
+var (
+	screencasting atomic.Bool
+
+	screencastMu sync.Mutex
+	// screencastPaused 为因本次屏幕共享而暂停的播放器，结束共享时只恢复这些播放器
+	screencastPaused []string
+)
+
+// screencastStreams 返回正在捕获输出设备监视器的录音流：屏幕共享与录屏（浏览器共享标签页音频、OBS、
+// 通过录屏门户发起的会话等）在共享画面的同时通常会录制输出的声音
+func screencastStreams() []Node {
+	var streams []Node
+	for _, node := range registry.Nodes() {
+		props := node.Info.Props
+		if props.MediaClass != "Stream/Input/Audio" {
+			continue
+		}
+		if props.CaptureSink.String() == "true" || isMonitorTarget(props.TargetObject.String()) || isMonitorTarget(props.NodeTarget.String()) || capturesSink(node.ID) {
+			streams = append(streams, node)
+		}
+	}
+	return streams
+}
+
+// isMonitorTarget 判断录音流指定的目标是否为输出设备（或其 .monitor 源）
+func isMonitorTarget(target string) bool {
+	if target == "" {
+		return false
+	}
+	if strings.HasSuffix(target, ".monitor") {
+		return true
+	}
+	_, ok := registry.NodeIDByName(target, config.SinkMediaClasses...)
+	return ok
+}
+
+// capturesSink 判断录音流的输入端口是否直接连接到输出设备的监视器端口
+func capturesSink(id int) bool {
+	for _, peer := range registry.LinkedNodes(id, false) {
+		if node, ok := registry.Node(peer); ok && slices.Contains(config.SinkMediaClasses, node.Info.Props.MediaClass) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkScreencast 在节点或连接变化后更新屏幕共享状态，[screencast] policy 为 pause 时在开始共享时暂停播放器、结束后恢复
+func checkScreencast() {
+	streams := screencastStreams()
+	active := len(streams) > 0
+	if screencasting.Swap(active) == active {
+		return
+	}
+
+	sink := sinkPolicy.Sink()
+	nodeID, _ := GetSinkIDByName(sink)
+	if !active {
+		zap.L().Info("屏幕共享已结束")
+		screencastMu.Lock()
+		players := screencastPaused
+		screencastPaused = nil
+		screencastMu.Unlock()
+		if config.Screencast.Policy == "pause" && len(players) > 0 {
+			id := addHistory(HistoryEntry{Trigger: screencastStopTrigger, OldSink: sink, NewSink: sink, Action: "resume"})
+			resumePlayersAsync(id, players, nodeID, screencastStopTrigger, Device{})
+		}
+		return
+	}
+
+	zap.L().Info("检测到屏幕共享或录制输出音频", zap.Strings("streams", streamNames(nodeIDs(streams))), zap.String("policy", config.Screencast.Policy))
+	if config.Screencast.Policy == "pause" {
+		pauseForScreencast(nodeID, sink)
+	}
+}
+
+func nodeIDs(nodes []Node) []int {
+	ids := make([]int, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	return ids
+}
+
+// pauseForScreencast 在开始屏幕共享时暂停输出到默认设备的播放器；不静音输出，以免共享的其他声音被一并静音
+func pauseForScreencast(nodeID int, sink string) {
+	entry := HistoryEntry{Trigger: screencastStartTrigger, OldSink: sink, NewSink: sink, Action: "pause"}
+	switch {
+	case !sessionIsActive():
+		entry.Action = "跳过（会话不在前台）"
+	case isSnoozed():
+		entry.Action = "跳过（自动暂停已暂时停用）"
+	}
+	id := addHistory(entry)
+	if entry.Action != "pause" {
+		return
+	}
+
+	zap.L().Info("暂停播放器，触发事件为【" + screencastStartTrigger + "】")
+	runHook(HookPause, newHookContext(nodeID, screencastStartTrigger, Device{}))
+	routed := routedTo(nodeID)
+	go func() {
+		defer restoreOnPanic()
+		ctx, cancel := context.WithTimeout(context.Background(), config.PauseTimeout)
+		defer cancel()
+
+		paused := pausePlayers(ctx, id, func(ctx context.Context, playerName string) bool {
+			if p, ok := lookupPlayer(playerName); ok && isCallPlayer(p) {
+				return false
+			}
+			return routed == nil || routed(ctx, playerName)
+		})
+		screencastMu.Lock()
+		screencastPaused = append(screencastPaused, paused...)
+		screencastMu.Unlock()
+	}()
+}
+
+// suppressedByScreencast 在 [screencast] policy 为 suppress 且正在共享屏幕时跳过自动暂停，避免演示中途打断播放
+func suppressedByScreencast(reason string) bool {
+	if config.Screencast.Policy != "suppress" || !screencasting.Load() {
+		return false
+	}
+	zap.L().Info("正在共享屏幕，跳过本次处理", zap.String("reason", reason))
+	return true
+}
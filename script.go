@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+// scriptDevice 为规则表达式中的 old 与 new
+type scriptDevice struct {
+	Sink     string `expr:"sink"`
+	Name     string `expr:"name"`
+	Class    string `expr:"class"`
+	PortType string `expr:"port_type"`
+}
+
+type scriptPlayer struct {
+	Name     string `expr:"name"`
+	Identity string `expr:"identity"`
+	Status   string `expr:"status"`
+}
+
+// scriptEnv 为 [script] expr 可以访问的事件信息
+type scriptEnv struct {
+	Trigger string         `expr:"trigger"`
+	Reason  string         `expr:"reason"`
+	Old     scriptDevice   `expr:"old"`
+	New     scriptDevice   `expr:"new"`
+	Action  string         `expr:"action"`
+	User    bool           `expr:"user"`
+	Now     time.Time      `expr:"now"`
+	Hour    int            `expr:"hour"`
+	Weekday string         `expr:"weekday"`
+	Players []scriptPlayer `expr:"players"`
+}
+
+// scriptActions 为规则表达式可以返回的动作，空字符串或 default 表示沿用内置策略的结果
+var scriptActions = []string{"", "default", "pause", "duck", "mute-only", "safe-sink", "resume", "ignore"}
+
+var scriptProgram atomic.Pointer[vm.Program]
+
+// compileScript 在加载配置时编译 [script] expr，返回值必须为字符串
+func compileScript(c Config) (*vm.Program, error) {
+	if c.Script.Expr == "" {
+		return nil, nil
+	}
+	program, err := expr.Compile(c.Script.Expr, expr.Env(scriptEnv{}), expr.AsKind(reflect.String))
+	if err != nil {
+		return nil, fmt.Errorf("script.expr 无效：%w", err)
+	}
+	return program, nil
+}
+
+func newScriptDevice(sink string, class policy.Class) scriptDevice {
+	d := scriptDevice{Sink: sink, Class: class.String()}
+	if sink == "" {
+		return d
+	}
+	dev, _ := classifyNode(sink, GetSinkIDByName, sinkClass)
+	if dev.ID != 0 {
+		d.Name = DeviceDisplayName(dev)
+		if route, ok := GetHighestPriorityOutputRoute(dev); ok {
+			d.PortType = route.PortType()
+		}
+	}
+	return d
+}
+
+// scriptAction 以设备切换事件运行规则表达式，返回其给出的动作；没有配置表达式、表达式出错或返回 default 时 ok 为 false
+func scriptAction(t transition, action policy.Action) (string, bool) {
+	program := scriptProgram.Load()
+	// 初始化默认设备时没有可比较的旧设备
+	if program == nil || t.oldSink == "" {
+		return "", false
+	}
+
+	now := time.Now()
+	env := scriptEnv{
+		Trigger: triggerID(t.reason),
+		Reason:  t.reason,
+		Old:     newScriptDevice(t.oldSink, t.from),
+		New:     newScriptDevice(t.newSink, t.to),
+		Action:  action.String(),
+		User:    t.user,
+		Now:     now,
+		Hour:    now.Hour(),
+		Weekday: weekdayNames[now.Weekday()],
+	}
+	for _, name := range playerNames() {
+		p, _ := lookupPlayer(name)
+		env.Players = append(env.Players, scriptPlayer{Name: p.ShortName(), Identity: p.Identity, Status: p.PlaybackStatus})
+	}
+
+	out, err := expr.Run(program, env)
+	if err != nil {
+		zap.L().Warn("规则表达式执行失败，按内置策略处理", zap.String("reason", t.reason), zap.Error(err))
+		return "", false
+	}
+	result, _ := out.(string)
+	switch result {
+	case "", "default":
+		return "", false
+	}
+	if !slices.Contains(scriptActions, result) {
+		zap.L().Warn("规则表达式返回了无效的动作，按内置策略处理", zap.String("action", result))
+		return "", false
+	}
+	zap.L().Info("规则表达式给出动作", zap.String("reason", t.reason), zap.String("builtin", action.String()), zap.String("action", result))
+	return result, true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var readyOnce sync.Once
+
+func sdNotify(state string) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		zap.L().Debug("无法连接 systemd 通知套接字", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		zap.L().Debug("向 systemd 发送状态失败", zap.String("state", state), zap.Error(err))
+	}
+}
+
+func markReady() {
+	readyOnce.Do(func() {
+		zap.L().Info("初始状态同步完成")
+		ready.Store(true)
+		sdNotify("READY=1\nSTATUS=正在监听事件")
+	})
+}
+
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+func StartWatchdog() {
+	interval := watchdogInterval()
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sdNotify("WATCHDOG=1")
+		}
+	}()
+}
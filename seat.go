@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+// sessionInactive 为 true 时当前登录会话不在前台（例如切换到了其他用户），此时不做任何处理
+var sessionInactive atomic.Bool
+
+func sessionIsActive() bool {
+	return !sessionInactive.Load()
+}
+
+// ownSessionPath 查找守护进程所属的登录会话；作为 systemd 用户服务运行时不属于任何会话，
+// 此时使用该用户的图形会话（User.Display）
+func ownSessionPath(conn *dbus.Conn) (dbus.ObjectPath, error) {
+	manager := conn.Object(login1Name, login1Path)
+
+	var path dbus.ObjectPath
+	if id := os.Getenv("XDG_SESSION_ID"); id != "" {
+		if err := manager.Call(login1Manager+".GetSession", 0, id).Store(&path); err == nil {
+			return path, nil
+		}
+	}
+	if err := manager.Call(login1Manager+".GetSessionByPID", 0, uint32(os.Getpid())).Store(&path); err == nil {
+		return path, nil
+	}
+
+	var user dbus.ObjectPath
+	if err := manager.Call(login1Manager+".GetUser", 0, uint32(os.Getuid())).Store(&user); err != nil {
+		return "", err
+	}
+	v, err := conn.Object(login1Name, user).GetProperty("org.freedesktop.login1.User.Display")
+	if err != nil {
+		return "", err
+	}
+	display, ok := v.Value().([]interface{})
+	if !ok || len(display) < 2 {
+		return "", fmt.Errorf("无法解析 Display 属性")
+	}
+	path, _ = display[1].(dbus.ObjectPath)
+	if path == "" || path == "/" {
+		return "", fmt.Errorf("用户没有图形会话")
+	}
+	return path, nil
+}
+
+// checkRuntimeDir 确认运行时目录属于当前用户，避免多用户环境下连接到其他用户的 PipeWire 实例
+func checkRuntimeDir() {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		zap.L().Warn("未设置 XDG_RUNTIME_DIR，可能无法连接到当前用户的 PipeWire 实例")
+		return
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		zap.L().Warn("无法访问运行时目录", zap.String("dir", dir), zap.Error(err))
+		return
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		zap.L().Warn("运行时目录不属于当前用户，可能会操作其他用户的音频会话", zap.String("dir", dir), zap.Uint32("owner", st.Uid))
+	}
+}
+
+// startSessionMonitor 跟踪所属登录会话的 Active 属性，多用户同时登录时只在前台会话中处理设备切换
+func startSessionMonitor() {
+	checkRuntimeDir()
+	if !config.Logind.OnlyActiveSession {
+		return
+	}
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		zap.L().Warn("无法连接系统总线，不检查登录会话状态", zap.Error(err))
+		return
+	}
+	path, err := ownSessionPath(conn)
+	if err != nil {
+		zap.L().Warn("无法确定所属的登录会话，不检查会话状态", zap.Error(err))
+		conn.Close()
+		return
+	}
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+	); err != nil {
+		zap.L().Warn("无法订阅登录会话状态", zap.Error(err))
+		conn.Close()
+		return
+	}
+
+	update := func(active bool) {
+		if sessionInactive.Swap(!active) == !active {
+			return
+		}
+		zap.L().Info("登录会话状态变化", zap.String("session", string(path)), zap.Bool("active", active))
+	}
+	if v, err := conn.Object(login1Name, path).GetProperty(login1Session + ".Active"); err == nil {
+		active, _ := v.Value().(bool)
+		update(active)
+	}
+	zap.L().Debug("跟踪登录会话状态", zap.String("session", string(path)))
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go func() {
+		for sig := range signals {
+			if len(sig.Body) < 2 {
+				continue
+			}
+			if iface, _ := sig.Body[0].(string); iface != login1Session {
+				continue
+			}
+			changed, _ := sig.Body[1].(map[string]dbus.Variant)
+			if v, ok := changed["Active"]; ok {
+				active, _ := v.Value().(bool)
+				update(active)
+			}
+		}
+	}()
+}
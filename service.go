@@ -0,0 +1,181 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"go.uber.org/zap"
+)
+
+const (
+	serviceName      = "io.github.nsplup.PwAutopaused"
+	serviceInterface = "io.github.nsplup.PwAutopaused"
+	servicePath      = dbus.ObjectPath("/io/github/nsplup/PwAutopaused")
+)
+
+var (
+	snoozeMu    sync.Mutex
+	snoozeUntil time.Time
+	snoozeTimer *time.Timer
+	// disabled 为真时自动暂停一直停用，直到调用 snooze(0)
+	disabled bool
+)
+
+func isSnoozed() bool {
+	snoozeMu.Lock()
+	defer snoozeMu.Unlock()
+	return disabled || time.Now().Before(snoozeUntil)
+}
+
+func snooze(d time.Duration) {
+	snoozeMu.Lock()
+	defer snoozeMu.Unlock()
+
+	if snoozeTimer != nil {
+		snoozeTimer.Stop()
+		snoozeTimer = nil
+	}
+	disabled = false
+	if d <= 0 {
+		snoozeUntil = time.Time{}
+		zap.L().Info("自动暂停已恢复")
+		go notifyStateChanged()
+		return
+	}
+
+	snoozeUntil = time.Now().Add(d)
+	snoozeTimer = time.AfterFunc(d, func() {
+		zap.L().Info("暂停时间已到，自动暂停已恢复")
+		notifyStateChanged()
+	})
+	zap.L().Info("自动暂停已暂时停用", zap.Time("until", snoozeUntil))
+	go notifyStateChanged()
+}
+
+// disable 停用自动暂停，不设到期时间
+func disable() {
+	snoozeMu.Lock()
+	defer snoozeMu.Unlock()
+
+	if snoozeTimer != nil {
+		snoozeTimer.Stop()
+		snoozeTimer = nil
+	}
+	snoozeUntil = time.Time{}
+	disabled = true
+	zap.L().Info("自动暂停已停用，需手动恢复")
+	go notifyStateChanged()
+}
+
+// notifyStateChanged 广播 StateChanged 信号，供 statusbar 等客户端及时刷新
+func notifyStateChanged() {
+	refreshTray()
+	conn := sessionBus()
+	if conn == nil {
+		return
+	}
+	if err := conn.Emit(servicePath, serviceInterface+".StateChanged"); err != nil {
+		zap.L().Debug("无法发送状态变更信号", zap.Error(err))
+	}
+}
+
+type controlService struct{}
+
+func (controlService) Snooze(minutes uint32) *dbus.Error {
+	snooze(time.Duration(minutes) * time.Minute)
+	return nil
+}
+
+func (controlService) Unsnooze() *dbus.Error {
+	snooze(0)
+	return nil
+}
+
+// Panic 立即静音默认输出并暂停所有播放器，不论设备分类；暂停在后台进行，调用会立即返回
+func (controlService) Panic() *dbus.Error {
+	go func() {
+		defer restoreOnPanic()
+		panicPause(panicTrigger)
+	}()
+	return nil
+}
+
+// Recover 撤销 Panic 留下的静音并恢复被暂停的播放器
+func (controlService) Recover() *dbus.Error {
+	go func() {
+		defer restoreOnPanic()
+		resumeNow(recoverTrigger)
+	}()
+	return nil
+}
+
+func (controlService) History() ([]map[string]dbus.Variant, *dbus.Error) {
+	return historyVariants(), nil
+}
+
+func (controlService) Status() (map[string]dbus.Variant, *dbus.Error) {
+	nodes, devices := registry.Len()
+
+	snoozeMu.Lock()
+	until, off := snoozeUntil, disabled
+	snoozeMu.Unlock()
+
+	last, _ := lastHistory()
+	event, at := last.Summary(), last.Time
+
+	status := map[string]dbus.Variant{
+		"DefaultSink":     dbus.MakeVariant(sinkPolicy.Sink()),
+		"Classification":  dbus.MakeVariant(sinkPolicy.Class().String()),
+		"State":           dbus.MakeVariant(sinkPolicy.State().String()),
+		"Nodes":           dbus.MakeVariant(uint32(nodes)),
+		"Devices":         dbus.MakeVariant(uint32(devices)),
+		"Enabled":         dbus.MakeVariant(!off && !time.Now().Before(until)),
+		"Mode":            dbus.MakeVariant(effectiveMode()),
+		"Players":         dbus.MakeVariant(playerSummaries()),
+		"LastEvent":       dbus.MakeVariant(event),
+		"LastEventTime":   dbus.MakeVariant(int64(0)),
+		"SnoozedUntil":    dbus.MakeVariant(int64(0)),
+		"CommandFailures": dbus.MakeVariant(pwCliFailures.Load()),
+		"Ambiguous":       dbus.MakeVariant(ambiguousCount.Load()),
+		"StartupDevices":  dbus.MakeVariant(startupReport()),
+	}
+	if !at.IsZero() {
+		status["LastEventTime"] = dbus.MakeVariant(at.Unix())
+	}
+	if time.Now().Before(until) {
+		status["SnoozedUntil"] = dbus.MakeVariant(until.Unix())
+	}
+	return status, nil
+}
+
+func startControlService() {
+	conn := sessionBus()
+	if conn == nil {
+		return
+	}
+
+	svc := controlService{}
+	if err := conn.Export(svc, servicePath, serviceInterface); err != nil {
+		zap.L().Warn("无法导出控制接口", zap.Error(err))
+		return
+	}
+	node := &introspect.Node{
+		Name: string(servicePath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			{Name: serviceInterface, Methods: introspect.Methods(svc)},
+		},
+	}
+	conn.Export(introspect.NewIntrospectable(node), servicePath, "org.freedesktop.DBus.Introspectable")
+
+	reply, err := conn.RequestName(serviceName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		zap.L().Warn("无法注册控制接口名称", zap.Error(err))
+		return
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		zap.L().Warn("控制接口名称已被占用，可能已有其他实例在运行", zap.String("name", serviceName))
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	busMu    sync.RWMutex
+	dbusConn *dbus.Conn
+)
+
+// sessionBus 返回长期复用的会话总线连接，MPRIS、通知与控制接口共用同一连接
+func sessionBus() *dbus.Conn {
+	busMu.RLock()
+	defer busMu.RUnlock()
+	return dbusConn
+}
+
+func connectSessionBus() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+
+	busMu.Lock()
+	dbusConn = conn
+	busMu.Unlock()
+
+	startPlayerRegistry()
+	startNotificationActions()
+	watchNotificationInhibition()
+	startControlService()
+	startTray()
+	startShortcuts()
+	return nil
+}
+
+// superviseSessionBus 在会话总线连接断开后自动重连，并重新注册信号订阅与控制接口
+func superviseSessionBus(ctx context.Context) {
+	const (
+		minBackoff = time.Second
+		maxBackoff = 30 * time.Second
+	)
+
+	for {
+		select {
+		case <-sessionBus().Context().Done():
+		case <-ctx.Done():
+			sessionBus().Close()
+			return
+		}
+		zap.L().Warn("已从会话总线断开，正在重新连接")
+
+		backoff := minBackoff
+		for {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			err := connectSessionBus()
+			if err == nil {
+				zap.L().Info("已重新连接会话总线")
+				break
+			}
+			zap.L().Warn("重新连接会话总线失败", zap.Duration("backoff", backoff), zap.Error(err))
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}
+}
+
+// headless 表示没有会话总线（如没有桌面会话的 kiosk 系统），此时只在 PipeWire 中静音输出，
+// 不使用 MPRIS、桌面通知与控制接口
+var headless atomic.Bool
+
+// sessionBusAvailable 判断是否存在会话总线：未设置 DBUS_SESSION_BUS_ADDRESS 且 $XDG_RUNTIME_DIR/bus 与 /run/user/<uid>/bus 均不存在时视为没有，
+// 避免 godbus 回退到 dbus-launch 启动一个没有任何播放器的总线
+func sessionBusAvailable() bool {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		return true
+	}
+	dirs := []string{os.Getenv("XDG_RUNTIME_DIR"), fmt.Sprintf("/run/user/%d", os.Getuid())}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bus")); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// enterHeadless 切换为无会话总线的运行方式
+func enterHeadless(err error) {
+	headless.Store(true)
+	zap.L().Warn("没有可用的会话总线，只在 PipeWire 中静音输出，不暂停播放器、不发送通知", zap.String("mode", effectiveMode()), zap.Error(err))
+}
+
+// effectiveMode 返回实际使用的处理方式：无会话总线时需要 MPRIS 的处理方式（pause、safe-sink）改为 mute-only；
+// 不修改 config.Mode，会话总线出现或重新加载配置后自然恢复
+func effectiveMode() string {
+	mode := config.Mode
+	if headless.Load() && mode != "duck" && mode != "mute-only" {
+		return "mute-only"
+	}
+	return mode
+}
+
+// waitForSessionBus 在无头模式下定期检查会话总线是否出现（如显示管理器的 greeter 会话或早于用户会话启动的服务），
+// 出现后连接并恢复配置的处理方式
+func waitForSessionBus(ctx context.Context) {
+	const (
+		minBackoff = 2 * time.Second
+		maxBackoff = time.Minute
+	)
+
+	backoff := minBackoff
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+		if !sessionBusAvailable() {
+			continue
+		}
+		if err := connectSessionBus(); err != nil {
+			zap.L().Debug("会话总线尚不可用", zap.Duration("backoff", backoff), zap.Error(err))
+			continue
+		}
+
+		headless.Store(false)
+		zap.L().Info("会话总线已可用，恢复暂停播放器与桌面通知", zap.String("mode", effectiveMode()))
+		superviseSessionBus(ctx)
+		return
+	}
+}
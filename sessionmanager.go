@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+// detectSessionManager 通过进程名判断正在运行的会话管理器，无法判断时按 WirePlumber 处理
+func detectSessionManager() string {
+	comms, _ := filepath.Glob("/proc/[0-9]*/comm")
+	for _, path := range comms {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(string(data)) {
+		case "wireplumber":
+			return "wireplumber"
+		case "pipewire-media-":
+			// comm 最长 15 个字符，pipewire-media-session 会被截断
+			return "media-session"
+		}
+	}
+	return "wireplumber"
+}
+
+func applySessionManager() {
+	name := config.SessionManager
+	if name == "" || name == "auto" {
+		name = detectSessionManager()
+	}
+
+	manager := policy.WirePlumber
+	switch name {
+	case "wireplumber":
+	case "media-session":
+		manager = policy.MediaSession
+	default:
+		zap.L().Warn("未知的会话管理器，按 WirePlumber 处理", zap.String("session_manager", name))
+		name = "wireplumber"
+	}
+
+	sinkPolicy.SetManager(manager)
+	sourcePolicy.SetManager(manager)
+	zap.L().Info("会话管理器", zap.String("session_manager", name))
+}
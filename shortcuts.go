@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	portalName      = "org.freedesktop.portal.Desktop"
+	portalPath      = dbus.ObjectPath("/org/freedesktop/portal/desktop")
+	portalRequest   = "org.freedesktop.portal.Request"
+	shortcutsIface  = "org.freedesktop.portal.GlobalShortcuts"
+	shortcutPanic   = "panic"
+	shortcutResume  = "resume"
+	shortcutTrigger = "快捷键"
+)
+
+var portalToken atomic.Uint32
+
+// portalCall 调用门户方法并等待对应 Request 对象的 Response 信号；
+// 请求路径由发送方名称与 handle_token 决定，需在调用前订阅以免错过响应
+func portalCall(ctx context.Context, conn *dbus.Conn, method string, options map[string]dbus.Variant, args ...any) (map[string]dbus.Variant, error) {
+	token := fmt.Sprintf("pw_autopaused_%d", portalToken.Add(1))
+	options["handle_token"] = dbus.MakeVariant(token)
+	sender := strings.ReplaceAll(strings.TrimPrefix(conn.Names()[0], ":"), ".", "_")
+	path := dbus.ObjectPath(fmt.Sprintf("%s/request/%s/%s", portalPath, sender, token))
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(portalRequest),
+		dbus.WithMatchMember("Response"),
+	}
+	if err := conn.AddMatchSignal(match...); err != nil {
+		return nil, err
+	}
+	defer conn.RemoveMatchSignal(match...)
+	signals := make(chan *dbus.Signal, 4)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	if err := conn.Object(portalName, portalPath).CallWithContext(ctx, shortcutsIface+"."+method, 0, append(args, options)...).Err; err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case sig := <-signals:
+			if sig.Path != path || sig.Name != portalRequest+".Response" || len(sig.Body) < 2 {
+				continue
+			}
+			if code, _ := sig.Body[0].(uint32); code != 0 {
+				return nil, fmt.Errorf("%s: request cancelled (response %d)", method, code)
+			}
+			results, _ := sig.Body[1].(map[string]dbus.Variant)
+			return results, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+type portalShortcut struct {
+	ID      string
+	Options map[string]dbus.Variant
+}
+
+func newPortalShortcut(id, description, trigger string) portalShortcut {
+	options := map[string]dbus.Variant{"description": dbus.MakeVariant(description)}
+	if trigger != "" {
+		options["preferred_trigger"] = dbus.MakeVariant(trigger)
+	}
+	return portalShortcut{ID: id, Options: options}
+}
+
+// startShortcuts 通过 GlobalShortcuts 门户注册全局快捷键：一键静音并暂停所有播放器，以及立即恢复播放
+func startShortcuts() {
+	conn := sessionBus()
+	if !config.Shortcuts.Enabled || conn == nil {
+		return
+	}
+
+	go func() {
+		// 首次绑定时桌面环境会弹出确认对话框，留出足够的时间
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		results, err := portalCall(ctx, conn, "CreateSession", map[string]dbus.Variant{
+			"session_handle_token": dbus.MakeVariant("pw_autopaused"),
+		})
+		if err != nil {
+			zap.L().Warn("无法创建全局快捷键会话，桌面环境可能不支持 GlobalShortcuts 门户", zap.Error(err))
+			return
+		}
+		handle, _ := results["session_handle"].Value().(string)
+		session := dbus.ObjectPath(handle)
+
+		err = conn.AddMatchSignal(
+			dbus.WithMatchInterface(shortcutsIface),
+			dbus.WithMatchMember("Activated"),
+		)
+		if err != nil {
+			zap.L().Warn("无法订阅全局快捷键事件", zap.Error(err))
+			return
+		}
+		signals := make(chan *dbus.Signal, 16)
+		conn.Signal(signals)
+		go func() {
+			for sig := range signals {
+				if sig.Name != shortcutsIface+".Activated" || len(sig.Body) < 2 {
+					continue
+				}
+				if s, _ := sig.Body[0].(dbus.ObjectPath); s != session {
+					continue
+				}
+				id, _ := sig.Body[1].(string)
+				go handleShortcut(id)
+			}
+		}()
+
+		shortcuts := []portalShortcut{
+			newPortalShortcut(shortcutPanic, "静音并暂停所有播放器", config.Shortcuts.Panic),
+			newPortalShortcut(shortcutResume, "恢复被暂停的播放器", config.Shortcuts.Resume),
+		}
+		results, err = portalCall(ctx, conn, "BindShortcuts", map[string]dbus.Variant{}, session, shortcuts, "")
+		if err != nil {
+			zap.L().Warn("无法绑定全局快捷键", zap.Error(err))
+			return
+		}
+		var bound []portalShortcut
+		if v, ok := results["shortcuts"]; ok {
+			dbus.Store([]any{v.Value()}, &bound)
+		}
+		for _, s := range bound {
+			trigger, _ := s.Options["trigger_description"].Value().(string)
+			zap.L().Info("已绑定全局快捷键", zap.String("shortcut", s.ID), zap.String("trigger", trigger))
+		}
+	}()
+}
+
+func handleShortcut(id string) {
+	defer restoreOnPanic()
+
+	switch id {
+	case shortcutPanic:
+		panicPause(shortcutTrigger)
+	case shortcutResume:
+		resumeNow(shortcutTrigger)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// stopping 置位后不再静音新的节点，避免退出过程中与正在进行的暂停操作交错
+var stopping atomic.Bool
+
+// shutdown 撤销本程序留下的静音与降低音量，然后停止后端并等待子进程退出
+func shutdown(cancelBackend context.CancelFunc, backendDone <-chan struct{}) {
+	stopping.Store(true)
+	cancelPendingTransition()
+
+	restoreDucked()
+	unmuteAll()
+	stopGate()
+
+	cancelBackend()
+	select {
+	case <-backendDone:
+	case <-time.After(5 * time.Second):
+		zap.L().Warn("等待后端退出超时")
+	}
+}
+
+// restoreOnPanic 用于 defer：动作路径发生 panic 时先撤销本程序留下的静音与降低音量，
+// 再继续 panic，避免进程崩溃后输出设备一直处于静音状态
+func restoreOnPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	zap.L().Error("发生严重错误，正在恢复静音与音量后退出", zap.Any("panic", r), zap.Stack("stack"))
+	stopping.Store(true)
+
+	// panic 时可能仍持有锁，恢复操作限时进行，避免进程卡住而无法被重启
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				zap.L().Error("恢复静音与音量失败", zap.Any("panic", r))
+			}
+		}()
+		releasePolicy()
+		unmuteAll()
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		zap.L().Error("恢复静音与音量超时")
+	}
+	panic(r)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+var (
+	sourcePolicy = policy.NewMachine()
+
+	sourceMu    sync.Mutex
+	sourceMuted []int
+)
+
+func handleDefaultSourceChange(nodeName string) {
+	if sourcePolicy.Sink() == "" {
+		zap.L().Info("默认输入设备初始化为", zap.String("source", nodeName))
+	}
+
+	dev, class := classifyNode(nodeName, GetNodeIDByName, sourceClass)
+	d := sourcePolicy.Handle(policy.Event{Type: policy.SinkChanged, Sink: nodeName, Class: class})
+	if d.User {
+		releaseSourcePolicy()
+		return
+	}
+	nodeID, ok := GetNodeIDByName(nodeName)
+	if !ok {
+		return
+	}
+	applySourceDecision(d, nodeID, "输入设备变更", dev)
+}
+
+func handleDefaultSourceRouteChange(newDev Device) {
+	source := sourcePolicy.Sink()
+	currentDevID, ok := GetDeviceIDByNodeName(source)
+	if !ok || newDev.ID != currentDevID {
+		return
+	}
+
+	d := sourcePolicy.Handle(policy.Event{Type: policy.RouteChanged, Class: sourceClass(newDev)})
+
+	nodeID, nOk := GetNodeIDByName(source)
+	if !nOk {
+		return
+	}
+	applySourceDecision(d, nodeID, "输入路由变更", newDev)
+}
+
+func applySourceDecision(d policy.Decision, nodeID int, reason string, dev Device) {
+	switch d.Action {
+	case policy.Pause:
+		applySourcePolicy(nodeID, reason, dev)
+	case policy.Resume:
+		releaseSourcePolicy()
+	}
+}
+
+func applySourcePolicy(nodeID int, reason string, dev Device) {
+	var targets []int
+	switch config.Source.Policy {
+	case "mute-source":
+		targets = []int{nodeID}
+	case "mute-streams":
+		targets = inputStreamsFor(nodeID)
+	default:
+		return
+	}
+	if isSnoozed() {
+		zap.L().Info("自动暂停已暂时停用，跳过输入设备静音", zap.String("reason", reason))
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	zap.L().Info("静音输入，触发事件为【"+reason+"】", zap.Ints("nodes", targets))
+	for _, id := range targets {
+		setPipewireMute(id, true)
+	}
+
+	sourceMu.Lock()
+	sourceMuted = append(sourceMuted, targets...)
+	sourceMu.Unlock()
+
+	go sendNotification("麦克风已静音", fmt.Sprintf("输入已切换至 %s（触发事件：%s）", sourceDisplayName(dev), reason), nil)
+}
+
+func sourceDisplayName(dev Device) string {
+	if route, ok := ActiveRoute(dev, "input"); ok && route.Description != "" {
+		return route.Description
+	}
+	return DeviceDisplayName(dev)
+}
+
+func releaseSourcePolicy() {
+	sourceMu.Lock()
+	targets := sourceMuted
+	sourceMuted = nil
+	sourceMu.Unlock()
+
+	if len(targets) == 0 {
+		return
+	}
+	zap.L().Info("取消输入静音", zap.Ints("nodes", targets))
+	for _, id := range targets {
+		setPipewireMute(id, false)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+// ready 在首次确认默认输出设备后置位，当前批次的事件处理完毕后输出启动报告
+var ready atomic.Bool
+
+var (
+	reportOnce sync.Once
+	reportMu   sync.Mutex
+	// startupClasses 为启动时各输出设备的分类，键为设备显示名称
+	startupClasses = map[string]string{}
+)
+
+// reportStartup 在处理完首个快照后输出一次默认输出设备及所有设备的分类，
+// 让用户确认耳机等设备是否被正确识别
+func reportStartup() {
+	if !ready.Load() {
+		return
+	}
+	reportOnce.Do(func() {
+		sink := sinkPolicy.Sink()
+		_, class := classifyNode(sink, GetSinkIDByName, sinkClass)
+		zap.L().Info("启动时的默认输出设备", zap.String("sink", sink), zap.String("class", class.String()))
+
+		devices := registry.Devices()
+		sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+
+		classes := make(map[string]string)
+		var private int
+		for _, dev := range devices {
+			route, ok := GetHighestPriorityOutputRoute(dev)
+			if !ok {
+				continue
+			}
+			c := sinkClass(dev)
+			if c == policy.Private {
+				private++
+			}
+			classes[DeviceDisplayName(dev)] = c.String()
+			zap.L().Info("设备分类",
+				zap.Int("id", dev.ID),
+				zap.String("device", DeviceDisplayName(dev)),
+				zap.String("route", route.Name),
+				zap.String("port_type", route.PortType()),
+				zap.String("class", c.String()))
+			if c == policy.Unclassified {
+				zap.L().Warn("设备的输出路由无法分类，切换到该设备时不会暂停，可通过 [classify] 配置指定",
+					zap.String("device", DeviceDisplayName(dev)), zap.String("route", route.Name))
+			}
+		}
+		if len(classes) == 0 {
+			zap.L().Warn("首个快照中没有带输出路由的设备，请检查 PipeWire 与会话管理器是否正常运行")
+		} else if private == 0 {
+			zap.L().Warn("当前没有被识别为私有设备的输出设备，连接耳机后可运行 doctor 命令确认其分类")
+		}
+
+		reportMu.Lock()
+		startupClasses = classes
+		reportMu.Unlock()
+		go notifyStateChanged()
+	})
+}
+
+func startupReport() map[string]string {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	out := make(map[string]string, len(startupClasses))
+	for name, class := range startupClasses {
+		out[name] = class
+	}
+	return out
+}
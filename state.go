@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type savedNode struct {
+	Node     string    `json:"node"`
+	ByVolume bool      `json:"by_volume,omitempty"`
+	Volumes  []float64 `json:"volumes,omitempty"`
+
+	ducked bool
+}
+
+type savedState struct {
+	PausedPlayers []string  `json:"paused_players,omitempty"`
+	PausedAt      time.Time `json:"paused_at"`
+	// PausedSince 为每个播放器被暂停的时间，旧版本的状态文件中没有此项，按 PausedAt 处理
+	PausedSince map[string]time.Time `json:"paused_since,omitempty"`
+	Muted       []savedNode          `json:"muted,omitempty"`
+	Ducked      []savedNode          `json:"ducked,omitempty"`
+}
+
+var (
+	stateMu      sync.Mutex
+	restoreOnce  sync.Once
+	pendingState *savedState
+	// pendingNodes 为状态文件中记录、恢复时尚未出现的节点，节点出现后再恢复；受 stateMu 保护
+	pendingNodes []savedNode
+)
+
+func StatePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "pw-autopaused", "state.json")
+}
+
+func nodeNameByID(nodeID int) string {
+	node, _ := registry.Node(nodeID)
+	return node.Info.Props.NodeName
+}
+
+// saveState 将本程序暂停的播放器与静音、降低音量的节点写入状态文件，以便异常退出后恢复
+func saveState() {
+	if dryRun {
+		return
+	}
+	var st savedState
+
+	pausedMu.Lock()
+	st.PausedPlayers = pausedPlayers
+	st.PausedAt = pausedAt
+	st.PausedSince = maps.Clone(pausedSince)
+	pausedMu.Unlock()
+
+	mutedMu.Lock()
+	for id, m := range mutedNodes {
+		st.Muted = append(st.Muted, savedNode{Node: nodeNameByID(id), ByVolume: m.byVolume, Volumes: m.volumes})
+	}
+	mutedMu.Unlock()
+
+	duckedMu.Lock()
+	for id, volumes := range duckedNodes {
+		st.Ducked = append(st.Ducked, savedNode{Node: nodeNameByID(id), ByVolume: true, Volumes: volumes})
+	}
+	duckedMu.Unlock()
+
+	path := StatePath()
+	if path == "" {
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	// 尚未恢复的节点继续保留在状态文件中，再次异常退出后仍能恢复
+	for _, n := range pendingNodes {
+		if n.ducked {
+			st.Ducked = append(st.Ducked, n)
+		} else {
+			st.Muted = append(st.Muted, n)
+		}
+	}
+	if len(st.PausedPlayers) == 0 && len(st.Muted) == 0 && len(st.Ducked) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			zap.L().Warn("删除状态文件失败", zap.String("path", path), zap.Error(err))
+		}
+		return
+	}
+
+	data, err := json.Marshal(st)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err == nil {
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		zap.L().Warn("写入状态文件失败", zap.String("path", path), zap.Error(err))
+	}
+}
+
+func loadState() {
+	path := StatePath()
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			zap.L().Warn("读取状态文件失败", zap.String("path", path), zap.Error(err))
+		}
+		return
+	}
+
+	var st savedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		zap.L().Warn("状态文件格式错误，已忽略", zap.String("path", path), zap.Error(err))
+		return
+	}
+	pendingState = &st
+}
+
+// restoreSavedState 在收到首个 PipeWire 快照后恢复上次遗留的静音与音量，并重新提供恢复播放的选项；
+// 此时尚未出现的节点由 restorePendingNodes 在之后的节点更新中恢复
+func restoreSavedState() {
+	restoreOnce.Do(func() {
+		st := pendingState
+		pendingState = nil
+		if st == nil {
+			return
+		}
+
+		stateMu.Lock()
+		pendingNodes = st.Muted
+		for _, n := range st.Ducked {
+			n.ducked = true
+			pendingNodes = append(pendingNodes, n)
+		}
+		stateMu.Unlock()
+		restorePendingNodes()
+
+		now := time.Now()
+		since := make(map[string]time.Time)
+		var players []string
+		for _, p := range st.PausedPlayers {
+			at, ok := st.PausedSince[p]
+			if !ok {
+				at = st.PausedAt
+			}
+			if !resumeExpired(at, now) {
+				players = append(players, p)
+				since[p] = at
+			}
+		}
+		if len(players) > 0 && config.Resume.Enabled {
+			pausedMu.Lock()
+			pausedPlayers = players
+			pausedSince = since
+			pausedAt = st.PausedAt
+			pausedMu.Unlock()
+
+			if config.Resume.OfferOnStartup {
+				go func() {
+					id := sendNotification("播放仍处于暂停状态", "上次运行时暂停的播放器尚未恢复", []string{actionResume, "继续播放"})
+					pausedNotificationID.Store(id)
+				}()
+			}
+		}
+		saveState()
+	})
+}
+
+// restorePendingNodes 恢复状态文件中记录、此前尚未出现的节点的静音与音量
+func restorePendingNodes() {
+	stateMu.Lock()
+	if len(pendingNodes) == 0 {
+		stateMu.Unlock()
+		return
+	}
+	var ready []savedNode
+	var ids []int
+	kept := pendingNodes[:0:0]
+	for _, n := range pendingNodes {
+		if id, ok := GetNodeIDByName(n.Node); ok {
+			ready, ids = append(ready, n), append(ids, id)
+		} else {
+			kept = append(kept, n)
+		}
+	}
+	pendingNodes = kept
+	stateMu.Unlock()
+
+	for i, n := range ready {
+		switch {
+		case n.ducked:
+			zap.L().Info("恢复上次遗留的音量", zap.String("node", n.Node))
+			setNodeParams(ids[i], map[string]any{"channelVolumes": n.Volumes})
+		case n.ByVolume:
+			zap.L().Info("恢复上次遗留的静音节点", zap.String("node", n.Node))
+			setNodeParams(ids[i], map[string]any{"channelVolumes": n.Volumes})
+		default:
+			zap.L().Info("恢复上次遗留的静音节点", zap.String("node", n.Node))
+			setNodeParams(ids[i], map[string]any{"mute": false})
+		}
+	}
+	if len(ready) > 0 {
+		saveState()
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+type statusbarOutput struct {
+	Text    string   `json:"text"`
+	Alt     string   `json:"alt"`
+	Tooltip string   `json:"tooltip"`
+	Class   []string `json:"class"`
+}
+
+// statusbarState 查询守护进程状态并转换为 waybar 自定义模块使用的 JSON
+func statusbarState() statusbarOutput {
+	var status map[string]dbus.Variant
+	if err := callService("Status").Store(&status); err != nil {
+		return statusbarOutput{Text: "", Alt: "offline", Tooltip: "pw-autopaused 未运行", Class: []string{"offline"}}
+	}
+
+	str := func(key string) string {
+		v, _ := status[key].Value().(string)
+		return v
+	}
+	class := str("Classification")
+	if class != "public" && class != "private" {
+		class = "unknown"
+	}
+	icons := map[string]string{"public": "🔊", "private": "🎧", "unknown": "🔈"}
+	names := map[string]string{"public": "公共设备", "private": "私有设备", "unknown": "未知"}
+
+	sink := str("DefaultSink")
+	if sink == "" {
+		sink = "（无）"
+	}
+	tooltip := []string{fmt.Sprintf("默认输出设备：%s（%s）", sink, names[class])}
+
+	out := statusbarOutput{Text: icons[class], Alt: class, Class: []string{class}}
+	if enabled, _ := status["Enabled"].Value().(bool); enabled {
+		tooltip = append(tooltip, "自动暂停：已启用")
+		out.Class = append(out.Class, "armed")
+	} else {
+		if until, _ := status["SnoozedUntil"].Value().(int64); until > 0 {
+			tooltip = append(tooltip, fmt.Sprintf("自动暂停：已停用，将于 %s 恢复", time.Unix(until, 0).Format("15:04")))
+		} else {
+			tooltip = append(tooltip, "自动暂停：已停用")
+		}
+		out.Text = "💤"
+		out.Alt = "snoozed"
+		out.Class = append(out.Class, "snoozed")
+	}
+	if event := str("LastEvent"); event != "" {
+		tooltip = append(tooltip, "最近一次事件："+event)
+	}
+	out.Tooltip = strings.Join(tooltip, "\n")
+	return out
+}
+
+// runStatusbar 持续跟随守护进程的状态，每次变化时输出一行 waybar 兼容的 JSON
+func runStatusbar() int {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "无法连接会话总线：", err)
+		return 1
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface(serviceInterface),
+		dbus.WithMatchMember("StateChanged"),
+	); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// 守护进程启动或退出时同样刷新
+	conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, serviceName),
+	)
+
+	// 定期刷新以反映暂停到期等不产生信号的变化
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	enc := json.NewEncoder(os.Stdout)
+	var last statusbarOutput
+	for first := true; ; first = false {
+		cur := statusbarState()
+		if first || cur.Tooltip != last.Tooltip || cur.Text != last.Text {
+			if err := enc.Encode(cur); err != nil {
+				return 1
+			}
+			last = cur
+		}
+
+		select {
+		case _, ok := <-signals:
+			if !ok {
+				return 1
+			}
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	streamPolicyMu    sync.Mutex
+	streamPolicyMuted []int
+)
+
+func streamTarget(stream Node) string {
+	streamMovesMu.Lock()
+	moved, ok := streamMoves[stream.ID]
+	streamMovesMu.Unlock()
+	if ok {
+		return strconv.Itoa(moved)
+	}
+
+	target := stream.Info.Props.TargetObject.String()
+	if target == "" {
+		target = stream.Info.Props.NodeTarget.String()
+	}
+	if target == "-1" {
+		return ""
+	}
+	return target
+}
+
+func streamTargetsSink(stream Node, sink Node) bool {
+	return streamTarget(stream) == "" || streamExplicitlyTargets(stream, sink)
+}
+
+// streamLinkedTo 根据实际的连接判断流是否连接到 target；known 为假表示流尚无连接信息，
+// 此时只能按 target.object 与默认设备推断
+func streamLinkedTo(stream Node, target Node) (linked, known bool) {
+	downstream := stream.Info.Props.MediaClass != "Stream/Input/Audio"
+	peers := registry.LinkedNodes(stream.ID, downstream)
+	if len(peers) == 0 {
+		return false, false
+	}
+	return slices.Contains(peers, target.ID), true
+}
+
+// streamFeeds 判断流是否正在输出到 target（输入流则为是否从 target 录音），有连接信息时以实际连接为准
+func streamFeeds(stream Node, target Node) bool {
+	if linked, known := streamLinkedTo(stream, target); known {
+		return linked
+	}
+	return streamTargetsSink(stream, target)
+}
+
+func outputStreamsFor(sinkID int) []int {
+	return streamsFor(sinkID, "Stream/Output/Audio")
+}
+
+func inputStreamsFor(sourceID int) []int {
+	return streamsFor(sourceID, "Stream/Input/Audio")
+}
+
+func streamsFor(targetID int, mediaClass string) []int {
+	target, ok := registry.Node(targetID)
+	if !ok {
+		return nil
+	}
+
+	var streams []int
+	for _, node := range registry.Nodes() {
+		id := node.ID
+		if node.Info.Props.MediaClass != mediaClass {
+			continue
+		}
+		if streamFeeds(node, target) {
+			streams = append(streams, id)
+		}
+	}
+	return streams
+}
+
+func guardTargets(sinkID int) []int {
+	if config.Guard == "streams" {
+		if streams := outputStreamsFor(sinkID); len(streams) > 0 {
+			return streams
+		}
+	}
+	return []int{sinkID}
+}
+
+func matchApp(node Node, patterns []string) bool {
+	props := node.Info.Props
+	names := []string{props.ApplicationName.String(), props.ApplicationBinary.String()}
+	// 部分程序只在客户端上设置应用名称，节点上没有 application.name
+	if c, ok := nodeClient(node); ok {
+		names = append(names, clientName(c), c.Info.Props.ApplicationBinary.String())
+	}
+	for _, p := range patterns {
+		for _, name := range names {
+			if name != "" && matchName(p, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isStreamSelected(node Node) bool {
+	include, exclude := config.Streams.Include, config.Streams.Exclude
+	if matchApp(node, exclude) {
+		return false
+	}
+	return len(include) == 0 || matchApp(node, include)
+}
+
+func applyStreamPolicy(sinkID int) {
+	if config.Streams.Mode != "mute" {
+		return
+	}
+
+	var targets []int
+	for _, id := range outputStreamsFor(sinkID) {
+		node, _ := registry.Node(id)
+		if isStreamSelected(node) {
+			targets = append(targets, id)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	zap.L().Info("静音仍在输出的应用流", zap.Ints("nodes", targets))
+	for _, id := range targets {
+		setPipewireMute(id, true)
+	}
+
+	streamPolicyMu.Lock()
+	streamPolicyMuted = append(streamPolicyMuted, targets...)
+	streamPolicyMu.Unlock()
+}
+
+func releaseStreamPolicy() {
+	streamPolicyMu.Lock()
+	targets := streamPolicyMuted
+	streamPolicyMuted = nil
+	streamPolicyMu.Unlock()
+
+	if len(targets) == 0 {
+		return
+	}
+	zap.L().Info("取消应用流静音", zap.Ints("nodes", targets))
+	for _, id := range targets {
+		setPipewireMute(id, false)
+	}
+}
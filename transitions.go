@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+// transitionAction 按 [transitions] 返回设备分类从 from 变为 to 时的动作。
+// 键为「原分类-新分类」，分类为 private、public、unknown 或 any，按精确匹配、from-any、any-to、any-any 的顺序查找。
+// changed 为 false 表示默认设备本身没有变化（如路由更新），此时分类不变视为没有切换
+func transitionAction(from, to policy.Class, changed bool) policy.Action {
+	if from == to && !changed {
+		return policy.None
+	}
+
+	keys := []string{
+		from.String() + "-" + to.String(),
+		from.String() + "-any",
+		"any-" + to.String(),
+		"any-any",
+	}
+	for _, key := range keys {
+		value, ok := config.Transitions[key]
+		if !ok {
+			continue
+		}
+		action, ok := policy.ParseAction(value)
+		if !ok {
+			zap.L().Warn("无效的切换动作，按 none 处理", zap.String("transition", key), zap.String("action", value))
+		}
+		return dndAction(action, changed)
+	}
+	return dndAction(policy.None, changed)
+}
+
+// dndAction 在勿扰模式下按 always-pause 将其余的设备变更也视为需要暂停
+func dndAction(action policy.Action, changed bool) policy.Action {
+	if action == policy.None && changed && dndPolicy("always-pause") {
+		return policy.Pause
+	}
+	return action
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nsplup/pw-autopaused/policy"
+)
+
+func TestTransitionAction(t *testing.T) {
+	tests := []struct {
+		name        string
+		transitions map[string]string
+		from, to    policy.Class
+		changed     bool
+		want        policy.Action
+	}{
+		{name: "私有到公共", from: policy.Private, to: policy.Public, changed: true, want: policy.Pause},
+		{name: "公共到私有", from: policy.Public, to: policy.Private, changed: true, want: policy.Resume},
+		{name: "私有到私有", from: policy.Private, to: policy.Private, changed: true, want: policy.None},
+		{name: "公共到公共", from: policy.Public, to: policy.Public, changed: true, want: policy.None},
+		{name: "私有到未分类", from: policy.Private, to: policy.Unclassified, changed: true, want: policy.None},
+		{name: "路由未变化", from: policy.Public, to: policy.Public, want: policy.None},
+		{
+			name:        "any 通配公共设备",
+			transitions: map[string]string{"any-public": "pause"},
+			from:        policy.Unclassified,
+			to:          policy.Public,
+			changed:     true,
+			want:        policy.Pause,
+		},
+		{
+			name:        "精确配置优先于通配",
+			transitions: map[string]string{"private-public": "none", "any-public": "pause"},
+			from:        policy.Private,
+			to:          policy.Public,
+			changed:     true,
+			want:        policy.None,
+		},
+		{
+			name:        "无效的动作按 none 处理",
+			transitions: map[string]string{"private-public": "stop"},
+			from:        policy.Private,
+			to:          policy.Public,
+			changed:     true,
+			want:        policy.None,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t)
+			if tt.transitions != nil {
+				config.Transitions = tt.transitions
+			}
+			if got := transitionAction(tt.from, tt.to, tt.changed); got != tt.want {
+				t.Errorf("transitionAction(%v, %v, %v) = %v，期望 %v", tt.from, tt.to, tt.changed, got, tt.want)
+			}
+		})
+	}
+}
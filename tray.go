@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"go.uber.org/zap"
+)
+
+const (
+	trayInterface    = "org.kde.StatusNotifierItem"
+	trayPath         = dbus.ObjectPath("/StatusNotifierItem")
+	trayMenuPath     = dbus.ObjectPath("/MenuBar")
+	trayMenuIface    = "com.canonical.dbusmenu"
+	trayWatcherName  = "org.kde.StatusNotifierWatcher"
+	trayWatcherPath  = dbus.ObjectPath("/StatusNotifierWatcher")
+	trayWatcherIface = "org.kde.StatusNotifierWatcher"
+)
+
+// 菜单项 ID，0 为根节点
+const (
+	trayItemSnooze int32 = iota + 1
+	trayItemDisable
+	trayItemResume
+	trayItemSeparator
+	trayItemConfig
+)
+
+// trayState 为托盘图标显示的状态。与摄像头、麦克风的隐私指示器一致，
+// 一切正常时图标处于 Passive（多数托盘会将其收起），只有停用或有待恢复的播放器时才显示出来
+type trayState struct {
+	status  string
+	icon    string
+	tooltip string
+	snoozed bool
+	paused  bool
+}
+
+var (
+	trayMu       sync.Mutex
+	trayProps    *prop.Properties
+	trayCurrent  trayState
+	trayRevision uint32
+)
+
+func currentTrayState() trayState {
+	snoozeMu.Lock()
+	until, off := snoozeUntil, disabled
+	snoozeMu.Unlock()
+	pausedMu.Lock()
+	paused := len(pausedPlayers)
+	pausedMu.Unlock()
+
+	s := trayState{status: "Passive", icon: "audio-headphones", tooltip: "自动暂停：已启用"}
+	switch {
+	case off:
+		s.status, s.icon, s.tooltip, s.snoozed = "Active", "appointment-soon", "自动暂停：已停用", true
+	case time.Now().Before(until):
+		s.status, s.icon, s.snoozed = "Active", "appointment-soon", true
+		s.tooltip = fmt.Sprintf("自动暂停：已停用，将于 %s 恢复", until.Format("15:04"))
+	}
+	if paused > 0 {
+		s.status, s.icon, s.paused = "NeedsAttention", "media-playback-pause", true
+		s.tooltip += fmt.Sprintf("\n已暂停 %d 个播放器，等待恢复", paused)
+	}
+	return s
+}
+
+type trayPixmap struct {
+	Width, Height int32
+	Data          []byte
+}
+
+type trayToolTip struct {
+	IconName    string
+	IconPixmap  []trayPixmap
+	Title       string
+	Description string
+}
+
+func (s trayState) toolTip() trayToolTip {
+	return trayToolTip{IconName: s.icon, Title: "pw-autopaused", Description: s.tooltip}
+}
+
+// trayItem 实现 StatusNotifierItem 接口；菜单由 dbusmenu 提供，点击图标时托盘直接显示菜单
+type trayItem struct{}
+
+func (trayItem) Activate(x, y int32) *dbus.Error          { return nil }
+func (trayItem) SecondaryActivate(x, y int32) *dbus.Error { return nil }
+func (trayItem) ContextMenu(x, y int32) *dbus.Error       { return nil }
+func (trayItem) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
+
+type trayLayout struct {
+	ID       int32
+	Props    map[string]dbus.Variant
+	Children []dbus.Variant
+}
+
+type trayItemProps struct {
+	ID    int32
+	Props map[string]dbus.Variant
+}
+
+type trayEvent struct {
+	ID        int32
+	EventID   string
+	Data      dbus.Variant
+	Timestamp uint32
+}
+
+// trayMenu 实现 com.canonical.dbusmenu 接口
+type trayMenu struct{}
+
+func trayMenuItems(s trayState) []trayItemProps {
+	item := func(id int32, label string, enabled bool) trayItemProps {
+		return trayItemProps{ID: id, Props: map[string]dbus.Variant{
+			"label":   dbus.MakeVariant(label),
+			"enabled": dbus.MakeVariant(enabled),
+		}}
+	}
+	disable := item(trayItemDisable, "停用自动暂停", true)
+	if s.snoozed {
+		disable = item(trayItemDisable, "重新启用自动暂停", true)
+	}
+	return []trayItemProps{
+		item(trayItemSnooze, "停用 15 分钟", !s.snoozed),
+		disable,
+		item(trayItemResume, "立即恢复播放", s.paused),
+		{ID: trayItemSeparator, Props: map[string]dbus.Variant{"type": dbus.MakeVariant("separator")}},
+		item(trayItemConfig, "打开配置文件", true),
+	}
+}
+
+func (trayMenu) GetLayout(parentID int32, depth int32, names []string) (uint32, trayLayout, *dbus.Error) {
+	trayMu.Lock()
+	s, rev := trayCurrent, trayRevision
+	trayMu.Unlock()
+
+	root := trayLayout{ID: 0, Props: map[string]dbus.Variant{"children-display": dbus.MakeVariant("submenu")}}
+	if parentID != 0 {
+		return rev, trayLayout{ID: parentID, Props: map[string]dbus.Variant{}}, nil
+	}
+	if depth != 0 {
+		for _, item := range trayMenuItems(s) {
+			root.Children = append(root.Children, dbus.MakeVariant(trayLayout{ID: item.ID, Props: item.Props, Children: []dbus.Variant{}}))
+		}
+	}
+	return rev, root, nil
+}
+
+func (trayMenu) GetGroupProperties(ids []int32, names []string) ([]trayItemProps, *dbus.Error) {
+	trayMu.Lock()
+	s := trayCurrent
+	trayMu.Unlock()
+
+	var out []trayItemProps
+	for _, item := range trayMenuItems(s) {
+		for _, id := range ids {
+			if id == item.ID {
+				out = append(out, item)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (trayMenu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	trayMu.Lock()
+	s := trayCurrent
+	trayMu.Unlock()
+
+	for _, item := range trayMenuItems(s) {
+		if v, ok := item.Props[name]; ok && item.ID == id {
+			return v, nil
+		}
+	}
+	return dbus.Variant{}, dbus.MakeFailedError(fmt.Errorf("unknown property %q of item %d", name, id))
+}
+
+func (m trayMenu) Event(id int32, eventID string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventID == "clicked" {
+		go trayClicked(id)
+	}
+	return nil
+}
+
+func (m trayMenu) EventGroup(events []trayEvent) ([]int32, *dbus.Error) {
+	for _, e := range events {
+		m.Event(e.ID, e.EventID, e.Data, e.Timestamp)
+	}
+	return []int32{}, nil
+}
+
+func (trayMenu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+func (trayMenu) AboutToShowGroup(ids []int32) ([]int32, []int32, *dbus.Error) {
+	return []int32{}, []int32{}, nil
+}
+
+func trayClicked(id int32) {
+	defer restoreOnPanic()
+
+	switch id {
+	case trayItemSnooze:
+		snooze(15 * time.Minute)
+	case trayItemDisable:
+		if isSnoozed() {
+			snooze(0)
+		} else {
+			disable()
+		}
+	case trayItemResume:
+		resumeNow("托盘菜单")
+	case trayItemConfig:
+		openConfig()
+	}
+}
+
+// openConfig 用默认程序打开用户配置文件，文件不存在时先创建空文件
+func openConfig() {
+	path := ConfigPath()
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			os.WriteFile(path, nil, 0o644)
+		}
+	}
+	// 等待 xdg-open 退出，避免在常驻进程中留下僵尸进程
+	go func() {
+		if out, err := exec.Command("xdg-open", path).CombinedOutput(); err != nil {
+			zap.L().Warn("无法打开配置文件", zap.String("path", path), zap.ByteString("output", out), zap.Error(err))
+		}
+	}()
+}
+
+// startTray 在会话总线上导出托盘图标与菜单，并向 StatusNotifierWatcher 注册；
+// 托盘程序（重新）启动时自动重新注册
+func startTray() {
+	conn := sessionBus()
+	if !config.Tray.Enabled || conn == nil {
+		return
+	}
+
+	s := currentTrayState()
+	props, err := prop.Export(conn, trayPath, prop.Map{
+		trayInterface: {
+			"Category":          {Value: "ApplicationStatus", Emit: prop.EmitFalse},
+			"Id":                {Value: "pw-autopaused", Emit: prop.EmitFalse},
+			"Title":             {Value: "pw-autopaused", Emit: prop.EmitFalse},
+			"Status":            {Value: s.status, Emit: prop.EmitFalse},
+			"WindowId":          {Value: int32(0), Emit: prop.EmitFalse},
+			"IconName":          {Value: s.icon, Emit: prop.EmitFalse},
+			"IconPixmap":        {Value: []trayPixmap{}, Emit: prop.EmitFalse},
+			"OverlayIconName":   {Value: "", Emit: prop.EmitFalse},
+			"AttentionIconName": {Value: "media-playback-pause", Emit: prop.EmitFalse},
+			"ToolTip":           {Value: s.toolTip(), Emit: prop.EmitFalse},
+			"ItemIsMenu":        {Value: true, Emit: prop.EmitFalse},
+			"Menu":              {Value: trayMenuPath, Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		zap.L().Warn("无法导出托盘图标", zap.Error(err))
+		return
+	}
+	conn.Export(trayItem{}, trayPath, trayInterface)
+	conn.Export(introspect.NewIntrospectable(&introspect.Node{
+		Name: string(trayPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: trayInterface, Methods: introspect.Methods(trayItem{}), Properties: props.Introspection(trayInterface)},
+		},
+	}), trayPath, "org.freedesktop.DBus.Introspectable")
+
+	menuProps, err := prop.Export(conn, trayMenuPath, prop.Map{
+		trayMenuIface: {
+			"Version":       {Value: uint32(3), Emit: prop.EmitFalse},
+			"TextDirection": {Value: "ltr", Emit: prop.EmitFalse},
+			"Status":        {Value: "normal", Emit: prop.EmitFalse},
+			"IconThemePath": {Value: []string{}, Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		zap.L().Warn("无法导出托盘菜单", zap.Error(err))
+		return
+	}
+	conn.Export(trayMenu{}, trayMenuPath, trayMenuIface)
+	conn.Export(introspect.NewIntrospectable(&introspect.Node{
+		Name: string(trayMenuPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: trayMenuIface, Methods: introspect.Methods(trayMenu{}), Properties: menuProps.Introspection(trayMenuIface)},
+		},
+	}), trayMenuPath, "org.freedesktop.DBus.Introspectable")
+
+	trayMu.Lock()
+	trayProps, trayCurrent = props, s
+	trayRevision++
+	trayMu.Unlock()
+
+	err = conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, trayWatcherName),
+	)
+	if err != nil {
+		zap.L().Warn("无法订阅托盘服务的变化", zap.Error(err))
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go func() {
+		for sig := range signals {
+			if sig.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(sig.Body) < 3 {
+				continue
+			}
+			if name, _ := sig.Body[0].(string); name != trayWatcherName {
+				continue
+			}
+			if owner, _ := sig.Body[2].(string); owner != "" {
+				registerTray(conn)
+			}
+		}
+	}()
+	registerTray(conn)
+}
+
+func registerTray(conn *dbus.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// 以连接的唯一名称注册，托盘按约定在 /StatusNotifierItem 查找图标
+	err := conn.Object(trayWatcherName, trayWatcherPath).CallWithContext(ctx, trayWatcherIface+".RegisterStatusNotifierItem", 0, conn.Names()[0]).Err
+	if err != nil {
+		zap.L().Debug("无法注册托盘图标，可能没有运行支持 StatusNotifierItem 的托盘", zap.Error(err))
+		return
+	}
+	zap.L().Info("已注册托盘图标")
+}
+
+// refreshTray 在状态变化后更新图标、提示与菜单，并发送 StatusNotifierItem 约定的信号
+func refreshTray() {
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	if trayProps == nil {
+		return
+	}
+	conn := sessionBus()
+	if conn == nil {
+		return
+	}
+
+	s := currentTrayState()
+	old := trayCurrent
+	if s == old {
+		return
+	}
+	trayCurrent = s
+	trayRevision++
+
+	trayProps.SetMust(trayInterface, "Status", s.status)
+	trayProps.SetMust(trayInterface, "IconName", s.icon)
+	trayProps.SetMust(trayInterface, "ToolTip", s.toolTip())
+	if s.status != old.status {
+		conn.Emit(trayPath, trayInterface+".NewStatus", s.status)
+	}
+	if s.icon != old.icon {
+		conn.Emit(trayPath, trayInterface+".NewIcon")
+	}
+	if s.tooltip != old.tooltip {
+		conn.Emit(trayPath, trayInterface+".NewToolTip")
+	}
+	if s.snoozed != old.snoozed || s.paused != old.paused {
+		conn.Emit(trayMenuPath, trayMenuIface+".LayoutUpdated", trayRevision, int32(0))
+	}
+	zap.L().Debug("托盘图标状态已更新", zap.String("status", s.status), zap.String("tooltip", strings.ReplaceAll(s.tooltip, "\n", "；")))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/nsplup/pw-autopaused/policy"
+	"go.uber.org/zap"
+)
+
+// maxVirtualDepth 限制逐层解析虚拟节点的层数，避免配置错误形成环路时无限解析
+const maxVirtualDepth = 4
+
+// groupPeers 返回与节点同属一个 node.link-group 或 node.group 的流节点：
+// 回声消除、filter-chain 与组合输出等虚拟 sink 通过这些流将声音送往下层设备
+func groupPeers(node Node) []Node {
+	props := node.Info.Props
+	class := "Stream/Output/Audio"
+	if strings.Contains(props.MediaClass, "Source") {
+		class = "Stream/Input/Audio"
+	}
+	same := func(a, b PropString) bool {
+		return a.String() != "" && a.String() == b.String()
+	}
+
+	var peers []Node
+	for _, n := range registry.Nodes() {
+		if n.ID == node.ID || n.Info.Props.MediaClass != class {
+			continue
+		}
+		if same(props.LinkGroup, n.Info.Props.LinkGroup) || same(props.NodeGroup, n.Info.Props.NodeGroup) {
+			peers = append(peers, n)
+		}
+	}
+	return peers
+}
+
+// isVirtualNode 判断节点是否为不对应硬件路由的虚拟节点
+func isVirtualNode(node Node) bool {
+	if node.Info.Props.DeviceID == 0 {
+		return true
+	}
+	dev, ok := registry.Device(node.Info.Props.DeviceID)
+	return ok && len(dev.Info.Params.Route) == 0 && len(groupPeers(node)) > 0
+}
+
+// resolveNode 按节点名称、object.serial 或节点索引查找设备节点（不含流节点）
+func resolveNode(target string) (Node, bool) {
+	if target == "" {
+		return Node{}, false
+	}
+	for _, node := range registry.Nodes() {
+		if !strings.HasPrefix(node.Info.Props.MediaClass, "Audio/") {
+			continue
+		}
+		if target == node.Info.Props.NodeName || target == node.Info.Props.ObjectSerial.String() || target == strconv.Itoa(node.ID) {
+			return node, true
+		}
+	}
+	return Node{}, false
+}
+
+// hardwareNodes 返回节点下层的硬件节点：普通节点返回其自身；虚拟节点沿同组流的目标逐层解析，
+// 组合输出可能对应多个硬件节点，无法解析时返回空
+func hardwareNodes(node Node) []Node {
+	return resolveHardware(node, make(map[int]bool), 0)
+}
+
+func resolveHardware(node Node, seen map[int]bool, depth int) []Node {
+	if !isVirtualNode(node) {
+		return []Node{node}
+	}
+	if depth >= maxVirtualDepth || seen[node.ID] {
+		return nil
+	}
+	seen[node.ID] = true
+	if gateEnabled() && node.Info.Props.NodeName == config.Gate.Name {
+		// 防护输出在暂停期间处于断开状态，以记录的目标设备为准
+		if target, ok := resolveNode(gateTargetName()); ok {
+			return resolveHardware(target, seen, depth+1)
+		}
+	}
+
+	var (
+		out      []Node
+		unpinned = []int{node.ID}
+	)
+	for _, peer := range groupPeers(node) {
+		if target, ok := resolveNode(streamTarget(peer)); ok {
+			out = append(out, resolveHardware(target, seen, depth+1)...)
+		} else {
+			unpinned = append(unpinned, peer.ID)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	// 没有指定目标的流（如 EasyEffects、由会话管理器连接的 filter-chain）沿实际的连接查找
+	for _, target := range followLinks(unpinned, !strings.Contains(node.Info.Props.MediaClass, "Source")) {
+		out = append(out, resolveHardware(target, seen, depth+1)...)
+	}
+	return out
+}
+
+// followLinks 从 start 中的节点出发沿连接逐层查找，返回遇到的第一层设备节点；
+// 中间经过的滤镜、流等节点继续向下查找。downstream 为真时沿声音流出的方向，否则逆向查找
+func followLinks(start []int, downstream bool) []Node {
+	const maxLinkHops = 8
+
+	seen := make(map[int]bool)
+	for _, id := range start {
+		seen[id] = true
+	}
+	var found []Node
+	frontier := start
+	for hop := 0; hop < maxLinkHops && len(frontier) > 0; hop++ {
+		var next []int
+		for _, id := range frontier {
+			for _, linked := range registry.LinkedNodes(id, downstream) {
+				if seen[linked] {
+					continue
+				}
+				seen[linked] = true
+				if node, ok := registry.Node(linked); ok && strings.HasPrefix(node.Info.Props.MediaClass, "Audio/") {
+					found = append(found, node)
+					continue
+				}
+				next = append(next, linked)
+			}
+		}
+		frontier = next
+	}
+	return found
+}
+
+// handleVirtualSinkLinks 在连接变化后重新判断作为默认输出的虚拟 sink：
+// 如 EasyEffects 将输出从耳机改接到扬声器时，默认输出设备本身不会变化
+func handleVirtualSinkLinks() {
+	sink := sinkPolicy.Sink()
+	nodeID, ok := GetSinkIDByName(sink)
+	if !ok {
+		return
+	}
+	node, ok := registry.Node(nodeID)
+	if !ok || !isVirtualNode(node) {
+		return
+	}
+	dev, class := classifyHardware(node, sinkClass)
+	if class == policy.Unclassified || class == sinkPolicy.Class() {
+		return
+	}
+
+	zap.L().Info("虚拟输出设备的下层设备已变更", zap.String("sink", sink), zap.String("device", DeviceDisplayName(dev)), zap.String("class", class.String()))
+	d := sinkPolicy.Handle(policy.Event{Type: policy.RouteChanged, Class: class})
+	go notifyStateChanged()
+	submitTransition(transition{oldSink: sink, newSink: sink, from: d.From, to: d.To, dev: dev, nodeID: nodeID, reason: "虚拟输出设备的下层设备变更"})
+}
+
+// classifyHardware 按下层硬件节点为节点分类：任一硬件节点为公共设备即视为公共设备（组合输出会从扬声器外放），
+// 全部为私有设备时才视为私有设备
+func classifyHardware(node Node, classify func(Device) policy.Class) (Device, policy.Class) {
+	var (
+		first   Device
+		found   bool
+		private int
+		nodes   = hardwareNodes(node)
+	)
+	for _, hw := range nodes {
+		dev, ok := registry.Device(hw.Info.Props.DeviceID)
+		if !ok {
+			continue
+		}
+		if !found {
+			first, found = dev, true
+		}
+		switch classify(nodeDevice(dev, hw)) {
+		case policy.Public:
+			return dev, policy.Public
+		case policy.Private:
+			private++
+		}
+	}
+	if !found {
+		return Device{}, policy.Unclassified
+	}
+	if private == len(nodes) {
+		return first, policy.Private
+	}
+	return first, policy.Unclassified
+}
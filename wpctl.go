@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// wpctlController 通过 WirePlumber 的 wpctl 写入节点参数。
+// 按节点索引而不是 @DEFAULT_AUDIO_SINK@ 操作：暂停时默认设备可能已经切换，别名会指向新的设备
+type wpctlController struct{}
+
+func (wpctlController) SetNodeProps(nodeID int, values map[string]any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	id := fmt.Sprint(nodeID)
+	for key, v := range values {
+		var args []string
+		switch key {
+		case "mute":
+			mute := "0"
+			if b, _ := v.(bool); b {
+				mute = "1"
+			}
+			args = []string{"set-mute", id, mute}
+		case "channelVolumes":
+			volumes, _ := v.([]float64)
+			if len(volumes) == 0 {
+				continue
+			}
+			// wpctl 只能为所有声道设置同一音量，且使用立方刻度
+			args = []string{"set-volume", id, fmt.Sprintf("%.6f", math.Cbrt(slices.Max(volumes)))}
+		default:
+			continue
+		}
+		if out, err := exec.CommandContext(ctx, "wpctl", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("wpctl %s: %w: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	return nil
+}